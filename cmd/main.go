@@ -2,8 +2,12 @@ package main
 
 import (
 	"context"
+	"github.com/saiset-co/sai-auth/pkg/clientip"
+	"github.com/saiset-co/sai-auth/pkg/middleware"
+	"github.com/saiset-co/sai-auth/pkg/notifier"
 	"github.com/saiset-co/sai-auth/pkg/providers"
 	"log"
+	"time"
 
 	"github.com/saiset-co/sai-auth/internal/handlers"
 	"github.com/saiset-co/sai-auth/internal/repository"
@@ -28,78 +32,287 @@ func main() {
 	var authConfig types.SaiAuthConfig
 	config.GetAs("sai-auth", &authConfig)
 
+	var redisConfig types.RedisConfig
+	config.GetAs("redis", &redisConfig)
+
 	userRepo := storage.NewMongoUserRepository()
 	roleRepo := repository.NewMongoRoleRepository()
-	tokenRepo := storage.NewMongoTokenRepository()
+	if authConfig.SecretKey == "" {
+		log.Fatal("sai-auth.secret_key is required to hash tokens at rest")
+	}
+
+	tokenRepo := storage.NewMongoTokenRepository(authConfig.SecretKey)
+	securityEventRepo := storage.NewMongoSecurityEventRepository()
+	signingKeyRepo, err := storage.NewMongoSigningKeyRepository(authConfig.SecretKey)
+	if err != nil {
+		log.Fatal("Failed to create signing key repository:", err)
+	}
 
 	repos := &repository.Repositories{
-		User:  userRepo,
-		Role:  roleRepo,
-		Token: tokenRepo,
+		User:          userRepo,
+		Role:          roleRepo,
+		Group:         storage.NewMongoGroupRepository(),
+		Tenant:        storage.NewMongoTenantRepository(),
+		Token:         tokenRepo,
+		SecurityEvent: securityEventRepo,
+		SigningKey:    signingKeyRepo,
+		MFAChallenge:  storage.NewMongoMFAChallengeRepository(authConfig.SecretKey),
+		ActionToken:   storage.NewMongoActionTokenRepository(authConfig.SecretKey),
+		Invitation:    storage.NewMongoInvitationRepository(authConfig.SecretKey),
+	}
+
+	trustedProxies, err := clientip.NewResolver(authConfig.TrustedProxies)
+	if err != nil {
+		log.Fatal("Invalid sai-auth.trusted_proxies:", err)
 	}
+	handlers.SetTrustedProxies(trustedProxies)
 
-	authServiceURL := sai.Config().GetValue("auth_providers.sai-auth.params.auth_service_url", "http://localhost:8080").(string)
+	authServiceURL, ok := config.GetValue("auth_providers.sai-auth.params.auth_service_url", "http://localhost:8080").(string)
+	if !ok {
+		log.Fatal("Invalid auth_providers.sai-auth.params.auth_service_url: must be a string")
+	}
 
 	authProvider := providers.NewSaiAuthProvider(config.GetConfig().Name, authServiceURL)
+	if localVerification, _ := config.GetValue("auth_providers.sai-auth.params.local_verification", false).(bool); localVerification {
+		revocationCheckInterval := 30 * time.Second
+		if value, _ := config.GetValue("auth_providers.sai-auth.params.revocation_check_interval", "").(string); value != "" {
+			revocationCheckInterval, err = time.ParseDuration(value)
+			if err != nil {
+				log.Fatal("Invalid auth_providers.sai-auth.params.revocation_check_interval:", err)
+			}
+		}
+		issuer, _ := config.GetValue("auth_providers.sai-auth.params.issuer", "").(string)
+		if issuer == "" {
+			issuer = authConfig.JWT.Issuer
+		}
+		authProvider.EnableLocalVerification(issuer, revocationCheckInterval)
+	}
+	// sai.RegisterMiddleware panics in sai-service v1.1.3, so the provider
+	// runs the rate_limit_user middleware after verifying each request.
+	if enabled, _ := config.GetValue("middlewares.rate_limit_user.enabled", false).(bool); enabled {
+		authProvider.SetUserRateLimiter(middleware.NewRateLimitUserMiddleware(redisConfig))
+	}
+	authProvider.SetTrustedProxies(trustedProxies)
 	if err := sai.RegisterAuthProvider("sai-auth", authProvider); err != nil {
 		log.Fatal("Failed to register auth provider:", err)
 	}
-	permissionSvc := service.NewPermissionService(repos.Role)
-	authSvc := service.NewAuthService(repos.User, repos.Role, repos.Token, permissionSvc, &authConfig)
+
+	permissionSvc := service.NewPermissionService(repos.Role, repos.Group)
+	authSvc := service.NewAuthService(repos.User, repos.Role, repos.Token, repos.SecurityEvent, permissionSvc, &authConfig)
+	if redisConfig.Host != "" {
+		authSvc.SetRateLimiter(storage.NewRedisRateLimiter(redisConfig))
+	}
+	if authConfig.JWT.Enabled {
+		keySvc, err := service.NewKeyService(repos.SigningKey, &authConfig)
+		if err != nil {
+			log.Fatal("Failed to create key service:", err)
+		}
+		authSvc.SetKeyService(keySvc)
+	}
+	superUserIPs, err := clientip.ParseNetworks(authConfig.SuperUser.AllowedIPs)
+	if err != nil {
+		log.Fatal("Invalid sai-auth.super_user.allowed_ips:", err)
+	}
+	authSvc.SetSuperUserNetworks(superUserIPs)
+	lockoutSvc := service.NewLockoutService(repos.User, repos.SecurityEvent, &authConfig)
+	authSvc.SetLockoutService(lockoutSvc)
+	authSvc.SetMFAService(service.NewMFAService(repos.User, repos.MFAChallenge, &authConfig))
 	userSvc := service.NewUserService(repos.User, repos.Token, permissionSvc)
 	userSvc.SetAuthService(authSvc)
+	userSvc.SetLockoutService(lockoutSvc)
 	roleSvc := service.NewRoleService(repos.Role, repos.User, permissionSvc, userSvc)
+	groupSvc := service.NewGroupService(repos.Group, repos.Role, repos.User, permissionSvc, userSvc)
+	roleSvc.SetGroupService(groupSvc)
+	tenantSvc := service.NewTenantService(repos.Tenant, repos.User, userSvc)
+	authSvc.SetTenantService(tenantSvc)
+	roleSvc.SetTenantService(tenantSvc)
+
+	userNotifier, err := notifier.New(authConfig.Notifier)
+	if err != nil {
+		log.Fatal("Failed to create notifier:", err)
+	}
+	resetSvc := service.NewPasswordResetService(repos.User, repos.Token, repos.ActionToken, authSvc, userNotifier, &authConfig)
+	verifySvc, err := service.NewEmailVerificationService(repos.User, repos.Token, repos.ActionToken, authSvc, userNotifier, &authConfig)
+	if err != nil {
+		log.Fatal("Failed to create email verification service:", err)
+	}
+	userSvc.SetEmailVerificationService(verifySvc)
+	invitationSvc := service.NewInvitationService(repos.Invitation, repos.User, repos.Role, userSvc, authSvc, userNotifier, &authConfig)
+	invitationSvc.SetLockoutService(lockoutSvc)
+	registrationSvc, err := service.NewRegistrationService(repos.User, repos.Role, userSvc, lockoutSvc, &authConfig)
+	if err != nil {
+		log.Fatal("Failed to create registration service:", err)
+	}
+	bootstrapSvc, err := service.NewBootstrapService(repos.User, userSvc, roleSvc, authSvc, &authConfig)
+	if err != nil {
+		log.Fatal("Failed to create bootstrap service:", err)
+	}
+	roleExpirySvc := service.NewRoleExpiryService(repos.User, userSvc, &authConfig)
 
 	authHandler := handlers.NewAuthHandler(authSvc)
 	userHandler := handlers.NewUserHandler(userSvc)
 	roleHandler := handlers.NewRoleHandler(roleSvc)
+	groupHandler := handlers.NewGroupHandler(groupSvc)
+	tenantHandler := handlers.NewTenantHandler(tenantSvc)
+	resetHandler := handlers.NewPasswordResetHandler(resetSvc)
+	verifyHandler := handlers.NewEmailVerificationHandler(verifySvc)
+	registrationHandler := handlers.NewRegistrationHandler(registrationSvc)
+	invitationHandler := handlers.NewInvitationHandler(invitationSvc)
+	setupHandler := handlers.NewSetupHandler(bootstrapSvc)
+	admin := handlers.NewAdminGuard(authSvc)
 
 	router := sai.Router()
 
+	router.GET("/.well-known/jwks.json", authHandler.JWKS).
+		WithDoc("JWKS", "Public keys for verifying JWT access tokens", "Authentication", nil, nil).
+		WithoutMiddlewares("auth")
+
 	authGroup := router.Group("/api/v1/auth")
 	authGroup.POST("/login", authHandler.Login).
 		WithDoc("Login", "Authenticate user and get tokens", "Authentication", nil, nil).
 		WithoutMiddlewares("auth")
+	authGroup.POST("/setup", setupHandler.Setup).
+		WithDoc("Setup", "Create the first superuser with the one-time setup token", "Authentication", nil, nil).
+		WithoutMiddlewares("auth")
+	authGroup.POST("/register", registrationHandler.Register).
+		WithDoc("Register", "Create an account with the default roles", "Authentication", nil, nil).
+		WithoutMiddlewares("auth")
+	authGroup.POST("/invitations/accept", invitationHandler.Accept).
+		WithDoc("Accept Invitation", "Create an account from an invitation token", "Authentication", nil, nil).
+		WithoutMiddlewares("auth")
+	authGroup.POST("/change-password", authHandler.ChangePassword).
+		WithDoc("Change Password", "Change own password with the current one", "Authentication", nil, nil).
+		WithoutMiddlewares("auth")
+	authGroup.POST("/password/forgot", resetHandler.Forgot).
+		WithDoc("Forgot Password", "Send a password reset token to the user's email", "Authentication", nil, nil).
+		WithoutMiddlewares("auth")
+	authGroup.POST("/password/reset", resetHandler.Reset).
+		WithDoc("Reset Password", "Set a new password with a reset token", "Authentication", nil, nil).
+		WithoutMiddlewares("auth")
+	authGroup.POST("/email/verify", verifyHandler.Verify).
+		WithDoc("Verify Email", "Confirm an email address with a verification token", "Authentication", nil, nil).
+		WithoutMiddlewares("auth")
+	authGroup.POST("/email/resend", verifyHandler.Resend).
+		WithDoc("Resend Verification", "Send a new email verification token", "Authentication", nil, nil).
+		WithoutMiddlewares("auth")
+	authGroup.POST("/mfa/verify", authHandler.VerifyMFA).
+		WithDoc("Verify MFA", "Exchange an MFA challenge and code for tokens", "Authentication", nil, nil).
+		WithoutMiddlewares("auth")
+	authGroup.POST("/mfa/enroll", authHandler.EnrollMFA).
+		WithDoc("Enroll MFA", "Generate a TOTP secret for the current user", "Authentication", nil, nil).
+		WithoutMiddlewares("auth")
+	authGroup.POST("/mfa/confirm", authHandler.ConfirmMFA).
+		WithDoc("Confirm MFA", "Activate TOTP with a code and get recovery codes", "Authentication", nil, nil).
+		WithoutMiddlewares("auth")
+	authGroup.POST("/mfa/disable", authHandler.DisableMFA).
+		WithDoc("Disable MFA", "Turn off TOTP for the current user", "Authentication", nil, nil).
+		WithoutMiddlewares("auth")
 	authGroup.POST("/refresh", authHandler.RefreshToken).
 		WithDoc("Refresh Token", "Refresh access token", "Authentication", nil, nil).
 		WithoutMiddlewares("auth")
 	authGroup.POST("/logout", authHandler.Logout).
 		WithDoc("Logout", "Logout and invalidate tokens", "Authentication", nil, nil).
 		WithoutMiddlewares("auth")
+	authGroup.POST("/tenant/switch", authHandler.SwitchTenant).
+		WithDoc("Switch Tenant", "Issue tokens for another tenant of the current user", "Authentication", nil, nil).
+		WithoutMiddlewares("auth")
+	authGroup.GET("/sessions", authHandler.ListSessions).
+		WithDoc("List Sessions", "List active sessions of the current user", "Authentication", nil, nil).
+		WithoutMiddlewares("auth")
+	authGroup.DELETE("/sessions", authHandler.RevokeSessions).
+		WithDoc("Revoke Sessions", "Revoke one or all sessions of the current user", "Authentication", nil, nil).
+		WithoutMiddlewares("auth")
+	authGroup.POST("/tokens/migrate", admin.Require(authHandler.MigrateTokenHashes)).
+		WithDoc("Migrate Tokens", "Replace plaintext tokens at rest with keyed hashes", "Authentication", nil, nil).
+		WithoutMiddlewares("auth")
 	authGroup.GET("/me", authHandler.GetUserInfo).
 		WithDoc("Get User Info", "Get current user information", "Authentication", nil, nil)
 	authGroup.POST("/verify", authHandler.VerifyToken).
 		WithDoc("Verify Token", "Verify token and permissions", "Authentication", nil, nil).
 		WithoutMiddlewares("auth")
+	authGroup.POST("/introspect", authHandler.Introspect).
+		WithDoc("Introspect Token", "Check whether the session behind an access token is active", "Authentication", nil, nil).
+		WithoutMiddlewares("auth")
 
-	userGroup := router.Group("/api/v1/users")
-	userGroup.GET("/", userHandler.Get).
+	userGroup := router.Group("/api/v1/users").WithoutMiddlewares("auth")
+	userGroup.GET("/", admin.Require(userHandler.Get)).
 		WithDoc("Get Users", "Get users list", "Users", nil, nil)
-	userGroup.POST("/", userHandler.Create).
+	userGroup.POST("/", admin.Require(userHandler.Create)).
 		WithDoc("Create User", "Create new user", "Users", nil, nil)
-	userGroup.PUT("/", userHandler.Update).
+	userGroup.PUT("/", admin.Require(userHandler.Update)).
 		WithDoc("Update User", "Update user", "Users", nil, nil)
-	userGroup.DELETE("/", userHandler.Delete).
+	userGroup.DELETE("/", admin.Require(userHandler.Delete)).
 		WithDoc("Delete User", "Delete user", "Users", nil, nil)
-	userGroup.POST("/assign-roles", userHandler.AssignRoles).
+	userGroup.POST("/assign-roles", admin.Require(userHandler.AssignRoles)).
 		WithDoc("Assign Roles", "Assign roles to user", "Users", nil, nil)
-	userGroup.POST("/remove-roles", userHandler.RemoveRoles).
+	userGroup.POST("/remove-roles", admin.Require(userHandler.RemoveRoles)).
 		WithDoc("Remove Roles", "Remove roles from user", "Users", nil, nil)
+	userGroup.POST("/approve", admin.Require(registrationHandler.Approve)).
+		WithDoc("Approve User", "Activate a pending self-registered user", "Users", nil, nil)
+	userGroup.POST("/reject", admin.Require(registrationHandler.Reject)).
+		WithDoc("Reject User", "Delete a pending self-registered user", "Users", nil, nil)
+	userGroup.GET("/superusers", admin.Require(userHandler.SuperUsers)).
+		WithDoc("List Superusers", "List current superusers (superusers only)", "Users", nil, nil)
+	userGroup.POST("/superusers/promote", admin.Require(userHandler.PromoteSuperUser)).
+		WithDoc("Promote Superuser", "Grant superuser status to a user (superusers only)", "Users", nil, nil)
+	userGroup.POST("/superusers/demote", admin.Require(userHandler.DemoteSuperUser)).
+		WithDoc("Demote Superuser", "Revoke superuser status, keeping at least one (superusers only)", "Users", nil, nil)
+	userGroup.POST("/unlock", admin.Require(userHandler.Unlock)).
+		WithDoc("Unlock User", "Clear failed login counters and lock", "Users", nil, nil)
+	userGroup.GET("/login-failures", admin.Require(userHandler.LoginFailures)).
+		WithDoc("Login Failures", "Failed login history by user or IP", "Users", nil, nil)
+
+	invitationGroup := router.Group("/api/v1/invitations").WithoutMiddlewares("auth")
+	invitationGroup.GET("/", admin.Require(invitationHandler.List)).
+		WithDoc("List Invitations", "List invitations by status or email", "Invitations", nil, nil)
+	invitationGroup.POST("/", admin.Require(invitationHandler.Create)).
+		WithDoc("Create Invitation", "Invite a user by email with preset roles", "Invitations", nil, nil)
+	invitationGroup.POST("/revoke", admin.Require(invitationHandler.Revoke)).
+		WithDoc("Revoke Invitation", "Revoke a pending invitation", "Invitations", nil, nil)
 
-	roleGroup := router.Group("/api/v1/roles")
-	roleGroup.GET("/", roleHandler.Get).
+	groupGroup := router.Group("/api/v1/groups").WithoutMiddlewares("auth")
+	groupGroup.GET("/", admin.Require(groupHandler.List)).
+		WithDoc("List Groups", "List groups by member, role or parent group", "Groups", nil, nil)
+	groupGroup.POST("/", admin.Require(groupHandler.Create)).
+		WithDoc("Create Group", "Create a group with roles, members and parent groups", "Groups", nil, nil)
+	groupGroup.PUT("/", admin.Require(groupHandler.Update)).
+		WithDoc("Update Group", "Update a group's name, roles or parent groups", "Groups", nil, nil)
+	groupGroup.DELETE("/", admin.Require(groupHandler.Delete)).
+		WithDoc("Delete Group", "Delete a group and unlink its nested groups", "Groups", nil, nil)
+	groupGroup.POST("/add-members", admin.Require(groupHandler.AddMembers)).
+		WithDoc("Add Group Members", "Add users to a group", "Groups", nil, nil)
+	groupGroup.POST("/remove-members", admin.Require(groupHandler.RemoveMembers)).
+		WithDoc("Remove Group Members", "Remove users from a group", "Groups", nil, nil)
+
+	tenantGroup := router.Group("/api/v1/tenants").WithoutMiddlewares("auth")
+	tenantGroup.GET("/", admin.Require(tenantHandler.List)).
+		WithDoc("List Tenants", "List tenants", "Tenants", nil, nil)
+	tenantGroup.POST("/", admin.Require(tenantHandler.Create)).
+		WithDoc("Create Tenant", "Create a tenant", "Tenants", nil, nil)
+	tenantGroup.PUT("/", admin.Require(tenantHandler.Update)).
+		WithDoc("Update Tenant", "Rename, activate or deactivate a tenant", "Tenants", nil, nil)
+	tenantGroup.POST("/add-members", admin.Require(tenantHandler.AddMembers)).
+		WithDoc("Add Tenant Members", "Add users to a tenant", "Tenants", nil, nil)
+	tenantGroup.POST("/remove-members", admin.Require(tenantHandler.RemoveMembers)).
+		WithDoc("Remove Tenant Members", "Remove users from a tenant and end their sessions in it", "Tenants", nil, nil)
+
+	roleGroup := router.Group("/api/v1/roles").WithoutMiddlewares("auth")
+	roleGroup.GET("/", admin.Require(roleHandler.Get)).
 		WithDoc("Get Roles", "Get roles list", "Roles", nil, nil)
-	roleGroup.POST("/", roleHandler.Create).
+	roleGroup.POST("/", admin.Require(roleHandler.Create)).
 		WithDoc("Create Role", "Create new role", "Roles", nil, nil)
-	roleGroup.PUT("/", roleHandler.Update).
+	roleGroup.PUT("/", admin.Require(roleHandler.Update)).
 		WithDoc("Update Role", "Update role", "Roles", nil, nil)
-	roleGroup.DELETE("/", roleHandler.Delete).
+	roleGroup.DELETE("/", admin.Require(roleHandler.Delete)).
 		WithDoc("Delete Role", "Delete role", "Roles", nil, nil)
-	roleGroup.GET("/permissions", roleHandler.GetPermissions).
+	roleGroup.GET("/permissions", admin.Require(roleHandler.GetPermissions)).
 		WithDoc("Get Role Permissions", "Get compiled role permissions", "Roles", nil, nil)
-	roleGroup.POST("/permissions", authHandler.TestPermissions).
+	roleGroup.POST("/permissions", admin.Require(authHandler.TestPermissions)).
 		WithDoc("Test Permissions", "Test user permissions", "Roles", nil, nil)
 
+	bootstrapSvc.Start()
+	roleExpirySvc.Start()
+
 	if err := srv.Start(); err != nil {
 		log.Fatal("Failed to start service:", err)
 	}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"github.com/valyala/fasthttp"
+	"go.uber.org/zap"
+
+	"github.com/saiset-co/sai-auth/internal/models"
+	"github.com/saiset-co/sai-auth/internal/service"
+	"github.com/saiset-co/sai-auth/pkg/middleware"
+	"github.com/saiset-co/sai-service/sai"
+	saiTypes "github.com/saiset-co/sai-service/types"
+)
+
+// AdminGuard checks requests to the admin API against the caller's own
+// permissions in the sai-auth namespace, independent of the auth provider
+// configured for the service.
+type AdminGuard struct {
+	authService *service.AuthService
+}
+
+func NewAdminGuard(authService *service.AuthService) *AdminGuard {
+	return &AdminGuard{
+		authService: authService,
+	}
+}
+
+func (g *AdminGuard) Require(next func(*saiTypes.RequestCtx)) func(*saiTypes.RequestCtx) {
+	return func(ctx *saiTypes.RequestCtx) {
+		token := extractToken(ctx)
+		if token == "" {
+			ctx.Error(errors.New("Authorization token required"), fasthttp.StatusUnauthorized)
+			return
+		}
+
+		result, err := g.authService.VerifyToken(ctx, &models.VerifyRequest{
+			Token:         token,
+			Microservice:  service.AdminMicroservice,
+			Method:        string(ctx.Method()),
+			Path:          string(ctx.Path()),
+			RequestParams: requestParams(ctx),
+			ClientIP:      clientIP(ctx),
+		})
+		if err != nil {
+			sai.Logger().Error("Admin permission check failed", zap.Error(err))
+			ctx.Error(err, fasthttp.StatusInternalServerError)
+			return
+		}
+
+		switch {
+		case result.RateLimited:
+			ctx.Error(errors.New(result.Reason), fasthttp.StatusTooManyRequests)
+			middleware.SetRateLimitHeaders(ctx, result.RateLimit)
+			return
+		case !result.Allowed && result.UserID == "":
+			ctx.Error(errors.New(result.Reason), fasthttp.StatusUnauthorized)
+			return
+		case !result.Allowed:
+			sai.Logger().Warn("Admin access denied",
+				zap.String("user_id", result.UserID),
+				zap.ByteString("method", ctx.Method()),
+				zap.ByteString("path", ctx.Path()),
+				zap.String("reason", result.Reason))
+			ctx.Error(errors.New(result.Reason), fasthttp.StatusForbidden)
+			return
+		}
+
+		ctx.SetUserValue("user_id", result.UserID)
+		ctx.SetUserValue("tenant_id", result.TenantID)
+		middleware.SetRateLimitHeaders(ctx, result.RateLimit)
+
+		next(ctx)
+	}
+}
+
+func requestParams(ctx *saiTypes.RequestCtx) map[string]interface{} {
+	params := make(map[string]interface{})
+
+	if body := ctx.PostBody(); len(body) > 0 {
+		json.Unmarshal(body, &params)
+	}
+
+	ctx.QueryArgs().VisitAll(func(key, value []byte) {
+		if _, exists := params[string(key)]; !exists {
+			params[string(key)] = string(value)
+		}
+	})
+
+	return params
+}
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"math"
+	"strconv"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -9,6 +11,9 @@ import (
 
 	"github.com/saiset-co/sai-auth/internal/models"
 	"github.com/saiset-co/sai-auth/internal/service"
+	"github.com/saiset-co/sai-auth/pkg/clientip"
+	"github.com/saiset-co/sai-auth/pkg/middleware"
+	"github.com/saiset-co/sai-auth/types"
 	"github.com/saiset-co/sai-service/sai"
 	saiTypes "github.com/saiset-co/sai-service/types"
 )
@@ -35,8 +40,33 @@ func (h *AuthHandler) Login(ctx *saiTypes.RequestCtx) {
 		return
 	}
 
+	req.UserAgent = string(ctx.UserAgent())
+	req.IP = clientIP(ctx)
+
 	response, err := h.authService.Login(ctx, &req)
 	if err != nil {
+		var throttled *service.LoginThrottledError
+		if errors.As(err, &throttled) {
+			if throttled.Locked {
+				ctx.Error(err, fasthttp.StatusLocked)
+			} else {
+				ctx.Error(err, fasthttp.StatusTooManyRequests)
+			}
+			ctx.Response.Header.Set("Retry-After", strconv.FormatInt(int64(math.Ceil(throttled.RetryAfter.Seconds())), 10))
+			return
+		}
+
+		if err.Error() == "password expired" {
+			ctx.Error(errors.New("password expired, change it via /api/v1/auth/change-password"), fasthttp.StatusForbidden)
+			return
+		}
+
+		if err.Error() == "email not verified" || err.Error() == "registration is pending approval" ||
+			err.Error() == "superuser login is not allowed from this IP" || isTenantError(err) {
+			ctx.Error(err, fasthttp.StatusForbidden)
+			return
+		}
+
 		ctx.Error(err, fasthttp.StatusUnauthorized)
 		return
 	}
@@ -44,6 +74,213 @@ func (h *AuthHandler) Login(ctx *saiTypes.RequestCtx) {
 	ctx.SuccessJSON(response)
 }
 
+func (h *AuthHandler) ChangePassword(ctx *saiTypes.RequestCtx) {
+	var req models.ChangePasswordRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.Error(err, fasthttp.StatusBadRequest)
+		return
+	}
+
+	token := extractToken(ctx)
+	if token == "" && req.User == "" {
+		ctx.Error(errors.New("Authorization token or user is required"), fasthttp.StatusBadRequest)
+		return
+	}
+
+	if req.CurrentPassword == "" || req.NewPassword == "" {
+		ctx.Error(errors.New("Current and new password are required"), fasthttp.StatusBadRequest)
+		return
+	}
+
+	req.UserAgent = string(ctx.UserAgent())
+	req.IP = clientIP(ctx)
+
+	err := h.authService.ChangePassword(ctx, token, &req)
+	if err != nil {
+		var throttled *service.LoginThrottledError
+		var policyErr *service.PasswordPolicyError
+		switch {
+		case errors.As(err, &throttled):
+			if throttled.Locked {
+				ctx.Error(err, fasthttp.StatusLocked)
+			} else {
+				ctx.Error(err, fasthttp.StatusTooManyRequests)
+			}
+			ctx.Response.Header.Set("Retry-After", strconv.FormatInt(int64(math.Ceil(throttled.RetryAfter.Seconds())), 10))
+		case errors.As(err, &policyErr):
+			ctx.Error(err, fasthttp.StatusBadRequest)
+		case err.Error() == "superuser access is not allowed from this IP":
+			ctx.Error(err, fasthttp.StatusForbidden)
+		default:
+			ctx.Error(err, fasthttp.StatusUnauthorized)
+		}
+		return
+	}
+
+	ctx.SuccessJSON(map[string]string{"message": "Password changed"})
+}
+
+func (h *AuthHandler) VerifyMFA(ctx *saiTypes.RequestCtx) {
+	var req models.MFAVerifyRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.Error(err, fasthttp.StatusBadRequest)
+		return
+	}
+
+	if req.MFAToken == "" || req.Code == "" {
+		ctx.Error(errors.New("MFA token and code are required"), fasthttp.StatusBadRequest)
+		return
+	}
+
+	req.UserAgent = string(ctx.UserAgent())
+	req.IP = clientIP(ctx)
+
+	response, err := h.authService.VerifyMFA(ctx, &req)
+	if err != nil {
+		var throttled *service.LoginThrottledError
+		if errors.As(err, &throttled) {
+			if throttled.Locked {
+				ctx.Error(err, fasthttp.StatusLocked)
+			} else {
+				ctx.Error(err, fasthttp.StatusTooManyRequests)
+			}
+			ctx.Response.Header.Set("Retry-After", strconv.FormatInt(int64(math.Ceil(throttled.RetryAfter.Seconds())), 10))
+			return
+		}
+
+		if err.Error() == "superuser login is not allowed from this IP" || isTenantError(err) {
+			ctx.Error(err, fasthttp.StatusForbidden)
+		} else {
+			ctx.Error(err, fasthttp.StatusUnauthorized)
+		}
+		return
+	}
+
+	ctx.SuccessJSON(response)
+}
+
+// SwitchTenant moves the caller's session to another of their tenants.
+func (h *AuthHandler) SwitchTenant(ctx *saiTypes.RequestCtx) {
+	token := extractToken(ctx)
+	if token == "" {
+		ctx.Error(errors.New("Authorization token required"), fasthttp.StatusUnauthorized)
+		return
+	}
+
+	var req models.SwitchTenantRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.Error(err, fasthttp.StatusBadRequest)
+		return
+	}
+
+	response, err := h.authService.SwitchTenant(ctx, token, req.TenantID, clientIP(ctx), string(ctx.UserAgent()))
+	if err != nil {
+		switch {
+		case err.Error() == "tenant_id is required":
+			ctx.Error(err, fasthttp.StatusBadRequest)
+		case isTenantError(err), err.Error() == "superuser access is not allowed from this IP":
+			ctx.Error(err, fasthttp.StatusForbidden)
+		default:
+			ctx.Error(err, fasthttp.StatusUnauthorized)
+		}
+		return
+	}
+
+	ctx.SuccessJSON(response)
+}
+
+func isTenantError(err error) bool {
+	switch err.Error() {
+	case "user is not a member of this tenant", "tenant not found", "tenant is inactive":
+		return true
+	}
+	return false
+}
+
+func (h *AuthHandler) EnrollMFA(ctx *saiTypes.RequestCtx) {
+	token := extractToken(ctx)
+	if token == "" {
+		ctx.Error(errors.New("Authorization token required"), fasthttp.StatusUnauthorized)
+		return
+	}
+
+	response, err := h.authService.EnrollMFA(ctx, token, clientIP(ctx), string(ctx.UserAgent()))
+	if err != nil {
+		h.mfaError(ctx, err)
+		return
+	}
+
+	ctx.SuccessJSON(response)
+}
+
+func (h *AuthHandler) ConfirmMFA(ctx *saiTypes.RequestCtx) {
+	token := extractToken(ctx)
+	if token == "" {
+		ctx.Error(errors.New("Authorization token required"), fasthttp.StatusUnauthorized)
+		return
+	}
+
+	var req models.MFACodeRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.Error(err, fasthttp.StatusBadRequest)
+		return
+	}
+
+	if req.Code == "" {
+		ctx.Error(errors.New("Code is required"), fasthttp.StatusBadRequest)
+		return
+	}
+
+	response, err := h.authService.ConfirmMFA(ctx, token, req.Code, clientIP(ctx), string(ctx.UserAgent()))
+	if err != nil {
+		h.mfaError(ctx, err)
+		return
+	}
+
+	ctx.SuccessJSON(response)
+}
+
+func (h *AuthHandler) DisableMFA(ctx *saiTypes.RequestCtx) {
+	token := extractToken(ctx)
+	if token == "" {
+		ctx.Error(errors.New("Authorization token required"), fasthttp.StatusUnauthorized)
+		return
+	}
+
+	var req models.MFACodeRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.Error(err, fasthttp.StatusBadRequest)
+		return
+	}
+
+	if req.Code == "" {
+		ctx.Error(errors.New("Code is required"), fasthttp.StatusBadRequest)
+		return
+	}
+
+	if err := h.authService.DisableMFA(ctx, token, req.Code, clientIP(ctx), string(ctx.UserAgent())); err != nil {
+		h.mfaError(ctx, err)
+		return
+	}
+
+	ctx.SuccessJSON(map[string]string{"message": "MFA disabled"})
+}
+
+func (h *AuthHandler) mfaError(ctx *saiTypes.RequestCtx, err error) {
+	switch err.Error() {
+	case "invalid token", "invalid mfa code":
+		ctx.Error(err, fasthttp.StatusUnauthorized)
+	case "mfa already enabled":
+		ctx.Error(err, fasthttp.StatusConflict)
+	case "superuser access is not allowed from this IP":
+		ctx.Error(err, fasthttp.StatusForbidden)
+	case "mfa enrollment not started", "mfa is not enabled":
+		ctx.Error(err, fasthttp.StatusBadRequest)
+	default:
+		ctx.Error(err, fasthttp.StatusInternalServerError)
+	}
+}
+
 func (h *AuthHandler) RefreshToken(ctx *saiTypes.RequestCtx) {
 	var req models.RefreshTokenRequest
 	if err := ctx.ReadJSON(&req); err != nil {
@@ -56,9 +293,16 @@ func (h *AuthHandler) RefreshToken(ctx *saiTypes.RequestCtx) {
 		return
 	}
 
+	req.UserAgent = string(ctx.UserAgent())
+	req.IP = clientIP(ctx)
+
 	response, err := h.authService.RefreshToken(ctx, &req)
 	if err != nil {
-		ctx.Error(err, fasthttp.StatusUnauthorized)
+		if err.Error() == "superuser access is not allowed from this IP" {
+			ctx.Error(err, fasthttp.StatusForbidden)
+		} else {
+			ctx.Error(err, fasthttp.StatusUnauthorized)
+		}
 		return
 	}
 
@@ -66,7 +310,7 @@ func (h *AuthHandler) RefreshToken(ctx *saiTypes.RequestCtx) {
 }
 
 func (h *AuthHandler) Logout(ctx *saiTypes.RequestCtx) {
-	token := h.extractToken(ctx)
+	token := extractToken(ctx)
 	if token == "" {
 		ctx.Error(errors.New("Authorization token required"), fasthttp.StatusUnauthorized)
 		return
@@ -81,16 +325,84 @@ func (h *AuthHandler) Logout(ctx *saiTypes.RequestCtx) {
 	ctx.SuccessJSON(map[string]string{"message": "Logged out successfully"})
 }
 
+func (h *AuthHandler) ListSessions(ctx *saiTypes.RequestCtx) {
+	token := extractToken(ctx)
+	if token == "" {
+		ctx.Error(errors.New("Authorization token required"), fasthttp.StatusUnauthorized)
+		return
+	}
+
+	sessions, err := h.authService.ListSessions(ctx, token, clientIP(ctx), string(ctx.UserAgent()))
+	if err != nil {
+		if err.Error() == "superuser access is not allowed from this IP" {
+			ctx.Error(err, fasthttp.StatusForbidden)
+		} else {
+			ctx.Error(err, fasthttp.StatusUnauthorized)
+		}
+		return
+	}
+
+	ctx.SuccessJSON(types.Response{Data: sessions})
+}
+
+func (h *AuthHandler) RevokeSessions(ctx *saiTypes.RequestCtx) {
+	token := extractToken(ctx)
+	if token == "" {
+		ctx.Error(errors.New("Authorization token required"), fasthttp.StatusUnauthorized)
+		return
+	}
+
+	var req models.RevokeSessionsRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.Error(err, fasthttp.StatusBadRequest)
+		return
+	}
+
+	if req.SessionID == "" && !req.All {
+		ctx.Error(errors.New("session_id or all is required"), fasthttp.StatusBadRequest)
+		return
+	}
+
+	revoked, err := h.authService.RevokeSessions(ctx, token, clientIP(ctx), string(ctx.UserAgent()), &req)
+	if err != nil {
+		switch err.Error() {
+		case "session not found":
+			ctx.Error(err, fasthttp.StatusNotFound)
+		case "superuser access is not allowed from this IP":
+			ctx.Error(err, fasthttp.StatusForbidden)
+		default:
+			ctx.Error(err, fasthttp.StatusUnauthorized)
+		}
+		return
+	}
+
+	ctx.SuccessJSON(types.Response{Deleted: revoked})
+}
+
+func (h *AuthHandler) MigrateTokenHashes(ctx *saiTypes.RequestCtx) {
+	migrated, err := h.authService.MigrateTokenHashes(ctx)
+	if err != nil {
+		ctx.Error(err, fasthttp.StatusInternalServerError)
+		return
+	}
+
+	ctx.SuccessJSON(types.Response{Updated: migrated})
+}
+
 func (h *AuthHandler) GetUserInfo(ctx *saiTypes.RequestCtx) {
-	token := h.extractToken(ctx)
+	token := extractToken(ctx)
 	if token == "" {
 		ctx.Error(errors.New("Authorization token required"), fasthttp.StatusUnauthorized)
 		return
 	}
 
-	response, err := h.authService.GetUserInfo(ctx, token)
+	response, err := h.authService.GetUserInfo(ctx, token, clientIP(ctx), string(ctx.UserAgent()))
 	if err != nil {
-		ctx.Error(err, fasthttp.StatusUnauthorized)
+		if err.Error() == "superuser access is not allowed from this IP" {
+			ctx.Error(err, fasthttp.StatusForbidden)
+		} else {
+			ctx.Error(err, fasthttp.StatusUnauthorized)
+		}
 		return
 	}
 
@@ -116,6 +428,19 @@ func (h *AuthHandler) VerifyToken(ctx *saiTypes.RequestCtx) {
 		return
 	}
 
+	if response.RateLimited {
+		sai.Logger().Warn("Auth verify rate limited",
+			zap.String("user_id", response.UserID),
+			zap.String("microservice", req.Microservice),
+			zap.String("method", req.Method),
+			zap.String("path", req.Path),
+			zap.String("reason", response.Reason))
+		ctx.SuccessJSON(response)
+		ctx.SetStatusCode(fasthttp.StatusTooManyRequests)
+		middleware.SetRateLimitHeaders(ctx, response.RateLimit)
+		return
+	}
+
 	if !response.Allowed {
 		sai.Logger().Warn("Auth verify denied",
 			zap.String("microservice", req.Microservice),
@@ -127,9 +452,46 @@ func (h *AuthHandler) VerifyToken(ctx *saiTypes.RequestCtx) {
 		return
 	}
 
+	middleware.SetRateLimitHeaders(ctx, response.RateLimit)
+	ctx.SuccessJSON(response)
+}
+
+func (h *AuthHandler) Introspect(ctx *saiTypes.RequestCtx) {
+	var req models.IntrospectRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.Error(err, fasthttp.StatusBadRequest)
+		return
+	}
+
+	if req.Token == "" {
+		ctx.Error(errors.New("Token is required"), fasthttp.StatusBadRequest)
+		return
+	}
+
+	response, err := h.authService.IntrospectToken(ctx, req.Token)
+	if err != nil {
+		ctx.Error(err, fasthttp.StatusInternalServerError)
+		return
+	}
+
 	ctx.SuccessJSON(response)
 }
 
+func (h *AuthHandler) JWKS(ctx *saiTypes.RequestCtx) {
+	set, err := h.authService.JWKS(ctx)
+	if err != nil {
+		if err.Error() == "jwt access tokens are disabled" {
+			ctx.Error(err, fasthttp.StatusNotFound)
+		} else {
+			ctx.Error(err, fasthttp.StatusInternalServerError)
+		}
+		return
+	}
+
+	ctx.Response.Header.Set("Cache-Control", "public, max-age=300")
+	ctx.SuccessJSON(set)
+}
+
 func (h *AuthHandler) TestPermissions(ctx *saiTypes.RequestCtx) {
 	var req models.TestPermissionsRequest
 	if err := ctx.ReadJSON(&req); err != nil {
@@ -137,21 +499,43 @@ func (h *AuthHandler) TestPermissions(ctx *saiTypes.RequestCtx) {
 		return
 	}
 
-	if req.UserID == "" || req.Microservice == "" || req.Method == "" || req.Path == "" {
-		ctx.Error(errors.New("UserID, microservice, method, and path are required"), fasthttp.StatusBadRequest)
+	if req.UserID == "" {
+		ctx.Error(errors.New("UserID is required"), fasthttp.StatusBadRequest)
+		return
+	}
+
+	hasRequest := req.Microservice != "" && req.Method != "" && req.Path != ""
+	if !hasRequest && len(req.GrantRoles) == 0 {
+		ctx.Error(errors.New("Microservice, method, and path or grant_roles are required"), fasthttp.StatusBadRequest)
 		return
 	}
 
 	response, err := h.authService.TestPermissions(ctx, &req)
 	if err != nil {
-		ctx.Error(err, fasthttp.StatusInternalServerError)
+		if err.Error() == "user not found" || err.Error() == "target user not found" {
+			ctx.Error(err, fasthttp.StatusNotFound)
+		} else {
+			ctx.Error(err, fasthttp.StatusInternalServerError)
+		}
 		return
 	}
 
 	ctx.SuccessJSON(response)
 }
 
-func (h *AuthHandler) extractToken(ctx *saiTypes.RequestCtx) string {
+// trustedProxies resolves client addresses behind reverse proxies. It is set
+// once at startup; without it the connection address is used.
+var trustedProxies *clientip.Resolver
+
+func SetTrustedProxies(resolver *clientip.Resolver) {
+	trustedProxies = resolver
+}
+
+func clientIP(ctx *saiTypes.RequestCtx) string {
+	return trustedProxies.ClientIP(ctx.RequestCtx).String()
+}
+
+func extractToken(ctx *saiTypes.RequestCtx) string {
 	authHeader := string(ctx.Request.Header.Peek("Authorization"))
 	if strings.HasPrefix(authHeader, "Token ") {
 		return strings.TrimPrefix(authHeader, "Token ")
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/valyala/fasthttp"
+
+	"github.com/saiset-co/sai-auth/internal/models"
+	"github.com/saiset-co/sai-auth/internal/service"
+	saiTypes "github.com/saiset-co/sai-service/types"
+)
+
+type EmailVerificationHandler struct {
+	verifyService *service.EmailVerificationService
+}
+
+func NewEmailVerificationHandler(verifyService *service.EmailVerificationService) *EmailVerificationHandler {
+	return &EmailVerificationHandler{
+		verifyService: verifyService,
+	}
+}
+
+func (h *EmailVerificationHandler) Verify(ctx *saiTypes.RequestCtx) {
+	var req models.VerifyEmailRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.Error(err, fasthttp.StatusBadRequest)
+		return
+	}
+
+	if req.Token == "" {
+		ctx.Error(errors.New("Token is required"), fasthttp.StatusBadRequest)
+		return
+	}
+
+	err := h.verifyService.Verify(ctx, &req)
+	if err != nil {
+		if err.Error() == "invalid or expired verification token" {
+			ctx.Error(err, fasthttp.StatusBadRequest)
+		} else {
+			ctx.Error(err, fasthttp.StatusInternalServerError)
+		}
+		return
+	}
+
+	ctx.SuccessJSON(map[string]string{"message": "Email verified"})
+}
+
+func (h *EmailVerificationHandler) Resend(ctx *saiTypes.RequestCtx) {
+	var req models.ResendVerificationRequest
+	if body := ctx.PostBody(); len(body) > 0 {
+		if err := ctx.ReadJSON(&req); err != nil {
+			ctx.Error(err, fasthttp.StatusBadRequest)
+			return
+		}
+	}
+
+	token := extractToken(ctx)
+	if token == "" && req.User == "" {
+		ctx.Error(errors.New("Authorization token or user is required"), fasthttp.StatusBadRequest)
+		return
+	}
+
+	req.UserAgent = string(ctx.UserAgent())
+	req.IP = clientIP(ctx)
+
+	err := h.verifyService.Resend(ctx, token, &req)
+	if err != nil {
+		var throttled *service.VerificationThrottledError
+		switch {
+		case errors.As(err, &throttled):
+			ctx.Error(err, fasthttp.StatusTooManyRequests)
+			ctx.Response.Header.Set("Retry-After", strconv.FormatInt(int64(math.Ceil(throttled.RetryAfter.Seconds())), 10))
+		case err.Error() == "invalid token":
+			ctx.Error(err, fasthttp.StatusUnauthorized)
+		case err.Error() == "superuser access is not allowed from this IP":
+			ctx.Error(err, fasthttp.StatusForbidden)
+		case err.Error() == "email already verified", err.Error() == "email verification is disabled":
+			ctx.Error(err, fasthttp.StatusBadRequest)
+		default:
+			ctx.Error(err, fasthttp.StatusInternalServerError)
+		}
+		return
+	}
+
+	ctx.SuccessJSON(map[string]string{"message": "If the account needs verification, an email has been sent"})
+}
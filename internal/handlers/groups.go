@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/valyala/fasthttp"
+
+	"github.com/saiset-co/sai-auth/internal/models"
+	"github.com/saiset-co/sai-auth/internal/service"
+	"github.com/saiset-co/sai-auth/types"
+	saiTypes "github.com/saiset-co/sai-service/types"
+)
+
+type GroupHandler struct {
+	groupService *service.GroupService
+}
+
+func NewGroupHandler(groupService *service.GroupService) *GroupHandler {
+	return &GroupHandler{
+		groupService: groupService,
+	}
+}
+
+func (h *GroupHandler) Create(ctx *saiTypes.RequestCtx) {
+	var req models.CreateGroupRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.Error(err, fasthttp.StatusBadRequest)
+		return
+	}
+
+	if req.Name == "" {
+		ctx.Error(errors.New("Group name is required"), fasthttp.StatusBadRequest)
+		return
+	}
+
+	group, err := h.groupService.Create(ctx, &req)
+	if err != nil {
+		h.groupError(ctx, err)
+		return
+	}
+
+	response := types.Response{
+		Data:    group,
+		Created: 1,
+	}
+
+	ctx.SuccessJSON(response)
+}
+
+func (h *GroupHandler) List(ctx *saiTypes.RequestCtx) {
+	if groupID := string(ctx.QueryArgs().Peek("group_id")); groupID != "" {
+		group, err := h.groupService.GetByID(ctx, groupID)
+		if err != nil {
+			ctx.Error(err, fasthttp.StatusNotFound)
+			return
+		}
+
+		ctx.SuccessJSON([]*models.Group{group})
+		return
+	}
+
+	page, _ := strconv.Atoi(string(ctx.QueryArgs().Peek("page")))
+	limit, _ := strconv.Atoi(string(ctx.QueryArgs().Peek("limit")))
+
+	var active *bool
+	if activeStr := string(ctx.QueryArgs().Peek("active")); activeStr != "" {
+		if activeBool, err := strconv.ParseBool(activeStr); err == nil {
+			active = &activeBool
+		}
+	}
+
+	filter := &types.GroupFilterRequest{
+		PaginationRequest: types.PaginationRequest{
+			Page:   page,
+			Limit:  limit,
+			Search: string(ctx.QueryArgs().Peek("search")),
+		},
+		Active: active,
+		Member: string(ctx.QueryArgs().Peek("member")),
+		Role:   string(ctx.QueryArgs().Peek("role")),
+		Parent: string(ctx.QueryArgs().Peek("parent")),
+	}
+
+	groups, total, err := h.groupService.List(ctx, filter)
+	if err != nil {
+		ctx.Error(err, fasthttp.StatusInternalServerError)
+		return
+	}
+
+	response := types.PaginatedResponse{
+		Data:       groups,
+		Page:       filter.Page,
+		Limit:      filter.Limit,
+		Total:      total,
+		TotalPages: int(math.Ceil(float64(total) / float64(filter.Limit))),
+	}
+
+	ctx.SuccessJSON(response)
+}
+
+func (h *GroupHandler) Update(ctx *saiTypes.RequestCtx) {
+	groupID := string(ctx.QueryArgs().Peek("group_id"))
+	if groupID == "" {
+		ctx.Error(errors.New("group_id is required"), fasthttp.StatusBadRequest)
+		return
+	}
+
+	var req models.UpdateGroupRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.Error(err, fasthttp.StatusBadRequest)
+		return
+	}
+
+	if err := h.groupService.Update(ctx, groupID, &req); err != nil {
+		h.groupError(ctx, err)
+		return
+	}
+
+	response := types.Response{
+		Updated: 1,
+	}
+
+	ctx.SuccessJSON(response)
+}
+
+func (h *GroupHandler) Delete(ctx *saiTypes.RequestCtx) {
+	groupID := string(ctx.QueryArgs().Peek("group_id"))
+	if groupID == "" {
+		ctx.Error(errors.New("group_id is required"), fasthttp.StatusBadRequest)
+		return
+	}
+
+	if err := h.groupService.Delete(ctx, groupID); err != nil {
+		h.groupError(ctx, err)
+		return
+	}
+
+	response := types.Response{
+		Deleted: 1,
+	}
+
+	ctx.SuccessJSON(response)
+}
+
+func (h *GroupHandler) AddMembers(ctx *saiTypes.RequestCtx) {
+	h.changeMembers(ctx, h.groupService.AddMembers)
+}
+
+func (h *GroupHandler) RemoveMembers(ctx *saiTypes.RequestCtx) {
+	h.changeMembers(ctx, h.groupService.RemoveMembers)
+}
+
+func (h *GroupHandler) changeMembers(ctx *saiTypes.RequestCtx, change func(*saiTypes.RequestCtx, string, []string) error) {
+	groupID := string(ctx.QueryArgs().Peek("group_id"))
+	if groupID == "" {
+		ctx.Error(errors.New("group_id is required"), fasthttp.StatusBadRequest)
+		return
+	}
+
+	var req models.GroupMembersRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.Error(err, fasthttp.StatusBadRequest)
+		return
+	}
+
+	if len(req.UserIDs) == 0 {
+		ctx.Error(errors.New("user_ids are required"), fasthttp.StatusBadRequest)
+		return
+	}
+
+	if err := change(ctx, groupID, req.UserIDs); err != nil {
+		h.groupError(ctx, err)
+		return
+	}
+
+	response := types.Response{
+		Updated: 1,
+	}
+
+	ctx.SuccessJSON(response)
+}
+
+func (h *GroupHandler) groupError(ctx *saiTypes.RequestCtx, err error) {
+	var grantErr *service.RoleGrantError
+	switch {
+	case errors.As(err, &grantErr):
+		ctx.Error(err, fasthttp.StatusForbidden)
+	case err.Error() == "group not found":
+		ctx.Error(err, fasthttp.StatusNotFound)
+	case err.Error() == "group name already exists":
+		ctx.Error(err, fasthttp.StatusConflict)
+	case err.Error() == "group name is required",
+		err.Error() == "circular group dependency detected",
+		strings.HasPrefix(err.Error(), "maximum group nesting depth"),
+		strings.HasPrefix(err.Error(), "role "),
+		strings.HasPrefix(err.Error(), "user "),
+		strings.HasPrefix(err.Error(), "parent group "):
+		ctx.Error(err, fasthttp.StatusBadRequest)
+	default:
+		ctx.Error(err, fasthttp.StatusInternalServerError)
+	}
+}
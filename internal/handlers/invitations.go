@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/valyala/fasthttp"
+
+	"github.com/saiset-co/sai-auth/internal/models"
+	"github.com/saiset-co/sai-auth/internal/service"
+	"github.com/saiset-co/sai-auth/types"
+	saiTypes "github.com/saiset-co/sai-service/types"
+)
+
+type InvitationHandler struct {
+	invitationService *service.InvitationService
+}
+
+func NewInvitationHandler(invitationService *service.InvitationService) *InvitationHandler {
+	return &InvitationHandler{
+		invitationService: invitationService,
+	}
+}
+
+func (h *InvitationHandler) Create(ctx *saiTypes.RequestCtx) {
+	var req models.CreateInvitationRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.Error(err, fasthttp.StatusBadRequest)
+		return
+	}
+
+	if req.Email == "" {
+		ctx.Error(errors.New("Email is required"), fasthttp.StatusBadRequest)
+		return
+	}
+
+	invitation, err := h.invitationService.Create(ctx, &req)
+	if err != nil {
+		var grantErr *service.RoleGrantError
+		switch {
+		case errors.As(err, &grantErr):
+			ctx.Error(err, fasthttp.StatusForbidden)
+		case err.Error() == "email already exists":
+			ctx.Error(err, fasthttp.StatusConflict)
+		case strings.HasPrefix(err.Error(), "role "), err.Error() == "maximum 10 roles per user exceeded":
+			ctx.Error(err, fasthttp.StatusBadRequest)
+		default:
+			ctx.Error(err, fasthttp.StatusInternalServerError)
+		}
+		return
+	}
+
+	response := types.Response{
+		Data:    invitation,
+		Created: 1,
+	}
+
+	ctx.SuccessJSON(response)
+}
+
+func (h *InvitationHandler) List(ctx *saiTypes.RequestCtx) {
+	page, _ := strconv.Atoi(string(ctx.QueryArgs().Peek("page")))
+	limit, _ := strconv.Atoi(string(ctx.QueryArgs().Peek("limit")))
+
+	filter := &types.InvitationFilterRequest{
+		PaginationRequest: types.PaginationRequest{
+			Page:   page,
+			Limit:  limit,
+			Search: string(ctx.QueryArgs().Peek("search")),
+		},
+		Status: string(ctx.QueryArgs().Peek("status")),
+		Email:  string(ctx.QueryArgs().Peek("email")),
+	}
+
+	invitations, total, err := h.invitationService.List(ctx, filter)
+	if err != nil {
+		ctx.Error(err, fasthttp.StatusInternalServerError)
+		return
+	}
+
+	response := types.PaginatedResponse{
+		Data:       invitations,
+		Page:       filter.Page,
+		Limit:      filter.Limit,
+		Total:      total,
+		TotalPages: int(math.Ceil(float64(total) / float64(filter.Limit))),
+	}
+
+	ctx.SuccessJSON(response)
+}
+
+func (h *InvitationHandler) Revoke(ctx *saiTypes.RequestCtx) {
+	invitationID := string(ctx.QueryArgs().Peek("invitation_id"))
+	if invitationID == "" {
+		ctx.Error(errors.New("invitation_id is required"), fasthttp.StatusBadRequest)
+		return
+	}
+
+	err := h.invitationService.Revoke(ctx, invitationID)
+	if err != nil {
+		switch err.Error() {
+		case "invitation not found":
+			ctx.Error(err, fasthttp.StatusNotFound)
+		case "invitation is not pending":
+			ctx.Error(err, fasthttp.StatusConflict)
+		default:
+			ctx.Error(err, fasthttp.StatusInternalServerError)
+		}
+		return
+	}
+
+	response := types.Response{
+		Updated: 1,
+	}
+
+	ctx.SuccessJSON(response)
+}
+
+func (h *InvitationHandler) Accept(ctx *saiTypes.RequestCtx) {
+	var req models.AcceptInvitationRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.Error(err, fasthttp.StatusBadRequest)
+		return
+	}
+
+	if req.Token == "" || req.Username == "" || req.Password == "" {
+		ctx.Error(errors.New("Token, username, and password are required"), fasthttp.StatusBadRequest)
+		return
+	}
+
+	req.UserAgent = string(ctx.UserAgent())
+	req.IP = clientIP(ctx)
+
+	user, err := h.invitationService.Accept(ctx, &req)
+	if err != nil {
+		var throttled *service.LoginThrottledError
+		var policyErr *service.PasswordPolicyError
+		switch {
+		case errors.As(err, &throttled):
+			ctx.Error(err, fasthttp.StatusTooManyRequests)
+			ctx.Response.Header.Set("Retry-After", strconv.FormatInt(int64(math.Ceil(throttled.RetryAfter.Seconds())), 10))
+		case errors.As(err, &policyErr):
+			ctx.Error(err, fasthttp.StatusBadRequest)
+		case err.Error() == "invalid or expired invitation":
+			ctx.Error(err, fasthttp.StatusUnauthorized)
+		case err.Error() == "username already exists", err.Error() == "email already exists":
+			ctx.Error(err, fasthttp.StatusConflict)
+		default:
+			ctx.Error(err, fasthttp.StatusInternalServerError)
+		}
+		return
+	}
+
+	response := types.Response{
+		Data:    user,
+		Created: 1,
+	}
+
+	ctx.SuccessJSON(response)
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"github.com/pkg/errors"
+	"github.com/valyala/fasthttp"
+
+	"github.com/saiset-co/sai-auth/internal/models"
+	"github.com/saiset-co/sai-auth/internal/service"
+	saiTypes "github.com/saiset-co/sai-service/types"
+)
+
+type PasswordResetHandler struct {
+	resetService *service.PasswordResetService
+}
+
+func NewPasswordResetHandler(resetService *service.PasswordResetService) *PasswordResetHandler {
+	return &PasswordResetHandler{
+		resetService: resetService,
+	}
+}
+
+func (h *PasswordResetHandler) Forgot(ctx *saiTypes.RequestCtx) {
+	var req models.ForgotPasswordRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.Error(err, fasthttp.StatusBadRequest)
+		return
+	}
+
+	if req.User == "" {
+		ctx.Error(errors.New("User is required"), fasthttp.StatusBadRequest)
+		return
+	}
+
+	req.IP = clientIP(ctx)
+
+	if err := h.resetService.Forgot(ctx, &req); err != nil {
+		ctx.Error(err, fasthttp.StatusInternalServerError)
+		return
+	}
+
+	ctx.SuccessJSON(map[string]string{"message": "If the account exists, a reset link has been sent"})
+}
+
+func (h *PasswordResetHandler) Reset(ctx *saiTypes.RequestCtx) {
+	var req models.ResetPasswordRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.Error(err, fasthttp.StatusBadRequest)
+		return
+	}
+
+	if req.Token == "" || req.NewPassword == "" {
+		ctx.Error(errors.New("Token and new password are required"), fasthttp.StatusBadRequest)
+		return
+	}
+
+	req.UserAgent = string(ctx.UserAgent())
+	req.IP = clientIP(ctx)
+
+	err := h.resetService.Reset(ctx, &req)
+	if err != nil {
+		var policyErr *service.PasswordPolicyError
+		switch {
+		case errors.As(err, &policyErr):
+			ctx.Error(err, fasthttp.StatusBadRequest)
+		case err.Error() == "invalid or expired reset token":
+			ctx.Error(err, fasthttp.StatusUnauthorized)
+		default:
+			ctx.Error(err, fasthttp.StatusInternalServerError)
+		}
+		return
+	}
+
+	ctx.SuccessJSON(map[string]string{"message": "Password has been reset"})
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/valyala/fasthttp"
+
+	"github.com/saiset-co/sai-auth/internal/models"
+	"github.com/saiset-co/sai-auth/internal/service"
+	"github.com/saiset-co/sai-auth/types"
+	saiTypes "github.com/saiset-co/sai-service/types"
+)
+
+type RegistrationHandler struct {
+	registrationService *service.RegistrationService
+}
+
+func NewRegistrationHandler(registrationService *service.RegistrationService) *RegistrationHandler {
+	return &RegistrationHandler{
+		registrationService: registrationService,
+	}
+}
+
+func (h *RegistrationHandler) Register(ctx *saiTypes.RequestCtx) {
+	var req models.RegisterRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.Error(err, fasthttp.StatusBadRequest)
+		return
+	}
+
+	if req.Username == "" || req.Email == "" || req.Password == "" {
+		ctx.Error(errors.New("Username, email, and password are required"), fasthttp.StatusBadRequest)
+		return
+	}
+
+	req.UserAgent = string(ctx.UserAgent())
+	req.IP = clientIP(ctx)
+
+	user, err := h.registrationService.Register(ctx, &req)
+	if err != nil {
+		var throttled *service.LoginThrottledError
+		var policyErr *service.PasswordPolicyError
+		switch {
+		case errors.As(err, &throttled):
+			ctx.Error(err, fasthttp.StatusTooManyRequests)
+			ctx.Response.Header.Set("Retry-After", strconv.FormatInt(int64(math.Ceil(throttled.RetryAfter.Seconds())), 10))
+		case errors.As(err, &policyErr):
+			ctx.Error(err, fasthttp.StatusBadRequest)
+		case err.Error() == "registration is disabled":
+			ctx.Error(err, fasthttp.StatusNotFound)
+		case err.Error() == "invalid invite code":
+			ctx.Error(err, fasthttp.StatusForbidden)
+		case err.Error() == "username already exists", err.Error() == "email already exists":
+			ctx.Error(err, fasthttp.StatusConflict)
+		default:
+			ctx.Error(err, fasthttp.StatusInternalServerError)
+		}
+		return
+	}
+
+	response := types.Response{
+		Data:    user,
+		Created: 1,
+	}
+
+	ctx.SuccessJSON(response)
+}
+
+func (h *RegistrationHandler) Approve(ctx *saiTypes.RequestCtx) {
+	h.decide(ctx, h.registrationService.Approve)
+}
+
+func (h *RegistrationHandler) Reject(ctx *saiTypes.RequestCtx) {
+	h.decide(ctx, h.registrationService.Reject)
+}
+
+func (h *RegistrationHandler) decide(ctx *saiTypes.RequestCtx, decision func(*saiTypes.RequestCtx, string) error) {
+	userID := string(ctx.QueryArgs().Peek("user_id"))
+	if userID == "" {
+		ctx.Error(errors.New("user_id is required"), fasthttp.StatusBadRequest)
+		return
+	}
+
+	err := decision(ctx, userID)
+	if err != nil {
+		switch err.Error() {
+		case "user not found":
+			ctx.Error(err, fasthttp.StatusNotFound)
+		case "user is not pending approval":
+			ctx.Error(err, fasthttp.StatusConflict)
+		default:
+			ctx.Error(err, fasthttp.StatusInternalServerError)
+		}
+		return
+	}
+
+	response := types.Response{
+		Updated: 1,
+	}
+
+	ctx.SuccessJSON(response)
+}
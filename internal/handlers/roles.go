@@ -3,6 +3,7 @@ package handlers
 import (
 	"math"
 	"strconv"
+	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/saiset-co/sai-auth/internal/models"
@@ -36,9 +37,13 @@ func (h *RoleHandler) Create(ctx *saiTypes.RequestCtx) {
 
 	role, err := h.roleService.Create(ctx, &req)
 	if err != nil {
-		if err.Error() == "role name already exists" {
+		switch {
+		case err.Error() == "role name already exists":
 			ctx.Error(err, fasthttp.StatusConflict)
-		} else {
+		case err.Error() == "tenant not found", strings.HasSuffix(err.Error(), "belongs to another tenant"),
+			isPermissionError(err):
+			ctx.Error(err, fasthttp.StatusBadRequest)
+		default:
 			ctx.Error(err, fasthttp.StatusInternalServerError)
 		}
 		return
@@ -59,6 +64,8 @@ func (h *RoleHandler) Get(ctx *saiTypes.RequestCtx) {
 	limit, _ := strconv.Atoi(string(ctx.QueryArgs().Peek("limit")))
 	search := string(ctx.QueryArgs().Peek("search"))
 
+	tenantID := string(ctx.QueryArgs().Peek("tenant_id"))
+
 	var active *bool
 	if activeStr := string(ctx.QueryArgs().Peek("active")); activeStr != "" {
 		if activeBool, err := strconv.ParseBool(activeStr); err == nil {
@@ -72,7 +79,8 @@ func (h *RoleHandler) Get(ctx *saiTypes.RequestCtx) {
 			Limit:  limit,
 			Search: search,
 		},
-		Active: active,
+		Active:   active,
+		TenantID: tenantID,
 	}
 
 	if len(filter) > 0 {
@@ -88,7 +96,11 @@ func (h *RoleHandler) Get(ctx *saiTypes.RequestCtx) {
 		}
 
 		if roleName, exists := filter["name"]; exists {
-			role, err := h.roleService.GetByName(ctx, roleName.(string))
+			if filterTenant, ok := filter["tenant_id"].(string); ok {
+				tenantID = filterTenant
+			}
+
+			role, err := h.roleService.GetByName(ctx, tenantID, roleName.(string))
 			if err != nil {
 				ctx.Error(err, fasthttp.StatusNotFound)
 				return
@@ -144,7 +156,11 @@ func (h *RoleHandler) Update(ctx *saiTypes.RequestCtx) {
 
 	err := h.roleService.Update(ctx, req.Filter, req.Data)
 	if err != nil {
-		ctx.Error(err, fasthttp.StatusInternalServerError)
+		if err.Error() == "tenant_id cannot be changed" || isPermissionError(err) {
+			ctx.Error(err, fasthttp.StatusBadRequest)
+		} else {
+			ctx.Error(err, fasthttp.StatusInternalServerError)
+		}
 		return
 	}
 
@@ -208,3 +224,11 @@ func (h *RoleHandler) parseFilter(ctx *saiTypes.RequestCtx) map[string]interface
 
 	return filter
 }
+
+func isPermissionError(err error) bool {
+	return strings.HasPrefix(err.Error(), "maximum 50 permissions") ||
+		strings.HasPrefix(err.Error(), "invalid permissions") ||
+		strings.HasPrefix(err.Error(), "invalid path template") ||
+		strings.HasPrefix(err.Error(), "deny permission ") ||
+		strings.HasPrefix(err.Error(), "permission effect ")
+}
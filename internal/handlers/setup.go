@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"github.com/pkg/errors"
+	"github.com/valyala/fasthttp"
+
+	"github.com/saiset-co/sai-auth/internal/models"
+	"github.com/saiset-co/sai-auth/internal/service"
+	"github.com/saiset-co/sai-auth/types"
+	saiTypes "github.com/saiset-co/sai-service/types"
+)
+
+type SetupHandler struct {
+	bootstrapService *service.BootstrapService
+}
+
+func NewSetupHandler(bootstrapService *service.BootstrapService) *SetupHandler {
+	return &SetupHandler{
+		bootstrapService: bootstrapService,
+	}
+}
+
+func (h *SetupHandler) Setup(ctx *saiTypes.RequestCtx) {
+	var req models.SetupRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.Error(err, fasthttp.StatusBadRequest)
+		return
+	}
+
+	if req.SetupToken == "" || req.Username == "" || req.Email == "" || req.Password == "" {
+		ctx.Error(errors.New("Setup token, username, email, and password are required"), fasthttp.StatusBadRequest)
+		return
+	}
+
+	user, err := h.bootstrapService.Setup(ctx, &req)
+	if err != nil {
+		var policyErr *service.PasswordPolicyError
+		switch {
+		case errors.As(err, &policyErr):
+			ctx.Error(err, fasthttp.StatusBadRequest)
+		case err.Error() == "invalid setup token":
+			ctx.Error(err, fasthttp.StatusUnauthorized)
+		case err.Error() == "username already exists", err.Error() == "email already exists":
+			ctx.Error(err, fasthttp.StatusConflict)
+		default:
+			ctx.Error(err, fasthttp.StatusInternalServerError)
+		}
+		return
+	}
+
+	response := types.Response{
+		Data:    user,
+		Created: 1,
+	}
+
+	ctx.SuccessJSON(response)
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/valyala/fasthttp"
+
+	"github.com/saiset-co/sai-auth/internal/models"
+	"github.com/saiset-co/sai-auth/internal/service"
+	"github.com/saiset-co/sai-auth/types"
+	saiTypes "github.com/saiset-co/sai-service/types"
+)
+
+type TenantHandler struct {
+	tenantService *service.TenantService
+}
+
+func NewTenantHandler(tenantService *service.TenantService) *TenantHandler {
+	return &TenantHandler{
+		tenantService: tenantService,
+	}
+}
+
+func (h *TenantHandler) Create(ctx *saiTypes.RequestCtx) {
+	var req models.CreateTenantRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.Error(err, fasthttp.StatusBadRequest)
+		return
+	}
+
+	if req.Name == "" {
+		ctx.Error(errors.New("Tenant name is required"), fasthttp.StatusBadRequest)
+		return
+	}
+
+	tenant, err := h.tenantService.Create(ctx, &req)
+	if err != nil {
+		h.tenantError(ctx, err)
+		return
+	}
+
+	response := types.Response{
+		Data:    tenant,
+		Created: 1,
+	}
+
+	ctx.SuccessJSON(response)
+}
+
+func (h *TenantHandler) List(ctx *saiTypes.RequestCtx) {
+	if tenantID := string(ctx.QueryArgs().Peek("tenant_id")); tenantID != "" {
+		tenant, err := h.tenantService.GetByID(ctx, tenantID)
+		if err != nil {
+			ctx.Error(err, fasthttp.StatusNotFound)
+			return
+		}
+
+		ctx.SuccessJSON([]*models.Tenant{tenant})
+		return
+	}
+
+	page, _ := strconv.Atoi(string(ctx.QueryArgs().Peek("page")))
+	limit, _ := strconv.Atoi(string(ctx.QueryArgs().Peek("limit")))
+
+	var active *bool
+	if activeStr := string(ctx.QueryArgs().Peek("active")); activeStr != "" {
+		if activeBool, err := strconv.ParseBool(activeStr); err == nil {
+			active = &activeBool
+		}
+	}
+
+	filter := &types.TenantFilterRequest{
+		PaginationRequest: types.PaginationRequest{
+			Page:   page,
+			Limit:  limit,
+			Search: string(ctx.QueryArgs().Peek("search")),
+		},
+		Active: active,
+	}
+
+	tenants, total, err := h.tenantService.List(ctx, filter)
+	if err != nil {
+		ctx.Error(err, fasthttp.StatusInternalServerError)
+		return
+	}
+
+	response := types.PaginatedResponse{
+		Data:       tenants,
+		Page:       filter.Page,
+		Limit:      filter.Limit,
+		Total:      total,
+		TotalPages: int(math.Ceil(float64(total) / float64(filter.Limit))),
+	}
+
+	ctx.SuccessJSON(response)
+}
+
+func (h *TenantHandler) Update(ctx *saiTypes.RequestCtx) {
+	tenantID := string(ctx.QueryArgs().Peek("tenant_id"))
+	if tenantID == "" {
+		ctx.Error(errors.New("tenant_id is required"), fasthttp.StatusBadRequest)
+		return
+	}
+
+	var req models.UpdateTenantRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.Error(err, fasthttp.StatusBadRequest)
+		return
+	}
+
+	if err := h.tenantService.Update(ctx, tenantID, &req); err != nil {
+		h.tenantError(ctx, err)
+		return
+	}
+
+	response := types.Response{
+		Updated: 1,
+	}
+
+	ctx.SuccessJSON(response)
+}
+
+func (h *TenantHandler) AddMembers(ctx *saiTypes.RequestCtx) {
+	h.changeMembers(ctx, h.tenantService.AddMembers)
+}
+
+func (h *TenantHandler) RemoveMembers(ctx *saiTypes.RequestCtx) {
+	h.changeMembers(ctx, h.tenantService.RemoveMembers)
+}
+
+func (h *TenantHandler) changeMembers(ctx *saiTypes.RequestCtx, change func(*saiTypes.RequestCtx, string, []string) error) {
+	tenantID := string(ctx.QueryArgs().Peek("tenant_id"))
+	if tenantID == "" {
+		ctx.Error(errors.New("tenant_id is required"), fasthttp.StatusBadRequest)
+		return
+	}
+
+	var req models.TenantMembersRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.Error(err, fasthttp.StatusBadRequest)
+		return
+	}
+
+	if len(req.UserIDs) == 0 {
+		ctx.Error(errors.New("user_ids are required"), fasthttp.StatusBadRequest)
+		return
+	}
+
+	if err := change(ctx, tenantID, req.UserIDs); err != nil {
+		h.tenantError(ctx, err)
+		return
+	}
+
+	response := types.Response{
+		Updated: 1,
+	}
+
+	ctx.SuccessJSON(response)
+}
+
+func (h *TenantHandler) tenantError(ctx *saiTypes.RequestCtx, err error) {
+	switch {
+	case err.Error() == "tenant not found":
+		ctx.Error(err, fasthttp.StatusNotFound)
+	case err.Error() == "tenant name already exists":
+		ctx.Error(err, fasthttp.StatusConflict)
+	case err.Error() == "tenant name is required",
+		strings.HasPrefix(err.Error(), "user "):
+		ctx.Error(err, fasthttp.StatusBadRequest)
+	default:
+		ctx.Error(err, fasthttp.StatusInternalServerError)
+	}
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"math"
 	"strconv"
+	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/saiset-co/sai-auth/internal/models"
@@ -36,7 +37,10 @@ func (h *UserHandler) Create(ctx *saiTypes.RequestCtx) {
 
 	user, err := h.userService.Create(ctx, &req)
 	if err != nil {
-		if err.Error() == "username already exists" || err.Error() == "email already exists" {
+		var policyErr *service.PasswordPolicyError
+		if errors.As(err, &policyErr) {
+			ctx.Error(err, fasthttp.StatusBadRequest)
+		} else if err.Error() == "username already exists" || err.Error() == "email already exists" {
 			ctx.Error(err, fasthttp.StatusConflict)
 		} else {
 			ctx.Error(err, fasthttp.StatusInternalServerError)
@@ -59,6 +63,8 @@ func (h *UserHandler) Get(ctx *saiTypes.RequestCtx) {
 	limit, _ := strconv.Atoi(string(ctx.QueryArgs().Peek("limit")))
 	search := string(ctx.QueryArgs().Peek("search"))
 	role := string(ctx.QueryArgs().Peek("role"))
+	status := string(ctx.QueryArgs().Peek("status"))
+	tenant := string(ctx.QueryArgs().Peek("tenant"))
 
 	var active *bool
 	if activeStr := string(ctx.QueryArgs().Peek("active")); activeStr != "" {
@@ -75,6 +81,8 @@ func (h *UserHandler) Get(ctx *saiTypes.RequestCtx) {
 		},
 		Role:   role,
 		Active: active,
+		Status: status,
+		Tenant: tenant,
 	}
 
 	if len(filter) > 0 {
@@ -135,7 +143,25 @@ func (h *UserHandler) Update(ctx *saiTypes.RequestCtx) {
 
 	err := h.userService.Update(ctx, req.Filter, req.Data)
 	if err != nil {
-		ctx.Error(err, fasthttp.StatusInternalServerError)
+		var policyErr *service.PasswordPolicyError
+		var grantErr *service.RoleGrantError
+		switch {
+		case errors.As(err, &grantErr),
+			err.Error() == "superuser accounts can only be changed by a superuser":
+			ctx.Error(err, fasthttp.StatusForbidden)
+		case errors.As(err, &policyErr),
+			strings.HasPrefix(err.Error(), "update operator "),
+			strings.HasPrefix(err.Error(), "field "),
+			err.Error() == "password must be a string",
+			err.Error() == "password can only be changed for a single user selected by internal_id":
+			ctx.Error(err, fasthttp.StatusBadRequest)
+		case err.Error() == "user not found":
+			ctx.Error(err, fasthttp.StatusNotFound)
+		case errors.Is(err, service.ErrLastSuperUser):
+			ctx.Error(err, fasthttp.StatusConflict)
+		default:
+			ctx.Error(err, fasthttp.StatusInternalServerError)
+		}
 		return
 	}
 
@@ -160,7 +186,14 @@ func (h *UserHandler) Delete(ctx *saiTypes.RequestCtx) {
 
 	err := h.userService.Delete(ctx, req.Filter)
 	if err != nil {
-		ctx.Error(err, fasthttp.StatusInternalServerError)
+		switch {
+		case err.Error() == "superuser accounts can only be changed by a superuser":
+			ctx.Error(err, fasthttp.StatusForbidden)
+		case errors.Is(err, service.ErrLastSuperUser):
+			ctx.Error(err, fasthttp.StatusConflict)
+		default:
+			ctx.Error(err, fasthttp.StatusInternalServerError)
+		}
 		return
 	}
 
@@ -178,10 +211,7 @@ func (h *UserHandler) AssignRoles(ctx *saiTypes.RequestCtx) {
 		return
 	}
 
-	var req struct {
-		RoleIDs []string `json:"role_ids"`
-	}
-
+	var req models.AssignRolesRequest
 	if err := ctx.ReadJSON(&req); err != nil {
 		ctx.Error(err, fasthttp.StatusBadRequest)
 		return
@@ -192,9 +222,9 @@ func (h *UserHandler) AssignRoles(ctx *saiTypes.RequestCtx) {
 		return
 	}
 
-	err := h.userService.AssignRoles(ctx, userID, req.RoleIDs)
+	err := h.userService.AssignRoles(ctx, userID, &req)
 	if err != nil {
-		ctx.Error(err, fasthttp.StatusInternalServerError)
+		h.roleChangeError(ctx, err)
 		return
 	}
 
@@ -228,7 +258,45 @@ func (h *UserHandler) RemoveRoles(ctx *saiTypes.RequestCtx) {
 
 	err := h.userService.RemoveRoles(ctx, userID, req.RoleIDs)
 	if err != nil {
+		h.roleChangeError(ctx, err)
+		return
+	}
+
+	response := types.Response{
+		Updated: 1,
+	}
+
+	ctx.SuccessJSON(response)
+}
+
+func (h *UserHandler) roleChangeError(ctx *saiTypes.RequestCtx, err error) {
+	var grantErr *service.RoleGrantError
+	switch {
+	case errors.As(err, &grantErr):
+		ctx.Error(err, fasthttp.StatusForbidden)
+	case err.Error() == "expires_at must be in the future",
+		err.Error() == "not_before must be before expires_at",
+		err.Error() == "maximum 10 roles per user exceeded":
+		ctx.Error(err, fasthttp.StatusBadRequest)
+	default:
 		ctx.Error(err, fasthttp.StatusInternalServerError)
+	}
+}
+
+func (h *UserHandler) Unlock(ctx *saiTypes.RequestCtx) {
+	userID := string(ctx.QueryArgs().Peek("user_id"))
+	if userID == "" {
+		ctx.Error(errors.New("user_id is required"), fasthttp.StatusBadRequest)
+		return
+	}
+
+	err := h.userService.Unlock(ctx, userID)
+	if err != nil {
+		if err.Error() == "user not found" {
+			ctx.Error(err, fasthttp.StatusNotFound)
+		} else {
+			ctx.Error(err, fasthttp.StatusInternalServerError)
+		}
 		return
 	}
 
@@ -239,6 +307,107 @@ func (h *UserHandler) RemoveRoles(ctx *saiTypes.RequestCtx) {
 	ctx.SuccessJSON(response)
 }
 
+func (h *UserHandler) LoginFailures(ctx *saiTypes.RequestCtx) {
+	page, _ := strconv.Atoi(string(ctx.QueryArgs().Peek("page")))
+	limit, _ := strconv.Atoi(string(ctx.QueryArgs().Peek("limit")))
+	since, _ := strconv.ParseInt(string(ctx.QueryArgs().Peek("since")), 10, 64)
+
+	filter := &types.SecurityEventFilterRequest{
+		PaginationRequest: types.PaginationRequest{
+			Page:  page,
+			Limit: limit,
+		},
+		UserID: string(ctx.QueryArgs().Peek("user_id")),
+		IP:     string(ctx.QueryArgs().Peek("ip")),
+		Since:  since,
+	}
+
+	events, total, err := h.userService.LoginFailures(ctx, filter)
+	if err != nil {
+		ctx.Error(err, fasthttp.StatusInternalServerError)
+		return
+	}
+
+	response := types.PaginatedResponse{
+		Data:       events,
+		Page:       filter.Page,
+		Limit:      filter.Limit,
+		Total:      total,
+		TotalPages: int(math.Ceil(float64(total) / float64(filter.Limit))),
+	}
+
+	ctx.SuccessJSON(response)
+}
+
+func (h *UserHandler) SuperUsers(ctx *saiTypes.RequestCtx) {
+	page, _ := strconv.Atoi(string(ctx.QueryArgs().Peek("page")))
+	limit, _ := strconv.Atoi(string(ctx.QueryArgs().Peek("limit")))
+
+	filter := &types.UserFilterRequest{
+		PaginationRequest: types.PaginationRequest{
+			Page:   page,
+			Limit:  limit,
+			Search: string(ctx.QueryArgs().Peek("search")),
+		},
+	}
+
+	users, total, err := h.userService.ListSuperUsers(ctx, filter)
+	if err != nil {
+		h.superUserError(ctx, err)
+		return
+	}
+
+	response := types.PaginatedResponse{
+		Data:       users,
+		Page:       filter.Page,
+		Limit:      filter.Limit,
+		Total:      total,
+		TotalPages: int(math.Ceil(float64(total) / float64(filter.Limit))),
+	}
+
+	ctx.SuccessJSON(response)
+}
+
+func (h *UserHandler) PromoteSuperUser(ctx *saiTypes.RequestCtx) {
+	h.changeSuperUser(ctx, h.userService.PromoteSuperUser)
+}
+
+func (h *UserHandler) DemoteSuperUser(ctx *saiTypes.RequestCtx) {
+	h.changeSuperUser(ctx, h.userService.DemoteSuperUser)
+}
+
+func (h *UserHandler) changeSuperUser(ctx *saiTypes.RequestCtx, change func(*saiTypes.RequestCtx, string) error) {
+	userID := string(ctx.QueryArgs().Peek("user_id"))
+	if userID == "" {
+		ctx.Error(errors.New("user_id is required"), fasthttp.StatusBadRequest)
+		return
+	}
+
+	if err := change(ctx, userID); err != nil {
+		h.superUserError(ctx, err)
+		return
+	}
+
+	response := types.Response{
+		Updated: 1,
+	}
+
+	ctx.SuccessJSON(response)
+}
+
+func (h *UserHandler) superUserError(ctx *saiTypes.RequestCtx, err error) {
+	switch err.Error() {
+	case "superuser access required":
+		ctx.Error(err, fasthttp.StatusForbidden)
+	case "user not found":
+		ctx.Error(err, fasthttp.StatusNotFound)
+	case "user is already a superuser", "user is not a superuser", "cannot demote the last superuser":
+		ctx.Error(err, fasthttp.StatusConflict)
+	default:
+		ctx.Error(err, fasthttp.StatusInternalServerError)
+	}
+}
+
 func (h *UserHandler) parseFilter(ctx *saiTypes.RequestCtx) map[string]interface{} {
 	filter := make(map[string]interface{})
 
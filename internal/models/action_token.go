@@ -0,0 +1,40 @@
+package models
+
+const (
+	ActionTokenPasswordReset     = "password_reset"
+	ActionTokenEmailVerification = "email_verification"
+)
+
+// ActionToken is a single-use token mailed to a user to confirm an action.
+// Only TokenHash is stored; Token is filled in when the token is created.
+type ActionToken struct {
+	InternalID string `json:"internal_id" bson:"internal_id"`
+	UserID     string `json:"user_id" bson:"user_id"`
+	Purpose    string `json:"purpose" bson:"purpose"`
+	Token      string `json:"token,omitempty" bson:"-"`
+	TokenHash  string `json:"token_hash" bson:"token_hash"`
+	ExpiresAt  int64  `json:"expires_at" bson:"expires_at"`
+	CrTime     int64  `json:"cr_time" bson:"cr_time"`
+}
+
+type ForgotPasswordRequest struct {
+	User string `json:"user" validate:"required"`
+	IP   string `json:"-"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required"`
+	UserAgent   string `json:"-"`
+	IP          string `json:"-"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type ResendVerificationRequest struct {
+	User      string `json:"user,omitempty"`
+	UserAgent string `json:"-"`
+	IP        string `json:"-"`
+}
@@ -0,0 +1,38 @@
+package models
+
+// Group gives its members its roles. Members of a group are also members of
+// its parent groups, so they get the roles of every ancestor as well.
+type Group struct {
+	InternalID   string                 `json:"internal_id" bson:"internal_id"`
+	Name         string                 `json:"name" bson:"name"`
+	IsActive     bool                   `json:"is_active" bson:"is_active"`
+	ParentGroups []string               `json:"parent_groups" bson:"parent_groups"`
+	Roles        []string               `json:"roles" bson:"roles"`
+	Members      []string               `json:"members" bson:"members"`
+	Data         map[string]interface{} `json:"data" bson:"data"`
+	CrTime       int64                  `json:"cr_time" bson:"cr_time"`
+	ChTime       int64                  `json:"ch_time" bson:"ch_time"`
+}
+
+type CreateGroupRequest struct {
+	Name         string                 `json:"name" validate:"required"`
+	IsActive     *bool                  `json:"is_active"`
+	ParentGroups []string               `json:"parent_groups"`
+	Roles        []string               `json:"roles"`
+	Members      []string               `json:"members"`
+	Data         map[string]interface{} `json:"data"`
+}
+
+// UpdateGroupRequest changes the fields that are set; membership is changed
+// with the add-members and remove-members endpoints.
+type UpdateGroupRequest struct {
+	Name         *string                `json:"name"`
+	IsActive     *bool                  `json:"is_active"`
+	ParentGroups []string               `json:"parent_groups"`
+	Roles        []string               `json:"roles"`
+	Data         map[string]interface{} `json:"data"`
+}
+
+type GroupMembersRequest struct {
+	UserIDs []string `json:"user_ids"`
+}
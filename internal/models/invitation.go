@@ -0,0 +1,40 @@
+package models
+
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationRevoked  = "revoked"
+	InvitationExpired  = "expired"
+)
+
+// Invitation onboards a user by email into preset roles. Only TokenHash is
+// stored; Token is returned once when the invitation is created.
+type Invitation struct {
+	InternalID string                 `json:"internal_id" bson:"internal_id"`
+	Email      string                 `json:"email" bson:"email"`
+	Roles      []string               `json:"roles" bson:"roles"`
+	Data       map[string]interface{} `json:"data" bson:"data"`
+	Token      string                 `json:"token,omitempty" bson:"-"`
+	TokenHash  string                 `json:"-" bson:"token_hash"`
+	Status     string                 `json:"status" bson:"status"`
+	InvitedBy  string                 `json:"invited_by,omitempty" bson:"invited_by"`
+	UserID     string                 `json:"user_id,omitempty" bson:"user_id"`
+	ExpiresAt  int64                  `json:"expires_at" bson:"expires_at"`
+	AcceptedAt int64                  `json:"accepted_at,omitempty" bson:"accepted_at"`
+	CrTime     int64                  `json:"cr_time" bson:"cr_time"`
+	ChTime     int64                  `json:"ch_time,omitempty" bson:"ch_time"`
+}
+
+type CreateInvitationRequest struct {
+	Email string                 `json:"email" validate:"required,email"`
+	Roles []string               `json:"roles"`
+	Data  map[string]interface{} `json:"data"`
+}
+
+type AcceptInvitationRequest struct {
+	Token     string `json:"token" validate:"required"`
+	Username  string `json:"username" validate:"required"`
+	Password  string `json:"password" validate:"required"`
+	UserAgent string `json:"-"`
+	IP        string `json:"-"`
+}
@@ -0,0 +1,35 @@
+package models
+
+// MFAChallenge is issued after a correct password for a user with MFA enabled
+// and is exchanged, together with a code, for a session.
+type MFAChallenge struct {
+	InternalID string `json:"internal_id" bson:"internal_id"`
+	UserID     string `json:"user_id" bson:"user_id"`
+	Token      string `json:"token,omitempty" bson:"-"`
+	TokenHash  string `json:"token_hash" bson:"token_hash"`
+	Renew      bool   `json:"renew" bson:"renew"`
+	TenantID   string `json:"tenant_id,omitempty" bson:"tenant_id"`
+	Attempts   int    `json:"attempts" bson:"attempts"`
+	ExpiresAt  int64  `json:"expires_at" bson:"expires_at"`
+	CrTime     int64  `json:"cr_time" bson:"cr_time"`
+}
+
+type MFAEnrollResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type MFAConfirmResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type MFAVerifyRequest struct {
+	MFAToken  string `json:"mfa_token" validate:"required"`
+	Code      string `json:"code" validate:"required"`
+	UserAgent string `json:"-"`
+	IP        string `json:"-"`
+}
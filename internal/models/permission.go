@@ -14,19 +14,40 @@ type Rate struct {
 	Window time.Duration `json:"window" validate:"required"`
 }
 
+type RateLimitStatus struct {
+	Allowed   bool  `json:"allowed"`
+	Limit     int64 `json:"limit"`
+	Remaining int64 `json:"remaining"`
+	Reset     int64 `json:"reset"`
+}
+
+const (
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+)
+
+// Permission grants access to an endpoint unless Effect is EffectDeny, in
+// which case it forbids the endpoint whatever other roles allow. Deny
+// permissions carry no params or rates.
 type Permission struct {
 	Microservice     string   `json:"microservice" validate:"required"`
 	Method           string   `json:"method" validate:"required"`
 	Path             string   `json:"path" validate:"required"`
+	Effect           string   `json:"effect,omitempty"`
 	Rates            []Rate   `json:"rates"`
 	RequiredParams   []Params `json:"required_params"`
 	RestrictedParams []Params `json:"restricted_params"`
 }
 
+// CompiledPermission is a permission merged from all of a user's roles.
+// Denies are compiled apart from allows for the same endpoint. InheritedFrom
+// lists the roles it came from; roles given through a group appear as
+// "group:<group_id>/<role_id>".
 type CompiledPermission struct {
 	Microservice     string   `json:"microservice"`
 	Method           string   `json:"method"`
 	Path             string   `json:"path"`
+	Effect           string   `json:"effect,omitempty"`
 	Rates            []Rate   `json:"rates"`
 	RequiredParams   []Params `json:"required_params"`
 	RestrictedParams []Params `json:"restricted_params"`
@@ -1,22 +1,44 @@
 package models
 
+// Role is global when TenantID is empty; otherwise it only applies to
+// sessions in that tenant and its name is unique within the tenant.
 type Role struct {
-	InternalID  string                 `json:"internal_id" bson:"internal_id"`
-	Name        string                 `json:"name" bson:"name" validate:"required"`
-	IsActive    bool                   `json:"is_active" bson:"is_active"`
-	ParentRoles []string               `json:"parent_roles" bson:"parent_roles"`
-	Permissions []Permission           `json:"permissions" bson:"permissions"`
-	Data        map[string]interface{} `json:"data" bson:"data"`
-	CrTime      int64                  `json:"cr_time" bson:"cr_time"`
-	ChTime      int64                  `json:"ch_time" bson:"ch_time"`
+	InternalID     string                 `json:"internal_id" bson:"internal_id"`
+	TenantID       string                 `json:"tenant_id,omitempty" bson:"tenant_id"`
+	Name           string                 `json:"name" bson:"name" validate:"required"`
+	IsActive       bool                   `json:"is_active" bson:"is_active"`
+	ParentRoles    []string               `json:"parent_roles" bson:"parent_roles"`
+	Permissions    []Permission           `json:"permissions" bson:"permissions"`
+	GrantableRoles []GrantableRole        `json:"grantable_roles" bson:"grantable_roles"`
+	Data           map[string]interface{} `json:"data" bson:"data"`
+	CrTime         int64                  `json:"cr_time" bson:"cr_time"`
+	ChTime         int64                  `json:"ch_time" bson:"ch_time"`
 }
 
 type CreateRoleRequest struct {
-	Name        string                 `json:"name" validate:"required"`
-	IsActive    *bool                  `json:"is_active"`
-	ParentRoles []string               `json:"parent_roles"`
-	Permissions []Permission           `json:"permissions"`
-	Data        map[string]interface{} `json:"data"`
+	TenantID       string                 `json:"tenant_id"`
+	Name           string                 `json:"name" validate:"required"`
+	IsActive       *bool                  `json:"is_active"`
+	ParentRoles    []string               `json:"parent_roles"`
+	Permissions    []Permission           `json:"permissions"`
+	GrantableRoles []GrantableRole        `json:"grantable_roles"`
+	Data           map[string]interface{} `json:"data"`
+}
+
+// GrantableRole lets holders of a role assign and remove RoleID ("*" for
+// any role) on users whose data satisfies every Scope param. Scope values
+// may use placeholders resolved against the granting user, e.g. department
+// "$.data.department".
+type GrantableRole struct {
+	RoleID string   `json:"role_id" bson:"role_id"`
+	Scope  []Params `json:"scope,omitempty" bson:"scope"`
+}
+
+// RoleGrantResult explains whether a role could be granted to a user.
+type RoleGrantResult struct {
+	RoleID  string `json:"role_id"`
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason,omitempty"`
 }
 
 type RolePermissionsResponse struct {
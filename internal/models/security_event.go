@@ -0,0 +1,25 @@
+package models
+
+const (
+	SecurityEventRefreshTokenReuse = "refresh_token_reuse"
+	SecurityEventLoginFailed       = "login_failed"
+	SecurityEventAccountLocked     = "account_locked"
+	SecurityEventAccountUnlocked   = "account_unlocked"
+	SecurityEventPasswordReset     = "password_reset"
+	SecurityEventEmailVerified     = "email_verified"
+	SecurityEventUserRegistered    = "user_registered"
+	SecurityEventSuperUserIPDenied = "superuser_ip_denied"
+	SecurityEventSuperUserPromoted = "superuser_promoted"
+	SecurityEventSuperUserDemoted  = "superuser_demoted"
+)
+
+type SecurityEvent struct {
+	InternalID string                 `json:"internal_id" bson:"internal_id"`
+	Type       string                 `json:"type" bson:"type"`
+	UserID     string                 `json:"user_id" bson:"user_id"`
+	SessionID  string                 `json:"session_id,omitempty" bson:"session_id"`
+	IP         string                 `json:"ip,omitempty" bson:"ip"`
+	UserAgent  string                 `json:"user_agent,omitempty" bson:"user_agent"`
+	Details    map[string]interface{} `json:"details,omitempty" bson:"details"`
+	CrTime     int64                  `json:"cr_time" bson:"cr_time"`
+}
@@ -0,0 +1,13 @@
+package models
+
+// SigningKey is a JWT signing key. It signs new access tokens until SignUntil
+// and stays published in the JWKS until ExpiresAt, so tokens signed just
+// before a rotation still verify.
+type SigningKey struct {
+	InternalID string `json:"internal_id" bson:"internal_id"`
+	Algorithm  string `json:"algorithm" bson:"algorithm"`
+	PrivateKey string `json:"private_key" bson:"private_key"`
+	SignUntil  int64  `json:"sign_until" bson:"sign_until"`
+	ExpiresAt  int64  `json:"expires_at" bson:"expires_at"`
+	CrTime     int64  `json:"cr_time" bson:"cr_time"`
+}
@@ -0,0 +1,32 @@
+package models
+
+// Tenant is an organization sharing the sai-auth instance. Users belong to
+// any number of tenants and each session acts in one of them.
+type Tenant struct {
+	InternalID string                 `json:"internal_id" bson:"internal_id"`
+	Name       string                 `json:"name" bson:"name"`
+	IsActive   bool                   `json:"is_active" bson:"is_active"`
+	Data       map[string]interface{} `json:"data" bson:"data"`
+	CrTime     int64                  `json:"cr_time" bson:"cr_time"`
+	ChTime     int64                  `json:"ch_time" bson:"ch_time"`
+}
+
+type CreateTenantRequest struct {
+	Name     string                 `json:"name" validate:"required"`
+	IsActive *bool                  `json:"is_active"`
+	Data     map[string]interface{} `json:"data"`
+}
+
+type UpdateTenantRequest struct {
+	Name     *string                `json:"name"`
+	IsActive *bool                  `json:"is_active"`
+	Data     map[string]interface{} `json:"data"`
+}
+
+type TenantMembersRequest struct {
+	UserIDs []string `json:"user_ids"`
+}
+
+type SwitchTenantRequest struct {
+	TenantID string `json:"tenant_id"`
+}
@@ -1,27 +1,58 @@
 package models
 
 type Token struct {
-	InternalID          string               `json:"internal_id" redis:"internal_id"`
-	UserID              string               `json:"user_id" redis:"user_id"`
-	AccessToken         string               `json:"access_token" redis:"access_token"`
-	RefreshToken        string               `json:"refresh_token" redis:"refresh_token"`
-	ExpiresAt           int64                `json:"expires_at" redis:"expires_at"`
-	RefreshExpiresAt    int64                `json:"refresh_expires_at" redis:"refresh_expires_at"`
-	CompiledPermissions []CompiledPermission `json:"compiled_permissions" redis:"compiled_permissions"`
-	CreatedAt           int64                `json:"cr_time" redis:"cr_time"`
-	UpdatedAt           int64                `json:"ch_time" redis:"ch_time"`
+	InternalID           string               `json:"internal_id" redis:"internal_id"`
+	UserID               string               `json:"user_id" redis:"user_id"`
+	TenantID             string               `json:"tenant_id,omitempty" redis:"tenant_id"`
+	FamilyID             string               `json:"family_id" redis:"family_id"`
+	AccessToken          string               `json:"access_token,omitempty" redis:"access_token"`
+	AccessTokenHash      string               `json:"access_token_hash" redis:"access_token_hash"`
+	RefreshToken         string               `json:"refresh_token,omitempty" redis:"refresh_token"`
+	RefreshTokenHash     string               `json:"refresh_token_hash" redis:"refresh_token_hash"`
+	RotatedRefreshTokens []string             `json:"rotated_refresh_tokens,omitempty" redis:"rotated_refresh_tokens"`
+	ExpiresAt            int64                `json:"expires_at" redis:"expires_at"`
+	RefreshExpiresAt     int64                `json:"refresh_expires_at" redis:"refresh_expires_at"`
+	CompiledPermissions  []CompiledPermission `json:"compiled_permissions" redis:"compiled_permissions"`
+	PermissionsChangedAt int64                `json:"permissions_changed_at,omitempty" redis:"permissions_changed_at"`
+	UserAgent            string               `json:"user_agent" redis:"user_agent"`
+	IP                   string               `json:"ip" redis:"ip"`
+	LastUsedAt           int64                `json:"last_used_at" redis:"last_used_at"`
+	CreatedAt            int64                `json:"cr_time" redis:"cr_time"`
+	UpdatedAt            int64                `json:"ch_time" redis:"ch_time"`
+}
+
+type SessionResponse struct {
+	SessionID        string `json:"session_id"`
+	TenantID         string `json:"tenant_id,omitempty"`
+	UserAgent        string `json:"user_agent"`
+	IP               string `json:"ip"`
+	CreatedAt        int64  `json:"cr_time"`
+	LastUsedAt       int64  `json:"last_used_at"`
+	ExpiresAt        int64  `json:"expires_at"`
+	RefreshExpiresAt int64  `json:"refresh_expires_at"`
+	Current          bool   `json:"current"`
+}
+
+type RevokeSessionsRequest struct {
+	SessionID string `json:"session_id"`
+	All       bool   `json:"all"`
 }
 
 type TokenResponse struct {
+	SessionID    string `json:"session_id"`
+	TenantID     string `json:"tenant_id,omitempty"`
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
 	ExpiresIn    int64  `json:"expires_in"`
 }
 
 type AuthResponse struct {
-	User        *User                `json:"user"`
-	Tokens      *TokenResponse       `json:"tokens"`
-	Permissions []CompiledPermission `json:"permissions"`
+	User         *User                `json:"user,omitempty"`
+	Tokens       *TokenResponse       `json:"tokens,omitempty"`
+	Permissions  []CompiledPermission `json:"permissions,omitempty"`
+	MFARequired  bool                 `json:"mfa_required,omitempty"`
+	MFAToken     string               `json:"mfa_token,omitempty"`
+	MFAExpiresIn int64                `json:"mfa_expires_in,omitempty"`
 }
 
 type VerifyRequest struct {
@@ -30,14 +61,38 @@ type VerifyRequest struct {
 	Method        string                 `json:"method" validate:"required"`
 	Path          string                 `json:"path" validate:"required"`
 	RequestParams map[string]interface{} `json:"request_params"`
+	ClientIP      string                 `json:"client_ip,omitempty"`
 }
 
 type VerifyResponse struct {
 	Allowed        bool                   `json:"allowed"`
 	UserID         string                 `json:"user_id"`
+	TenantID       string                 `json:"tenant_id,omitempty"`
 	ModifiedParams map[string]interface{} `json:"modified_params,omitempty"`
+	Permission     string                 `json:"permission,omitempty"`
+	Rates          []Rate                 `json:"rates,omitempty"`
+	PathParams     map[string]string      `json:"path_params,omitempty"`
+	RateLimited    bool                   `json:"rate_limited,omitempty"`
+	RateLimit      *RateLimitStatus       `json:"rate_limit,omitempty"`
 	Reason         string                 `json:"reason,omitempty"`
 	ViolatedRule   *ViolatedRule          `json:"violated_restriction,omitempty"`
+	RoleGrants     []RoleGrantResult      `json:"role_grants,omitempty"`
+}
+
+type IntrospectRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// IntrospectResponse describes a live session. PermissionsChangedAt is when
+// its permissions were last recompiled without reissuing the access token;
+// permissions embedded in tokens issued before then are out of date.
+type IntrospectResponse struct {
+	Active               bool   `json:"active"`
+	UserID               string `json:"user_id,omitempty"`
+	SessionID            string `json:"session_id,omitempty"`
+	TenantID             string `json:"tenant_id,omitempty"`
+	ExpiresAt            int64  `json:"expires_at,omitempty"`
+	PermissionsChangedAt int64  `json:"permissions_changed_at,omitempty"`
 }
 
 type ViolatedRule struct {
@@ -52,6 +107,10 @@ type TestPermissionsRequest struct {
 	Method       string                 `json:"method" validate:"required"`
 	Path         string                 `json:"path" validate:"required"`
 	TestParams   map[string]interface{} `json:"test_params"`
+	TenantID     string                 `json:"tenant_id"`
+	// GrantRoles asks whether UserID could assign these roles to TargetUserID.
+	GrantRoles   []string `json:"grant_roles"`
+	TargetUserID string   `json:"target_user_id"`
 }
 
 type UserInfoResponse struct {
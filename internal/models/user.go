@@ -1,32 +1,142 @@
 package models
 
 type User struct {
-	InternalID   string                 `json:"internal_id" bson:"internal_id"`
-	Username     string                 `json:"username" bson:"username" validate:"required"`
-	Email        string                 `json:"email" bson:"email" validate:"required,email"`
-	PasswordHash string                 `json:"password_hash,omitempty" bson:"password_hash"`
-	IsActive     bool                   `json:"is_active" bson:"is_active"`
-	IsSuperUser  bool                   `json:"is_super_user,omitempty" bson:"is_super_user"`
-	Roles        []string               `json:"roles" bson:"roles"`
-	Data         map[string]interface{} `json:"data" bson:"data"`
-	CrTime       int64                  `json:"cr_time,omitempty" bson:"cr_time"`
-	ChTime       int64                  `json:"ch_time,omitempty" bson:"ch_time"`
+	InternalID        string                 `json:"internal_id" bson:"internal_id"`
+	Username          string                 `json:"username" bson:"username" validate:"required"`
+	Email             string                 `json:"email" bson:"email" validate:"required,email"`
+	EmailVerified     bool                   `json:"email_verified" bson:"email_verified"`
+	PasswordHash      string                 `json:"password_hash,omitempty" bson:"password_hash"`
+	PasswordHistory   []string               `json:"password_history,omitempty" bson:"password_history"`
+	PasswordChangedAt int64                  `json:"password_changed_at,omitempty" bson:"password_changed_at"`
+	IsActive          bool                   `json:"is_active" bson:"is_active"`
+	IsSuperUser       bool                   `json:"is_super_user,omitempty" bson:"is_super_user"`
+	Roles             []string               `json:"roles" bson:"roles"`
+	RoleAssignments   []RoleAssignment       `json:"role_assignments,omitempty" bson:"role_assignments"`
+	Tenants           []string               `json:"tenants,omitempty" bson:"tenants"`
+	Data              map[string]interface{} `json:"data" bson:"data"`
+
+	MFAEnabled       bool     `json:"mfa_enabled" bson:"mfa_enabled"`
+	MFASecret        string   `json:"mfa_secret,omitempty" bson:"mfa_secret"`
+	MFAPendingSecret string   `json:"mfa_pending_secret,omitempty" bson:"mfa_pending_secret"`
+	MFARecoveryCodes []string `json:"mfa_recovery_codes,omitempty" bson:"mfa_recovery_codes"`
+	MFALastStep      int64    `json:"mfa_last_step,omitempty" bson:"mfa_last_step"`
+
+	FailedLogins      int   `json:"failed_logins,omitempty" bson:"failed_logins"`
+	LastFailedLoginAt int64 `json:"last_failed_login_at,omitempty" bson:"last_failed_login_at"`
+	LockedUntil       int64 `json:"locked_until,omitempty" bson:"locked_until"`
+
+	EmailVerificationSentAt int64 `json:"email_verification_sent_at,omitempty" bson:"email_verification_sent_at"`
+
+	RegistrationStatus string `json:"registration_status,omitempty" bson:"registration_status"`
+
+	CrTime int64 `json:"cr_time,omitempty" bson:"cr_time"`
+	ChTime int64 `json:"ch_time,omitempty" bson:"ch_time"`
+}
+
+// RoleAssignment limits when one of the user's roles is in effect; roles
+// without an assignment never expire. Times are Unix nanoseconds and zero
+// leaves that side of the window open.
+type RoleAssignment struct {
+	RoleID    string `json:"role_id" bson:"role_id"`
+	NotBefore int64  `json:"not_before,omitempty" bson:"not_before"`
+	ExpiresAt int64  `json:"expires_at,omitempty" bson:"expires_at"`
+}
+
+// Active reports whether the assignment window contains now.
+func (a *RoleAssignment) Active(now int64) bool {
+	return (a.NotBefore == 0 || a.NotBefore <= now) && (a.ExpiresAt == 0 || now < a.ExpiresAt)
+}
+
+// ActiveRoles returns the user's roles whose assignment is in effect at now.
+func (u *User) ActiveRoles(now int64) []string {
+	if len(u.RoleAssignments) == 0 {
+		return u.Roles
+	}
+
+	windows := make(map[string]*RoleAssignment, len(u.RoleAssignments))
+	for i := range u.RoleAssignments {
+		windows[u.RoleAssignments[i].RoleID] = &u.RoleAssignments[i]
+	}
+
+	active := make([]string, 0, len(u.Roles))
+	for _, roleID := range u.Roles {
+		if window, ok := windows[roleID]; ok && !window.Active(now) {
+			continue
+		}
+		active = append(active, roleID)
+	}
+
+	return active
+}
+
+// RegistrationPending marks self-registered users waiting for approval.
+const RegistrationPending = "pending"
+
+// ClearSecrets blanks credential material before a user leaves the service.
+func (u *User) ClearSecrets() {
+	u.PasswordHash = ""
+	u.PasswordHistory = nil
+	u.MFASecret = ""
+	u.MFAPendingSecret = ""
+	u.MFARecoveryCodes = nil
+	u.MFALastStep = 0
 }
 
 type CreateUserRequest struct {
-	Username string                 `json:"username" validate:"required"`
-	Email    string                 `json:"email" validate:"required,email"`
-	Password string                 `json:"password" validate:"required,min=8"`
-	IsActive *bool                  `json:"is_active"`
-	Data     map[string]interface{} `json:"data"`
+	Username string `json:"username" validate:"required"`
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required,min=8"`
+	IsActive *bool  `json:"is_active"`
+	// EmailVerified lets an admin skip verification for a known address.
+	EmailVerified bool                   `json:"email_verified"`
+	Data          map[string]interface{} `json:"data"`
 }
 
 type LoginRequest struct {
-	User     string `json:"user" validate:"required"`
-	Password string `json:"password" validate:"required"`
-	Renew    bool   `json:"renew,omitempty"`
+	User      string `json:"user" validate:"required"`
+	Password  string `json:"password" validate:"required"`
+	Renew     bool   `json:"renew,omitempty"`
+	TenantID  string `json:"tenant_id,omitempty"`
+	UserAgent string `json:"-"`
+	IP        string `json:"-"`
+}
+
+type ChangePasswordRequest struct {
+	User            string `json:"user,omitempty"`
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required"`
+	Code            string `json:"code,omitempty"`
+	UserAgent       string `json:"-"`
+	IP              string `json:"-"`
 }
 
 type RefreshTokenRequest struct {
 	RefreshToken string `json:"refresh_token" validate:"required"`
+	UserAgent    string `json:"-"`
+	IP           string `json:"-"`
+}
+
+// AssignRolesRequest adds roles to a user. With NotBefore or ExpiresAt (Unix
+// nanoseconds) the roles are only in effect within that window; without
+// them earlier windows of these roles are lifted.
+type AssignRolesRequest struct {
+	RoleIDs   []string `json:"role_ids" validate:"required"`
+	NotBefore int64    `json:"not_before,omitempty"`
+	ExpiresAt int64    `json:"expires_at,omitempty"`
+}
+
+type SetupRequest struct {
+	SetupToken string `json:"setup_token" validate:"required"`
+	Username   string `json:"username" validate:"required"`
+	Email      string `json:"email" validate:"required,email"`
+	Password   string `json:"password" validate:"required"`
+}
+
+type RegisterRequest struct {
+	Username   string `json:"username" validate:"required"`
+	Email      string `json:"email" validate:"required,email"`
+	Password   string `json:"password" validate:"required"`
+	InviteCode string `json:"invite_code,omitempty"`
+	UserAgent  string `json:"-"`
+	IP         string `json:"-"`
 }
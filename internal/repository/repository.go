@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"context"
+
 	"github.com/saiset-co/sai-auth/internal/models"
 	"github.com/saiset-co/sai-auth/types"
 	saiTypes "github.com/saiset-co/sai-service/types"
@@ -21,7 +23,7 @@ type UserRepository interface {
 type RoleRepository interface {
 	Create(ctx *saiTypes.RequestCtx, role *models.Role) error
 	GetByID(ctx *saiTypes.RequestCtx, id string) (*models.Role, error)
-	GetByName(ctx *saiTypes.RequestCtx, name string) (*models.Role, error)
+	GetByName(ctx *saiTypes.RequestCtx, tenantID, name string) (*models.Role, error)
 	GetByIDs(ctx *saiTypes.RequestCtx, ids []string) ([]*models.Role, error)
 	Update(ctx *saiTypes.RequestCtx, filter, data map[string]interface{}) error
 	Delete(ctx *saiTypes.RequestCtx, filter map[string]interface{}) error
@@ -29,20 +31,89 @@ type RoleRepository interface {
 	GetUsersByRole(ctx *saiTypes.RequestCtx, roleID string) ([]string, error)
 }
 
+type TenantRepository interface {
+	Create(ctx *saiTypes.RequestCtx, tenant *models.Tenant) error
+	GetByID(ctx *saiTypes.RequestCtx, id string) (*models.Tenant, error)
+	GetByName(ctx *saiTypes.RequestCtx, name string) (*models.Tenant, error)
+	Update(ctx *saiTypes.RequestCtx, filter, data map[string]interface{}) error
+	List(ctx *saiTypes.RequestCtx, filter *types.TenantFilterRequest) ([]*models.Tenant, int64, error)
+}
+
+type GroupRepository interface {
+	Create(ctx *saiTypes.RequestCtx, group *models.Group) error
+	GetByID(ctx *saiTypes.RequestCtx, id string) (*models.Group, error)
+	GetByName(ctx *saiTypes.RequestCtx, name string) (*models.Group, error)
+	GetByIDs(ctx *saiTypes.RequestCtx, ids []string) ([]*models.Group, error)
+	Update(ctx *saiTypes.RequestCtx, filter, data map[string]interface{}) error
+	Delete(ctx *saiTypes.RequestCtx, filter map[string]interface{}) error
+	List(ctx *saiTypes.RequestCtx, filter *types.GroupFilterRequest) ([]*models.Group, int64, error)
+}
+
 type TokenRepository interface {
 	Store(ctx *saiTypes.RequestCtx, token *models.Token) error
 	GetByAccessToken(ctx *saiTypes.RequestCtx, accessToken string) (*models.Token, error)
 	GetByRefreshToken(ctx *saiTypes.RequestCtx, refreshToken string) (*models.Token, error)
+	GetByRotatedRefreshToken(ctx *saiTypes.RequestCtx, refreshToken string) (*models.Token, error)
 	GetByUserID(ctx *saiTypes.RequestCtx, userID string) (*models.Token, error)
+	ListByUserID(ctx *saiTypes.RequestCtx, userID string) ([]*models.Token, error)
 	Update(ctx *saiTypes.RequestCtx, token *models.Token) error
+	Touch(ctx *saiTypes.RequestCtx, tokenID string, lastUsedAt int64) error
 	Delete(ctx *saiTypes.RequestCtx, tokenID string) error
 	DeleteByUserID(ctx *saiTypes.RequestCtx, userID string) error
+	DeleteExpiredByUserID(ctx *saiTypes.RequestCtx, userID string) error
+	DeleteByFamilyID(ctx *saiTypes.RequestCtx, familyID string) error
 	List(ctx *saiTypes.RequestCtx, filter *types.TokenFilterRequest) ([]*models.Token, int64, error)
 	IsValid(ctx *saiTypes.RequestCtx, accessToken string) bool
+	MigratePlaintextTokens(ctx *saiTypes.RequestCtx) (int, error)
+}
+
+type SecurityEventRepository interface {
+	Create(ctx *saiTypes.RequestCtx, event *models.SecurityEvent) error
+	List(ctx *saiTypes.RequestCtx, filter *types.SecurityEventFilterRequest) ([]*models.SecurityEvent, int64, error)
+}
+
+type SigningKeyRepository interface {
+	Create(ctx *saiTypes.RequestCtx, key *models.SigningKey) error
+	ListActive(ctx *saiTypes.RequestCtx, after int64) ([]*models.SigningKey, error)
+	DeleteExpired(ctx *saiTypes.RequestCtx, before int64) error
+}
+
+type MFAChallengeRepository interface {
+	Create(ctx *saiTypes.RequestCtx, challenge *models.MFAChallenge) error
+	GetByToken(ctx *saiTypes.RequestCtx, token string) (*models.MFAChallenge, error)
+	ListByUserID(ctx *saiTypes.RequestCtx, userID string) ([]*models.MFAChallenge, error)
+	IncrementAttempts(ctx *saiTypes.RequestCtx, challengeID string) error
+	Delete(ctx *saiTypes.RequestCtx, challengeID string) error
+}
+
+type ActionTokenRepository interface {
+	Create(ctx *saiTypes.RequestCtx, token *models.ActionToken) error
+	GetByToken(ctx *saiTypes.RequestCtx, purpose, token string) (*models.ActionToken, error)
+	Delete(ctx *saiTypes.RequestCtx, tokenID string) error
+	DeleteByUserID(ctx *saiTypes.RequestCtx, userID, purpose string) error
+}
+
+type InvitationRepository interface {
+	Create(ctx *saiTypes.RequestCtx, invitation *models.Invitation) error
+	GetByID(ctx *saiTypes.RequestCtx, id string) (*models.Invitation, error)
+	GetByToken(ctx *saiTypes.RequestCtx, token string) (*models.Invitation, error)
+	List(ctx *saiTypes.RequestCtx, filter *types.InvitationFilterRequest) ([]*models.Invitation, int64, error)
+	Update(ctx *saiTypes.RequestCtx, filter, data map[string]interface{}) error
+}
+
+type RateLimiter interface {
+	CheckRates(ctx context.Context, key string, rates []models.Rate) (*models.RateLimitStatus, error)
 }
 
 type Repositories struct {
-	User  UserRepository
-	Role  RoleRepository
-	Token TokenRepository
+	User          UserRepository
+	Role          RoleRepository
+	Group         GroupRepository
+	Tenant        TenantRepository
+	Token         TokenRepository
+	SecurityEvent SecurityEventRepository
+	SigningKey    SigningKeyRepository
+	MFAChallenge  MFAChallengeRepository
+	ActionToken   ActionTokenRepository
+	Invitation    InvitationRepository
 }
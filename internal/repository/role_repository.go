@@ -60,10 +60,10 @@ func (r *MongoRoleRepository) GetByID(ctx *saiTypes.RequestCtx, id string) (*mod
 	return &result.Data[0], nil
 }
 
-func (r *MongoRoleRepository) GetByName(ctx *saiTypes.RequestCtx, name string) (*models.Role, error) {
+func (r *MongoRoleRepository) GetByName(ctx *saiTypes.RequestCtx, tenantID, name string) (*models.Role, error) {
 	reqData := map[string]interface{}{
 		"collection": "roles",
-		"filter":     map[string]interface{}{"name": name},
+		"filter":     map[string]interface{}{"name": name, "tenant_id": tenantFilter(tenantID)},
 		"limit":      1,
 	}
 
@@ -174,6 +174,10 @@ func (r *MongoRoleRepository) List(ctx *saiTypes.RequestCtx, filter *types.RoleF
 		mongoFilter["is_active"] = *filter.Active
 	}
 
+	if filter.TenantID != "" {
+		mongoFilter["tenant_id"] = filter.TenantID
+	}
+
 	page := filter.Page
 	if page < 1 {
 		page = 1
@@ -248,3 +252,12 @@ func (r *MongoRoleRepository) GetUsersByRole(ctx *saiTypes.RequestCtx, roleID st
 
 	return userIDs, nil
 }
+
+// tenantFilter matches the role tenant; global roles created before tenants
+// existed have no tenant_id at all.
+func tenantFilter(tenantID string) interface{} {
+	if tenantID == "" {
+		return map[string]interface{}{"$in": []interface{}{"", nil}}
+	}
+	return tenantID
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/saiset-co/sai-auth/internal/models"
+	saiTypes "github.com/saiset-co/sai-service/types"
+)
+
+// AdminMicroservice is the permission namespace of sai-auth's own admin API.
+// Role permissions with this microservice and the API paths grant access to
+// /users, /roles, /groups and /invitations.
+const AdminMicroservice = "sai-auth"
+
+const (
+	AdminRole       = "sai-auth-admin"
+	UserManagerRole = "sai-auth-user-manager"
+	RoleManagerRole = "sai-auth-role-manager"
+	AuditorRole     = "sai-auth-auditor"
+)
+
+const (
+	adminUsersPath       = "/api/v1/users*"
+	adminRolesPath       = "/api/v1/roles*"
+	adminInvitationsPath = "/api/v1/invitations*"
+	adminGroupsPath      = "/api/v1/groups*"
+	adminTenantsPath     = "/api/v1/tenants*"
+	adminTokensPath      = "/api/v1/auth/tokens/migrate"
+)
+
+// adminRoles are seeded at startup when no role with the same name exists,
+// so they can be edited or extended afterwards. Only the admin role may grant
+// roles; other managers need grantable_roles configured for their teams.
+var adminRoles = map[string][]models.Permission{
+	AdminRole: adminPermissions(
+		adminPaths(adminUsersPath, "GET", "POST", "PUT", "DELETE"),
+		adminPaths(adminRolesPath, "GET", "POST", "PUT", "DELETE"),
+		adminPaths(adminInvitationsPath, "GET", "POST"),
+		adminPaths(adminGroupsPath, "GET", "POST", "PUT", "DELETE"),
+		adminPaths(adminTenantsPath, "GET", "POST", "PUT"),
+		adminPaths(adminTokensPath, "POST"),
+	),
+	UserManagerRole: adminPermissions(
+		adminPaths(adminUsersPath, "GET", "POST", "PUT", "DELETE"),
+		adminPaths(adminInvitationsPath, "GET", "POST"),
+		adminPaths(adminGroupsPath, "GET", "POST", "PUT", "DELETE"),
+		adminPaths(adminRolesPath, "GET"),
+	),
+	RoleManagerRole: adminPermissions(
+		adminPaths(adminRolesPath, "GET", "POST", "PUT", "DELETE"),
+	),
+	AuditorRole: adminPermissions(
+		adminPaths(adminUsersPath, "GET"),
+		adminPaths(adminRolesPath, "GET"),
+		adminPaths(adminInvitationsPath, "GET"),
+		adminPaths(adminGroupsPath, "GET"),
+		adminPaths(adminTenantsPath, "GET"),
+	),
+}
+
+func adminPaths(path string, methods ...string) []models.Permission {
+	permissions := make([]models.Permission, 0, len(methods))
+	for _, method := range methods {
+		permissions = append(permissions, models.Permission{
+			Microservice:     AdminMicroservice,
+			Method:           method,
+			Path:             path,
+			Rates:            []models.Rate{},
+			RequiredParams:   []models.Params{},
+			RestrictedParams: []models.Params{},
+		})
+	}
+	return permissions
+}
+
+func adminPermissions(groups ...[]models.Permission) []models.Permission {
+	var permissions []models.Permission
+	for _, group := range groups {
+		permissions = append(permissions, group...)
+	}
+	return permissions
+}
+
+// SeedAdminRoles creates the built-in admin roles that do not exist yet.
+func (s *RoleService) SeedAdminRoles(ctx *saiTypes.RequestCtx) error {
+	for name, permissions := range adminRoles {
+		if _, err := s.roleRepo.GetByName(ctx, "", name); err == nil {
+			continue
+		}
+
+		now := time.Now().UnixNano()
+		role := &models.Role{
+			InternalID:     uuid.New().String(),
+			Name:           name,
+			IsActive:       true,
+			ParentRoles:    []string{},
+			Permissions:    permissions,
+			GrantableRoles: []models.GrantableRole{},
+			Data:           map[string]interface{}{"built_in": true},
+			CrTime:         now,
+			ChTime:         now,
+		}
+
+		if name == AdminRole {
+			role.GrantableRoles = []models.GrantableRole{{RoleID: "*"}}
+		}
+
+		if err := s.roleRepo.Create(ctx, role); err != nil {
+			return fmt.Errorf("failed to create role %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
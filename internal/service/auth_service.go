@@ -3,23 +3,42 @@ package service
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"net"
 	"strings"
 	"time"
 
-	"golang.org/x/crypto/bcrypt"
+	"github.com/google/uuid"
 
 	"github.com/saiset-co/sai-auth/internal/models"
 	"github.com/saiset-co/sai-auth/internal/repository"
+	"github.com/saiset-co/sai-auth/internal/storage"
+	"github.com/saiset-co/sai-auth/pkg/clientip"
+	"github.com/saiset-co/sai-auth/pkg/hasher"
+	"github.com/saiset-co/sai-auth/pkg/jwt"
 	"github.com/saiset-co/sai-auth/types"
+	"github.com/saiset-co/sai-service/sai"
 	saiTypes "github.com/saiset-co/sai-service/types"
+	"go.uber.org/zap"
 )
 
+const sessionTouchInterval = time.Minute
+
 type AuthService struct {
 	userRepo      repository.UserRepository
 	roleRepo      repository.RoleRepository
 	tokenRepo     repository.TokenRepository
+	eventRepo     repository.SecurityEventRepository
 	permissionSvc *PermissionService
+	rateLimiter   repository.RateLimiter
+	keySvc        *KeyService
+	mfaSvc        *MFAService
+	lockoutSvc    *LockoutService
+	tenantSvc     *TenantService
+	passwordPol   *PasswordPolicy
+	hasher        hasher.Hasher
+	superUserIPs  []*net.IPNet
 	config        *types.SaiAuthConfig
 }
 
@@ -27,96 +46,404 @@ func NewAuthService(
 	userRepo repository.UserRepository,
 	roleRepo repository.RoleRepository,
 	tokenRepo repository.TokenRepository,
+	eventRepo repository.SecurityEventRepository,
 	permissionSvc *PermissionService,
 	config *types.SaiAuthConfig,
 ) *AuthService {
+	passwordHasher := newPasswordHasher(config)
+
 	return &AuthService{
 		userRepo:      userRepo,
 		roleRepo:      roleRepo,
 		tokenRepo:     tokenRepo,
+		eventRepo:     eventRepo,
 		permissionSvc: permissionSvc,
+		passwordPol:   NewPasswordPolicy(&config.PasswordPolicy, passwordHasher),
+		hasher:        passwordHasher,
 		config:        config,
 	}
 }
 
+// newPasswordHasher hashes with the configured algorithm and still verifies
+// the other one, so existing hashes keep working until they are rehashed.
+func newPasswordHasher(config *types.SaiAuthConfig) hasher.Hasher {
+	bcryptHasher := hasher.NewBcrypt(config.BcryptCost)
+	argon2idHasher := hasher.NewArgon2id(hasher.Argon2idParams{
+		Memory:      config.PasswordHash.Argon2id.Memory,
+		Iterations:  config.PasswordHash.Argon2id.Iterations,
+		Parallelism: config.PasswordHash.Argon2id.Parallelism,
+		SaltLength:  config.PasswordHash.Argon2id.SaltLength,
+		KeyLength:   config.PasswordHash.Argon2id.KeyLength,
+	})
+
+	switch config.PasswordHash.Algorithm {
+	case "argon2id":
+		return hasher.NewChain(argon2idHasher, bcryptHasher)
+	case "", "bcrypt":
+		return hasher.NewChain(bcryptHasher, argon2idHasher)
+	default:
+		sai.Logger().Warn("Unknown password hash algorithm, using bcrypt",
+			zap.String("algorithm", config.PasswordHash.Algorithm))
+		return hasher.NewChain(bcryptHasher, argon2idHasher)
+	}
+}
+
+func (s *AuthService) SetRateLimiter(rateLimiter repository.RateLimiter) {
+	s.rateLimiter = rateLimiter
+}
+
+// SetKeyService switches access tokens from opaque random strings to JWTs
+// signed with the keys managed by keySvc.
+func (s *AuthService) SetKeyService(keySvc *KeyService) {
+	s.keySvc = keySvc
+}
+
+// SetSuperUserNetworks limits superuser logins and requests to the given
+// networks.
+func (s *AuthService) SetSuperUserNetworks(networks []*net.IPNet) {
+	s.superUserIPs = networks
+}
+
+func (s *AuthService) SetMFAService(mfaSvc *MFAService) {
+	s.mfaSvc = mfaSvc
+}
+
+func (s *AuthService) SetLockoutService(lockoutSvc *LockoutService) {
+	s.lockoutSvc = lockoutSvc
+}
+
+// SetTenantService lets sessions act in a tenant, compiling the global roles
+// together with the roles of that tenant.
+func (s *AuthService) SetTenantService(tenantSvc *TenantService) {
+	s.tenantSvc = tenantSvc
+}
+
 func (s *AuthService) Login(ctx *saiTypes.RequestCtx, req *models.LoginRequest) (*models.AuthResponse, error) {
+	if s.lockoutSvc != nil {
+		if err := s.lockoutSvc.CheckIP(ctx, req.IP); err != nil {
+			return nil, err
+		}
+	}
+
 	user, err := s.findUser(ctx, req.User)
 	if err != nil {
+		if s.lockoutSvc != nil {
+			s.lockoutSvc.RecordFailure(ctx, nil, req.User, req.IP, req.UserAgent)
+		}
 		return nil, fmt.Errorf("invalid credentials")
 	}
 
 	if !user.IsActive {
+		if user.RegistrationStatus == models.RegistrationPending {
+			return nil, fmt.Errorf("registration is pending approval")
+		}
 		return nil, fmt.Errorf("user account is inactive")
 	}
 
-	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password))
-	if err != nil {
+	if s.lockoutSvc != nil {
+		if err := s.lockoutSvc.CheckUser(user); err != nil {
+			return nil, err
+		}
+	}
+
+	if !s.hasher.Verify(user.PasswordHash, req.Password) {
+		if s.lockoutSvc != nil {
+			s.lockoutSvc.RecordFailure(ctx, user, req.User, req.IP, req.UserAgent)
+		}
 		return nil, fmt.Errorf("invalid credentials")
 	}
 
-	if len(user.Roles) == 0 && !user.IsSuperUser {
+	s.rehashPassword(ctx, user, req.Password)
+
+	if s.passwordPol.Expired(user) {
+		return nil, fmt.Errorf("password expired")
+	}
+
+	if s.emailUnverified(user) && s.config.EmailVerification.Mode == EmailVerificationRequired {
+		return nil, fmt.Errorf("email not verified")
+	}
+
+	if !user.IsSuperUser && !s.permissionSvc.HasRoles(ctx, user) {
 		return nil, fmt.Errorf("user has no roles assigned")
 	}
 
-	existingToken, err := s.tokenRepo.GetByUserID(ctx, user.InternalID)
-	if err == nil && existingToken != nil && existingToken.ExpiresAt > time.Now().UnixNano() {
-		if req.Renew {
-			permissions, err := s.permissionSvc.CompilePermissions(ctx, user)
-			if err != nil {
-				return nil, fmt.Errorf("failed to compile permissions: %w", err)
-			}
-			existingToken.CompiledPermissions = permissions
-		}
-		
-		existingToken.ExpiresAt = time.Now().Add(s.config.AccessTokenTTL).UnixNano()
-		existingToken.RefreshExpiresAt = time.Now().Add(s.config.RefreshTokenTTL).UnixNano()
-		err = s.tokenRepo.Update(ctx, existingToken)
+	if !s.superUserAllowed(ctx, user, req.IP, req.UserAgent, "login") {
+		return nil, fmt.Errorf("superuser login is not allowed from this IP")
+	}
+
+	tenantID, err := s.sessionTenant(ctx, user, req.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.MFAEnabled && s.mfaSvc != nil {
+		challenge, err := s.mfaSvc.CreateChallenge(ctx, user, req.Renew, tenantID)
 		if err != nil {
-			return nil, fmt.Errorf("failed to update token: %w", err)
+			return nil, fmt.Errorf("failed to create mfa challenge: %w", err)
 		}
-		
-		user.PasswordHash = ""
+
 		return &models.AuthResponse{
-			User: user,
-			Tokens: &models.TokenResponse{
-				AccessToken:  existingToken.AccessToken,
-				RefreshToken: existingToken.RefreshToken,
-				ExpiresIn:    (existingToken.ExpiresAt - time.Now().UnixNano()) / int64(time.Second),
-			},
-			Permissions: existingToken.CompiledPermissions,
+			MFARequired:  true,
+			MFAToken:     challenge.Token,
+			MFAExpiresIn: (challenge.ExpiresAt - time.Now().UnixNano()) / int64(time.Second),
 		}, nil
 	}
 
-	s.tokenRepo.DeleteByUserID(ctx, user.InternalID)
+	// Failures are only forgiven once every factor has passed.
+	if s.lockoutSvc != nil {
+		s.lockoutSvc.RecordSuccess(ctx, user)
+	}
 
-	permissions, err := s.permissionSvc.CompilePermissions(ctx, user)
+	return s.startSession(ctx, user, tenantID, req.Renew, req.UserAgent, req.IP)
+}
+
+// VerifyMFA completes a login that was paused for a second factor.
+func (s *AuthService) VerifyMFA(ctx *saiTypes.RequestCtx, req *models.MFAVerifyRequest) (*models.AuthResponse, error) {
+	if s.mfaSvc == nil {
+		return nil, fmt.Errorf("invalid or expired mfa token")
+	}
+
+	if s.lockoutSvc != nil {
+		if err := s.lockoutSvc.CheckIP(ctx, req.IP); err != nil {
+			return nil, err
+		}
+	}
+
+	user, challenge, err := s.mfaSvc.OpenChallenge(ctx, req.MFAToken)
 	if err != nil {
-		return nil, fmt.Errorf("failed to compile permissions: %w", err)
+		return nil, err
+	}
+
+	if s.lockoutSvc != nil {
+		if err := s.lockoutSvc.CheckUser(user); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.mfaSvc.VerifyChallenge(ctx, user, challenge, req.Code); err != nil {
+		if s.lockoutSvc != nil {
+			s.lockoutSvc.RecordFailure(ctx, user, user.Username, req.IP, req.UserAgent)
+		}
+		return nil, err
+	}
+
+	if !user.IsActive {
+		return nil, fmt.Errorf("user account is inactive")
+	}
+
+	if !user.IsSuperUser && !s.permissionSvc.HasRoles(ctx, user) {
+		return nil, fmt.Errorf("user has no roles assigned")
+	}
+
+	if !s.superUserAllowed(ctx, user, req.IP, req.UserAgent, "mfa") {
+		return nil, fmt.Errorf("superuser login is not allowed from this IP")
+	}
+
+	tenantID, err := s.sessionTenant(ctx, user, challenge.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.lockoutSvc != nil {
+		s.lockoutSvc.RecordSuccess(ctx, user)
+	}
+
+	return s.startSession(ctx, user, tenantID, challenge.Renew, req.UserAgent, req.IP)
+}
+
+func (s *AuthService) EnrollMFA(ctx *saiTypes.RequestCtx, accessToken, ip, userAgent string) (*models.MFAEnrollResponse, error) {
+	_, user, err := s.sessionByAccessToken(ctx, accessToken, ip, userAgent, "mfa")
+	if err != nil {
+		return nil, err
+	}
+
+	return s.mfaSvc.Enroll(ctx, user)
+}
+
+func (s *AuthService) ConfirmMFA(ctx *saiTypes.RequestCtx, accessToken, code, ip, userAgent string) (*models.MFAConfirmResponse, error) {
+	_, user, err := s.sessionByAccessToken(ctx, accessToken, ip, userAgent, "mfa")
+	if err != nil {
+		return nil, err
+	}
+
+	return s.mfaSvc.Confirm(ctx, user, code)
+}
+
+func (s *AuthService) DisableMFA(ctx *saiTypes.RequestCtx, accessToken, code, ip, userAgent string) error {
+	_, user, err := s.sessionByAccessToken(ctx, accessToken, ip, userAgent, "mfa")
+	if err != nil {
+		return err
+	}
+
+	return s.mfaSvc.Disable(ctx, user, code)
+}
+
+// sessionByAccessToken resolves the session and user behind an access token
+// for the self-service endpoints, refusing superusers outside their allowed
+// networks just like login does.
+func (s *AuthService) sessionByAccessToken(ctx *saiTypes.RequestCtx, accessToken, ip, userAgent, action string) (*models.Token, *models.User, error) {
+	token, err := s.tokenRepo.GetByAccessToken(ctx, accessToken)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid token")
+	}
+
+	user, err := s.userRepo.GetByID(ctx, token.UserID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid token")
+	}
+
+	if !s.superUserAllowed(ctx, user, ip, userAgent, action) {
+		return nil, nil, fmt.Errorf("superuser access is not allowed from this IP")
+	}
+
+	return token, user, nil
+}
+
+func (s *AuthService) startSession(ctx *saiTypes.RequestCtx, user *models.User, tenantID string, renew bool, userAgent, ip string) (*models.AuthResponse, error) {
+	var permissions []models.CompiledPermission
+
+	latestToken, err := s.tokenRepo.GetByUserID(ctx, user.InternalID)
+	if err == nil && latestToken != nil && latestToken.TenantID == tenantID && !renew {
+		permissions = latestToken.CompiledPermissions
+	} else {
+		permissions, err = s.sessionPermissions(ctx, user, tenantID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile permissions: %w", err)
+		}
 	}
 
-	token, err := s.generateToken(user.InternalID, permissions)
+	token, err := s.generateToken(ctx, user, tenantID, permissions)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate token: %w", err)
 	}
 
+	token.UserAgent = userAgent
+	token.IP = ip
+
 	err = s.tokenRepo.Store(ctx, token)
 	if err != nil {
 		return nil, fmt.Errorf("failed to store token: %w", err)
 	}
 
-	user.PasswordHash = ""
+	// Every login adds a session, so drop the ones that can no longer be
+	// refreshed.
+	if err := s.tokenRepo.DeleteExpiredByUserID(ctx, user.InternalID); err != nil {
+		sai.Logger().Warn("Failed to prune expired sessions", zap.Error(err), zap.String("user_id", user.InternalID))
+	}
+
+	user.ClearSecrets()
 
 	return &models.AuthResponse{
-		User: user,
-		Tokens: &models.TokenResponse{
-			AccessToken:  token.AccessToken,
-			RefreshToken: token.RefreshToken,
-			ExpiresIn:    (token.ExpiresAt - time.Now().UnixNano()) / int64(time.Second),
-		},
+		User:        user,
+		Tokens:      s.tokenResponse(token),
 		Permissions: permissions,
 	}, nil
 }
 
+// emailUnverified reports whether the email verification mode holds the user
+// back. Superusers are exempt.
+func (s *AuthService) emailUnverified(user *models.User) bool {
+	mode := s.config.EmailVerification.Mode
+	if mode != EmailVerificationRequired && mode != EmailVerificationRestricted {
+		return false
+	}
+	return !user.EmailVerified && !user.IsSuperUser
+}
+
+// sessionTenant resolves the tenant of a new session; without a tenant
+// service sessions never have one.
+func (s *AuthService) sessionTenant(ctx *saiTypes.RequestCtx, user *models.User, requested string) (string, error) {
+	if s.tenantSvc == nil {
+		if requested != "" {
+			return "", fmt.Errorf("tenant not found")
+		}
+		return "", nil
+	}
+
+	return s.tenantSvc.SessionTenant(ctx, user, requested)
+}
+
+// sessionUsable reports whether a session in tenantID may still be used by
+// user: they are still a member and the tenant is active.
+func (s *AuthService) sessionUsable(ctx *saiTypes.RequestCtx, user *models.User, tenantID string) bool {
+	if tenantID == "" {
+		return true
+	}
+
+	if !isTenantMember(user, tenantID) {
+		return false
+	}
+
+	return s.tenantSvc != nil && s.tenantSvc.TenantActive(ctx, tenantID)
+}
+
+// sessionPermissions compiles the permissions stored on a user's sessions in
+// tenantID. Unverified users in restricted mode only get the restricted role.
+func (s *AuthService) sessionPermissions(ctx *saiTypes.RequestCtx, user *models.User, tenantID string) ([]models.CompiledPermission, error) {
+	if !s.emailUnverified(user) || s.config.EmailVerification.Mode != EmailVerificationRestricted {
+		return s.permissionSvc.CompilePermissions(ctx, user, tenantID)
+	}
+
+	restricted := *user
+	restricted.RoleAssignments = nil
+	restricted.Roles = []string{}
+	if role := s.config.EmailVerification.RestrictedRole; role != "" {
+		restricted.Roles = []string{role}
+	}
+
+	return s.permissionSvc.CompilePermissions(ctx, &restricted, tenantID)
+}
+
+// refreshSessionPermissions recompiles the permissions of every session of
+// user, e.g. after the email verification state or their roles changed.
+// Sessions in tenants the user no longer belongs to are revoked.
+func (s *AuthService) refreshSessionPermissions(ctx *saiTypes.RequestCtx, user *models.User) {
+	tokens, err := s.tokenRepo.ListByUserID(ctx, user.InternalID)
+	if err != nil || len(tokens) == 0 {
+		return
+	}
+
+	compiled := make(map[string][]models.CompiledPermission)
+	for _, token := range tokens {
+		if !isTenantMember(user, token.TenantID) {
+			s.tokenRepo.Delete(ctx, token.InternalID)
+			continue
+		}
+
+		permissions, ok := compiled[token.TenantID]
+		if !ok {
+			permissions, err = s.sessionPermissions(ctx, user, token.TenantID)
+			if err != nil {
+				sai.Logger().Error("Failed to recompile session permissions", zap.Error(err), zap.String("user_id", user.InternalID))
+				return
+			}
+			compiled[token.TenantID] = permissions
+		}
+
+		token.CompiledPermissions = permissions
+		token.PermissionsChangedAt = time.Now().UnixNano()
+		s.tokenRepo.Update(ctx, token)
+	}
+}
+
+// enforceEmailVerification brings the sessions of a user whose email became
+// unverified in line with the mode: revoked when verification is required,
+// narrowed to the restricted role when restricted.
+func (s *AuthService) enforceEmailVerification(ctx *saiTypes.RequestCtx, user *models.User) {
+	if !s.emailUnverified(user) {
+		return
+	}
+
+	if s.config.EmailVerification.Mode == EmailVerificationRequired {
+		if err := s.tokenRepo.DeleteByUserID(ctx, user.InternalID); err != nil {
+			sai.Logger().Error("Failed to revoke sessions of unverified user", zap.Error(err), zap.String("user_id", user.InternalID))
+		}
+		return
+	}
+
+	s.refreshSessionPermissions(ctx, user)
+}
+
 func (s *AuthService) findUser(ctx *saiTypes.RequestCtx, user string) (*models.User, error) {
 	if strings.Contains(user, "@") {
 		return s.userRepo.GetByEmail(ctx, user)
@@ -127,6 +454,9 @@ func (s *AuthService) findUser(ctx *saiTypes.RequestCtx, user string) (*models.U
 func (s *AuthService) RefreshToken(ctx *saiTypes.RequestCtx, req *models.RefreshTokenRequest) (*models.TokenResponse, error) {
 	token, err := s.tokenRepo.GetByRefreshToken(ctx, req.RefreshToken)
 	if err != nil {
+		if reused, reuseErr := s.tokenRepo.GetByRotatedRefreshToken(ctx, req.RefreshToken); reuseErr == nil {
+			s.revokeTokenFamily(ctx, reused, req)
+		}
 		return nil, fmt.Errorf("invalid refresh token")
 	}
 
@@ -140,28 +470,145 @@ func (s *AuthService) RefreshToken(ctx *saiTypes.RequestCtx, req *models.Refresh
 		return nil, fmt.Errorf("user account is inactive")
 	}
 
-	permissions, err := s.permissionSvc.CompilePermissions(ctx, user)
+	if !s.superUserAllowed(ctx, user, req.IP, req.UserAgent, "refresh") {
+		return nil, fmt.Errorf("superuser access is not allowed from this IP")
+	}
+
+	if !s.sessionUsable(ctx, user, token.TenantID) {
+		s.tokenRepo.Delete(ctx, token.InternalID)
+		return nil, fmt.Errorf("tenant is not available")
+	}
+
+	permissions, err := s.sessionPermissions(ctx, user, token.TenantID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to compile permissions: %w", err)
 	}
 
-	newToken, err := s.generateToken(user.InternalID, permissions)
+	refreshToken, err := s.generateRandomString(64)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate token: %w", err)
 	}
 
-	s.tokenRepo.Delete(ctx, token.InternalID)
+	token.RotatedRefreshTokens = append(token.RotatedRefreshTokens, token.RefreshTokenHash)
+	token.RefreshToken = refreshToken
+	token.CompiledPermissions = permissions
+
+	if err := s.issueAccessToken(ctx, token, user); err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
 
-	err = s.tokenRepo.Store(ctx, newToken)
+	err = s.tokenRepo.Update(ctx, token)
 	if err != nil {
-		return nil, fmt.Errorf("failed to store token: %w", err)
+		// Another request rotated the same refresh token first: the token
+		// was used twice, so treat it as reuse.
+		if errors.Is(err, storage.ErrRefreshTokenRotated) {
+			s.revokeTokenFamily(ctx, token, req)
+			return nil, fmt.Errorf("invalid refresh token")
+		}
+		return nil, fmt.Errorf("failed to update token: %w", err)
+	}
+
+	return s.tokenResponse(token), nil
+}
+
+// SwitchTenant moves the session behind accessToken to another tenant of the
+// user, recompiling its permissions and rotating both tokens.
+func (s *AuthService) SwitchTenant(ctx *saiTypes.RequestCtx, accessToken, tenantID, ip, userAgent string) (*models.TokenResponse, error) {
+	token, user, err := s.sessionByAccessToken(ctx, accessToken, ip, userAgent, "switch_tenant")
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.IsActive {
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	if tenantID == "" {
+		return nil, fmt.Errorf("tenant_id is required")
+	}
+
+	tenantID, err = s.sessionTenant(ctx, user, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions, err := s.sessionPermissions(ctx, user, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile permissions: %w", err)
+	}
+
+	refreshToken, err := s.generateRandomString(64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	token.RotatedRefreshTokens = append(token.RotatedRefreshTokens, token.RefreshTokenHash)
+	token.RefreshToken = refreshToken
+	token.TenantID = tenantID
+	token.CompiledPermissions = permissions
+
+	if err := s.issueAccessToken(ctx, token, user); err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	if err := s.tokenRepo.Update(ctx, token); err != nil {
+		if errors.Is(err, storage.ErrRefreshTokenRotated) {
+			return nil, fmt.Errorf("invalid token")
+		}
+		return nil, fmt.Errorf("failed to update token: %w", err)
+	}
+
+	return s.tokenResponse(token), nil
+}
+
+func (s *AuthService) revokeTokenFamily(ctx *saiTypes.RequestCtx, token *models.Token, req *models.RefreshTokenRequest) {
+	familyID := token.FamilyID
+	if familyID == "" {
+		familyID = token.InternalID
+	}
+
+	sai.Logger().Warn("Refresh token reuse detected, revoking token family",
+		zap.String("user_id", token.UserID),
+		zap.String("family_id", familyID),
+		zap.String("ip", req.IP))
+
+	if err := s.tokenRepo.DeleteByFamilyID(ctx, familyID); err != nil {
+		sai.Logger().Error("Failed to revoke token family", zap.Error(err), zap.String("family_id", familyID))
+	}
+	s.tokenRepo.Delete(ctx, token.InternalID)
+
+	s.recordSecurityEvent(ctx, &models.SecurityEvent{
+		Type:      models.SecurityEventRefreshTokenReuse,
+		UserID:    token.UserID,
+		SessionID: token.InternalID,
+		IP:        req.IP,
+		UserAgent: req.UserAgent,
+		Details: map[string]interface{}{
+			"family_id":       familyID,
+			"session_ip":      token.IP,
+			"session_agent":   token.UserAgent,
+			"rotations_count": len(token.RotatedRefreshTokens),
+		},
+	})
+}
+
+func (s *AuthService) recordSecurityEvent(ctx *saiTypes.RequestCtx, event *models.SecurityEvent) {
+	event.InternalID = uuid.New().String()
+	event.CrTime = time.Now().UnixNano()
+
+	if err := s.eventRepo.Create(ctx, event); err != nil {
+		sai.Logger().Error("Failed to record security event", zap.Error(err), zap.String("type", event.Type))
 	}
+}
 
+func (s *AuthService) tokenResponse(token *models.Token) *models.TokenResponse {
 	return &models.TokenResponse{
-		AccessToken:  newToken.AccessToken,
-		RefreshToken: newToken.RefreshToken,
-		ExpiresIn:    (newToken.ExpiresAt - time.Now().UnixNano()) / int64(time.Second),
-	}, nil
+		SessionID:    token.InternalID,
+		TenantID:     token.TenantID,
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		ExpiresIn:    (token.ExpiresAt - time.Now().UnixNano()) / int64(time.Second),
+	}
 }
 
 func (s *AuthService) Logout(ctx *saiTypes.RequestCtx, accessToken string) error {
@@ -173,18 +620,125 @@ func (s *AuthService) Logout(ctx *saiTypes.RequestCtx, accessToken string) error
 	return s.tokenRepo.Delete(ctx, token.InternalID)
 }
 
-func (s *AuthService) GetUserInfo(ctx *saiTypes.RequestCtx, accessToken string) (*models.UserInfoResponse, error) {
+func (s *AuthService) ListSessions(ctx *saiTypes.RequestCtx, accessToken, ip, userAgent string) ([]*models.SessionResponse, error) {
+	current, _, err := s.sessionByAccessToken(ctx, accessToken, ip, userAgent, "sessions")
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := s.tokenRepo.ListByUserID(ctx, current.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	sessions := make([]*models.SessionResponse, 0, len(tokens))
+	for _, token := range tokens {
+		sessions = append(sessions, &models.SessionResponse{
+			SessionID:        token.InternalID,
+			TenantID:         token.TenantID,
+			UserAgent:        token.UserAgent,
+			IP:               token.IP,
+			CreatedAt:        token.CreatedAt,
+			LastUsedAt:       token.LastUsedAt,
+			ExpiresAt:        token.ExpiresAt,
+			RefreshExpiresAt: token.RefreshExpiresAt,
+			Current:          token.InternalID == current.InternalID,
+		})
+	}
+
+	return sessions, nil
+}
+
+func (s *AuthService) RevokeSessions(ctx *saiTypes.RequestCtx, accessToken, ip, userAgent string, req *models.RevokeSessionsRequest) (int, error) {
+	current, _, err := s.sessionByAccessToken(ctx, accessToken, ip, userAgent, "sessions")
+	if err != nil {
+		return 0, err
+	}
+
+	tokens, err := s.tokenRepo.ListByUserID(ctx, current.UserID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	revoked := 0
+	for _, token := range tokens {
+		if !req.All && token.InternalID != req.SessionID {
+			continue
+		}
+
+		if err := s.tokenRepo.Delete(ctx, token.InternalID); err != nil {
+			return revoked, fmt.Errorf("failed to revoke session: %w", err)
+		}
+		revoked++
+	}
+
+	if !req.All && revoked == 0 {
+		return 0, fmt.Errorf("session not found")
+	}
+
+	return revoked, nil
+}
+
+func (s *AuthService) MigrateTokenHashes(ctx *saiTypes.RequestCtx) (int, error) {
+	migrated, err := s.tokenRepo.MigratePlaintextTokens(ctx)
+	if err != nil {
+		return migrated, fmt.Errorf("failed to migrate tokens: %w", err)
+	}
+
+	return migrated, nil
+}
+
+func (s *AuthService) touchSession(ctx *saiTypes.RequestCtx, token *models.Token) {
+	now := time.Now().UnixNano()
+	if now-token.LastUsedAt < int64(sessionTouchInterval) {
+		return
+	}
+
+	if err := s.tokenRepo.Touch(ctx, token.InternalID, now); err != nil {
+		sai.Logger().Warn("Failed to update session last use", zap.Error(err), zap.String("session_id", token.InternalID))
+	}
+}
+
+func (s *AuthService) JWKS(ctx *saiTypes.RequestCtx) (*jwt.JWKSet, error) {
+	if s.keySvc == nil {
+		return nil, fmt.Errorf("jwt access tokens are disabled")
+	}
+
+	return s.keySvc.JWKS(ctx)
+}
+
+// IntrospectToken reports whether the session behind an access token is still
+// alive. Providers verifying JWTs locally use it as their revocation check.
+func (s *AuthService) IntrospectToken(ctx *saiTypes.RequestCtx, accessToken string) (*models.IntrospectResponse, error) {
 	token, err := s.tokenRepo.GetByAccessToken(ctx, accessToken)
 	if err != nil {
-		return nil, fmt.Errorf("invalid token")
+		return &models.IntrospectResponse{Active: false}, nil
 	}
 
 	user, err := s.userRepo.GetByID(ctx, token.UserID)
+	if err != nil || !user.IsActive || !s.sessionUsable(ctx, user, token.TenantID) {
+		return &models.IntrospectResponse{Active: false}, nil
+	}
+
+	s.touchSession(ctx, token)
+
+	return &models.IntrospectResponse{
+		Active:               true,
+		UserID:               token.UserID,
+		SessionID:            token.InternalID,
+		TenantID:             token.TenantID,
+		ExpiresAt:            token.ExpiresAt,
+		PermissionsChangedAt: token.PermissionsChangedAt,
+	}, nil
+}
+
+func (s *AuthService) GetUserInfo(ctx *saiTypes.RequestCtx, accessToken, ip, userAgent string) (*models.UserInfoResponse, error) {
+	token, user, err := s.sessionByAccessToken(ctx, accessToken, ip, userAgent, "me")
 	if err != nil {
-		return nil, fmt.Errorf("user not found")
+		return nil, err
 	}
 
-	user.PasswordHash = ""
+	user.ClearSecrets()
 
 	return &models.UserInfoResponse{
 		User:        user,
@@ -193,14 +747,15 @@ func (s *AuthService) GetUserInfo(ctx *saiTypes.RequestCtx, accessToken string)
 }
 
 func (s *AuthService) VerifyToken(ctx *saiTypes.RequestCtx, req *models.VerifyRequest) (*models.VerifyResponse, error) {
-	userCount, err := s.userRepo.CountUsers(ctx)
-	if err == nil && userCount == 0 {
-		return &models.VerifyResponse{
-			Allowed:        true,
-			UserID:         "no-users",
-			ModifiedParams: req.RequestParams,
-			Reason:         "No users in system - access granted",
-		}, nil
+	if s.config.Bootstrap.AllowOpenAccess {
+		if userCount, err := s.userRepo.CountUsers(ctx); err == nil && userCount == 0 {
+			return &models.VerifyResponse{
+				Allowed:        true,
+				UserID:         "no-users",
+				ModifiedParams: req.RequestParams,
+				Reason:         "No users in system - access granted",
+			}, nil
+		}
 	}
 
 	token, err := s.tokenRepo.GetByAccessToken(ctx, req.Token)
@@ -211,6 +766,8 @@ func (s *AuthService) VerifyToken(ctx *saiTypes.RequestCtx, req *models.VerifyRe
 		}, nil
 	}
 
+	s.touchSession(ctx, token)
+
 	user, err := s.userRepo.GetByID(ctx, token.UserID)
 	if err != nil {
 		return &models.VerifyResponse{
@@ -226,7 +783,29 @@ func (s *AuthService) VerifyToken(ctx *saiTypes.RequestCtx, req *models.VerifyRe
 		}, nil
 	}
 
+	if !s.sessionUsable(ctx, user, token.TenantID) {
+		return &models.VerifyResponse{
+			Allowed:  false,
+			UserID:   user.InternalID,
+			TenantID: token.TenantID,
+			Reason:   "Tenant is not available",
+		}, nil
+	}
+
 	if user.IsSuperUser {
+		if !s.superUserAllowed(ctx, user, req.ClientIP, "", "verify") {
+			reason := "Superuser access is not allowed from this IP"
+			if req.ClientIP == "" {
+				reason = "Superuser access requires the client IP"
+			}
+
+			return &models.VerifyResponse{
+				Allowed: false,
+				UserID:  user.InternalID,
+				Reason:  reason,
+			}, nil
+		}
+
 		modifiedParams := make(map[string]interface{})
 		for key, value := range req.RequestParams {
 			modifiedParams[key] = value
@@ -235,13 +814,14 @@ func (s *AuthService) VerifyToken(ctx *saiTypes.RequestCtx, req *models.VerifyRe
 		return &models.VerifyResponse{
 			Allowed:        true,
 			UserID:         user.InternalID,
+			TenantID:       token.TenantID,
 			ModifiedParams: modifiedParams,
 		}, nil
 	}
 
 	result, err := s.permissionSvc.CheckPermission(
 		ctx,
-		token.CompiledPermissions,
+		s.permissionSvc.SessionIndex(token),
 		req.Microservice,
 		req.Method,
 		req.Path,
@@ -255,15 +835,76 @@ func (s *AuthService) VerifyToken(ctx *saiTypes.RequestCtx, req *models.VerifyRe
 	}
 
 	result.UserID = user.InternalID
+	result.TenantID = token.TenantID
+
+	if result.Allowed {
+		s.applyRateLimits(ctx, result)
+	}
+
 	return result, nil
 }
 
+func (s *AuthService) applyRateLimits(ctx *saiTypes.RequestCtx, result *models.VerifyResponse) {
+	if s.rateLimiter == nil || len(result.Rates) == 0 {
+		return
+	}
+
+	key := result.UserID + ":" + result.Permission
+	if result.TenantID != "" {
+		key = result.UserID + ":" + result.TenantID + ":" + result.Permission
+	}
+
+	strictest, err := s.rateLimiter.CheckRates(ctx, key, result.Rates)
+	if err != nil {
+		sai.Logger().Error("Rate limit check failed", zap.Error(err), zap.String("user_id", result.UserID))
+		return
+	}
+
+	result.Rates = nil
+	result.RateLimit = strictest
+
+	if !strictest.Allowed {
+		result.Allowed = false
+		result.RateLimited = true
+		result.ModifiedParams = nil
+		result.Reason = fmt.Sprintf("Rate limit exceeded for %s, retry in %ds", result.Permission, strictest.Reset)
+	}
+}
+
+// TestPermissions checks a request against the user's permissions and, with
+// GrantRoles, explains whether the user could assign those roles. Without a
+// microservice, method and path only the grants are checked.
 func (s *AuthService) TestPermissions(ctx *saiTypes.RequestCtx, req *models.TestPermissionsRequest) (*models.VerifyResponse, error) {
 	user, err := s.userRepo.GetByID(ctx, req.UserID)
 	if err != nil {
 		return nil, fmt.Errorf("user not found")
 	}
 
+	result := &models.VerifyResponse{Allowed: true, UserID: user.InternalID}
+	if req.Microservice != "" {
+		result, err = s.testPermission(ctx, user, req)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(req.GrantRoles) > 0 {
+		result.RoleGrants, err = s.explainGrants(ctx, user, req.TenantID, req.TargetUserID, req.GrantRoles)
+		if err != nil {
+			return nil, err
+		}
+
+		if req.Microservice == "" {
+			for _, grant := range result.RoleGrants {
+				result.Allowed = result.Allowed && grant.Allowed
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func (s *AuthService) testPermission(ctx *saiTypes.RequestCtx, user *models.User, req *models.TestPermissionsRequest) (*models.VerifyResponse, error) {
 	if user.IsSuperUser {
 		modifiedParams := make(map[string]interface{})
 		for key, value := range req.TestParams {
@@ -277,14 +918,14 @@ func (s *AuthService) TestPermissions(ctx *saiTypes.RequestCtx, req *models.Test
 		}, nil
 	}
 
-	permissions, err := s.permissionSvc.CompilePermissions(ctx, user)
+	permissions, err := s.permissionSvc.CompilePermissions(ctx, user, req.TenantID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to compile permissions: %w", err)
 	}
 
 	result, err := s.permissionSvc.CheckPermission(
 		ctx,
-		permissions,
+		NewPermissionIndex(permissions),
 		req.Microservice,
 		req.Method,
 		req.Path,
@@ -298,30 +939,112 @@ func (s *AuthService) TestPermissions(ctx *saiTypes.RequestCtx, req *models.Test
 	}
 
 	result.UserID = user.InternalID
+	result.TenantID = req.TenantID
 	return result, nil
 }
 
-func (s *AuthService) generateToken(userID string, permissions []models.CompiledPermission) (*models.Token, error) {
-	accessToken, err := s.generateRandomString(64)
+func (s *AuthService) explainGrants(ctx *saiTypes.RequestCtx, user *models.User, tenantID, targetUserID string, roleIDs []string) ([]models.RoleGrantResult, error) {
+	var targetData map[string]interface{}
+	if targetUserID != "" {
+		target, err := s.userRepo.GetByID(ctx, targetUserID)
+		if err != nil {
+			return nil, fmt.Errorf("target user not found")
+		}
+		targetData = target.Data
+	}
+
+	results := make([]models.RoleGrantResult, 0, len(roleIDs))
+
+	if user.IsSuperUser {
+		for _, roleID := range roleIDs {
+			results = append(results, models.RoleGrantResult{RoleID: roleID, Allowed: true})
+		}
+		return results, nil
+	}
+
+	grants, err := s.permissionSvc.CompileGrants(ctx, user, tenantID)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to compile grants: %w", err)
+	}
+
+	for _, roleID := range roleIDs {
+		results = append(results, s.permissionSvc.CheckGrant(grants, roleID, targetData))
 	}
 
+	return results, nil
+}
+
+func (s *AuthService) generateToken(ctx *saiTypes.RequestCtx, user *models.User, tenantID string, permissions []models.CompiledPermission) (*models.Token, error) {
 	refreshToken, err := s.generateRandomString(64)
 	if err != nil {
 		return nil, err
 	}
 
 	now := time.Now()
+	sessionID := uuid.New().String()
 
-	return &models.Token{
-		UserID:              userID,
-		AccessToken:         accessToken,
+	token := &models.Token{
+		InternalID:          sessionID,
+		FamilyID:            sessionID,
+		UserID:              user.InternalID,
+		TenantID:            tenantID,
 		RefreshToken:        refreshToken,
-		ExpiresAt:           now.Add(s.config.AccessTokenTTL).UnixNano(),
 		RefreshExpiresAt:    now.Add(s.config.RefreshTokenTTL).UnixNano(),
 		CompiledPermissions: permissions,
-	}, nil
+		LastUsedAt:          now.UnixNano(),
+		CreatedAt:           now.UnixNano(),
+		UpdatedAt:           now.UnixNano(),
+	}
+
+	if err := s.issueAccessToken(ctx, token, user); err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// issueAccessToken sets a new access token on the session, never outliving
+// its refresh token. With a key service configured the access token is a
+// signed JWT carrying the session's compact permissions.
+func (s *AuthService) issueAccessToken(ctx *saiTypes.RequestCtx, token *models.Token, user *models.User) error {
+	now := time.Now()
+
+	token.ExpiresAt = now.Add(s.config.AccessTokenTTL).UnixNano()
+	if token.ExpiresAt > token.RefreshExpiresAt {
+		token.ExpiresAt = token.RefreshExpiresAt
+	}
+
+	if s.keySvc == nil {
+		accessToken, err := s.generateRandomString(64)
+		if err != nil {
+			return err
+		}
+
+		token.AccessToken = accessToken
+		return nil
+	}
+
+	key, err := s.keySvc.SigningKey(ctx)
+	if err != nil {
+		return err
+	}
+
+	accessToken, err := jwt.Sign(&jwt.Claims{
+		Issuer:      s.config.JWT.Issuer,
+		Subject:     user.InternalID,
+		SessionID:   token.InternalID,
+		TenantID:    token.TenantID,
+		IssuedAt:    now.Unix(),
+		ExpiresAt:   time.Unix(0, token.ExpiresAt).Unix(),
+		SuperUser:   user.IsSuperUser,
+		Permissions: s.permissionSvc.CompactPermissions(token.CompiledPermissions),
+	}, key)
+	if err != nil {
+		return err
+	}
+
+	token.AccessToken = accessToken
+	return nil
 }
 
 func (s *AuthService) generateRandomString(length int) (string, error) {
@@ -332,11 +1055,186 @@ func (s *AuthService) generateRandomString(length int) (string, error) {
 	return hex.EncodeToString(bytes), nil
 }
 
+// superUserAllowed reports whether user may act from ip. Only superusers are
+// restricted, and only when allowed networks are configured; denials are
+// audited with the action that was refused.
+func (s *AuthService) superUserAllowed(ctx *saiTypes.RequestCtx, user *models.User, ip, userAgent, action string) bool {
+	if !user.IsSuperUser || len(s.superUserIPs) == 0 {
+		return true
+	}
+
+	if clientip.Contains(s.superUserIPs, net.ParseIP(ip)) {
+		return true
+	}
+
+	sai.Logger().Warn("Superuser access denied from IP",
+		zap.String("user_id", user.InternalID),
+		zap.String("ip", ip),
+		zap.String("action", action))
+
+	s.recordSecurityEvent(ctx, &models.SecurityEvent{
+		Type:      models.SecurityEventSuperUserIPDenied,
+		UserID:    user.InternalID,
+		IP:        ip,
+		UserAgent: userAgent,
+		Details:   map[string]interface{}{"action": action},
+	})
+
+	return false
+}
+
 func (s *AuthService) isSuperUser(ctx *saiTypes.RequestCtx, user *models.User) bool {
 	return user.IsSuperUser
 }
 
+// ValidatePassword applies the password policy; user is nil for accounts
+// that are not created yet.
+func (s *AuthService) ValidatePassword(password string, user *models.User) error {
+	return s.passwordPol.Validate(password, user)
+}
+
+// PasswordChange validates a new password for user and returns the fields to
+// $set on the user document, including the rotated password history.
+func (s *AuthService) PasswordChange(user *models.User, password string) (map[string]interface{}, error) {
+	if err := s.passwordPol.Validate(password, user); err != nil {
+		return nil, err
+	}
+
+	hash, err := s.HashPassword(password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	now := time.Now().UnixNano()
+
+	return map[string]interface{}{
+		"password_hash":       hash,
+		"password_history":    s.passwordPol.History(user),
+		"password_changed_at": now,
+		"ch_time":             now,
+	}, nil
+}
+
+// ChangePassword is the self-service password change. It works with either
+// a valid access token or the login, so users with an expired password can
+// still change it; without a token an enrolled second factor is required.
+// Every other session of the user is revoked afterwards.
+func (s *AuthService) ChangePassword(ctx *saiTypes.RequestCtx, accessToken string, req *models.ChangePasswordRequest) error {
+	var user *models.User
+	var current *models.Token
+
+	if accessToken != "" {
+		token, found, err := s.sessionByAccessToken(ctx, accessToken, req.IP, req.UserAgent, "change_password")
+		if err != nil {
+			return err
+		}
+		current = token
+		user = found
+	} else {
+		if s.lockoutSvc != nil {
+			if err := s.lockoutSvc.CheckIP(ctx, req.IP); err != nil {
+				return err
+			}
+		}
+
+		found, err := s.findUser(ctx, req.User)
+		if err != nil {
+			if s.lockoutSvc != nil {
+				s.lockoutSvc.RecordFailure(ctx, nil, req.User, req.IP, req.UserAgent)
+			}
+			return fmt.Errorf("invalid credentials")
+		}
+		user = found
+	}
+
+	if !user.IsActive {
+		return fmt.Errorf("user account is inactive")
+	}
+
+	if s.lockoutSvc != nil {
+		if err := s.lockoutSvc.CheckUser(user); err != nil {
+			return err
+		}
+	}
+
+	if !s.hasher.Verify(user.PasswordHash, req.CurrentPassword) {
+		if s.lockoutSvc != nil {
+			s.lockoutSvc.RecordFailure(ctx, user, user.Username, req.IP, req.UserAgent)
+		}
+		return fmt.Errorf("invalid credentials")
+	}
+
+	if current == nil && user.MFAEnabled && s.mfaSvc != nil {
+		if err := s.mfaSvc.verifyCode(ctx, user, req.Code); err != nil {
+			if s.lockoutSvc != nil {
+				s.lockoutSvc.RecordFailure(ctx, user, user.Username, req.IP, req.UserAgent)
+			}
+			return err
+		}
+	}
+
+	if current == nil && !s.superUserAllowed(ctx, user, req.IP, req.UserAgent, "change_password") {
+		return fmt.Errorf("superuser access is not allowed from this IP")
+	}
+
+	if s.lockoutSvc != nil {
+		s.lockoutSvc.RecordSuccess(ctx, user)
+	}
+
+	fields, err := s.PasswordChange(user, req.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	err = s.userRepo.Update(ctx,
+		map[string]interface{}{"internal_id": user.InternalID},
+		map[string]interface{}{"$set": fields},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	tokens, err := s.tokenRepo.ListByUserID(ctx, user.InternalID)
+	if err != nil {
+		sai.Logger().Warn("Failed to list sessions after password change", zap.Error(err), zap.String("user_id", user.InternalID))
+		return nil
+	}
+
+	for _, token := range tokens {
+		if current != nil && token.InternalID == current.InternalID {
+			continue
+		}
+		s.tokenRepo.Delete(ctx, token.InternalID)
+	}
+
+	return nil
+}
+
 func (s *AuthService) HashPassword(password string) (string, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), s.config.BcryptCost)
-	return string(hash), err
+	return s.hasher.Hash(password)
+}
+
+// rehashPassword moves a verified password to the configured algorithm and
+// parameters. It runs on login, so failures are only logged.
+func (s *AuthService) rehashPassword(ctx *saiTypes.RequestCtx, user *models.User, password string) {
+	if !s.hasher.NeedsRehash(user.PasswordHash) {
+		return
+	}
+
+	hash, err := s.hasher.Hash(password)
+	if err != nil {
+		sai.Logger().Error("Failed to rehash password", zap.Error(err), zap.String("user_id", user.InternalID))
+		return
+	}
+
+	err = s.userRepo.Update(ctx,
+		map[string]interface{}{"internal_id": user.InternalID, "password_hash": user.PasswordHash},
+		map[string]interface{}{"$set": map[string]interface{}{"password_hash": hash}},
+	)
+	if err != nil {
+		sai.Logger().Error("Failed to store rehashed password", zap.Error(err), zap.String("user_id", user.InternalID))
+		return
+	}
+
+	user.PasswordHash = hash
 }
@@ -0,0 +1,203 @@
+package service
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/valyala/fasthttp"
+
+	"github.com/saiset-co/sai-auth/internal/models"
+	"github.com/saiset-co/sai-auth/internal/repository"
+	"github.com/saiset-co/sai-auth/types"
+	"github.com/saiset-co/sai-service/sai"
+	saiTypes "github.com/saiset-co/sai-service/types"
+	"go.uber.org/zap"
+)
+
+const bootstrapRetryInterval = 5 * time.Second
+
+// BootstrapService makes sure the first superuser is created deliberately,
+// from configuration or with a one-time setup token, instead of whoever
+// signs up first.
+type BootstrapService struct {
+	userRepo    repository.UserRepository
+	userSvc     *UserService
+	roleSvc     *RoleService
+	authService *AuthService
+	config      *types.SaiAuthConfig
+
+	mu             sync.Mutex
+	setupTokenHash []byte
+}
+
+func NewBootstrapService(
+	userRepo repository.UserRepository,
+	userSvc *UserService,
+	roleSvc *RoleService,
+	authService *AuthService,
+	config *types.SaiAuthConfig,
+) (*BootstrapService, error) {
+	bootstrap := &config.Bootstrap
+
+	if bootstrap.Username != "" || bootstrap.Email != "" || bootstrap.PasswordHash != "" {
+		if bootstrap.Username == "" || bootstrap.Email == "" || bootstrap.PasswordHash == "" {
+			return nil, fmt.Errorf("bootstrap requires username, email and password_hash together")
+		}
+		if !authService.hasher.Identifies(bootstrap.PasswordHash) {
+			return nil, fmt.Errorf("bootstrap password_hash must be a bcrypt or argon2id hash")
+		}
+	}
+
+	return &BootstrapService{
+		userRepo:    userRepo,
+		userSvc:     userSvc,
+		roleSvc:     roleSvc,
+		authService: authService,
+		config:      config,
+	}, nil
+}
+
+// Start seeds the built-in admin roles and the first superuser in the
+// background, retrying until storage is reachable.
+func (s *BootstrapService) Start() {
+	go func() {
+		for {
+			ctx := &saiTypes.RequestCtx{RequestCtx: &fasthttp.RequestCtx{}}
+
+			err := s.run(ctx)
+			if err == nil {
+				return
+			}
+
+			sai.Logger().Warn("Superuser bootstrap failed, retrying", zap.Error(err))
+			time.Sleep(bootstrapRetryInterval)
+		}
+	}()
+}
+
+func (s *BootstrapService) run(ctx *saiTypes.RequestCtx) error {
+	if err := s.roleSvc.SeedAdminRoles(ctx); err != nil {
+		return err
+	}
+
+	exists, err := s.superUserExists(ctx)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	bootstrap := s.config.Bootstrap
+
+	if bootstrap.Username != "" {
+		_, usernameErr := s.userRepo.GetByUsername(ctx, bootstrap.Username)
+		_, emailErr := s.userRepo.GetByEmail(ctx, bootstrap.Email)
+		if usernameErr == nil || emailErr == nil {
+			sai.Logger().Error("Bootstrap username or email belongs to an existing user, not promoting it",
+				zap.String("username", bootstrap.Username))
+			return nil
+		}
+
+		user := &models.User{
+			InternalID:        uuid.New().String(),
+			Username:          bootstrap.Username,
+			Email:             bootstrap.Email,
+			EmailVerified:     true,
+			PasswordHash:      bootstrap.PasswordHash,
+			PasswordChangedAt: time.Now().UnixNano(),
+			IsActive:          true,
+			IsSuperUser:       true,
+			Roles:             []string{},
+			Data:              make(map[string]interface{}),
+		}
+
+		if err := s.userRepo.Create(ctx, user); err != nil {
+			return fmt.Errorf("failed to create bootstrap superuser: %w", err)
+		}
+
+		sai.Logger().Info("Bootstrap superuser created", zap.String("username", user.Username))
+		return nil
+	}
+
+	if bootstrap.SetupToken {
+		token, err := s.authService.generateRandomString(32)
+		if err != nil {
+			return err
+		}
+
+		hash := sha256.Sum256([]byte(token))
+
+		s.mu.Lock()
+		s.setupTokenHash = hash[:]
+		s.mu.Unlock()
+
+		sai.Logger().Warn("No superuser exists. Create one with POST /api/v1/auth/setup using this one-time setup token",
+			zap.String("setup_token", token))
+		return nil
+	}
+
+	sai.Logger().Warn("No superuser exists and bootstrap is not configured")
+	return nil
+}
+
+// Setup creates the first superuser with the setup token logged at startup.
+// The token is consumed on success.
+func (s *BootstrapService) Setup(ctx *saiTypes.RequestCtx, req *models.SetupRequest) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash := sha256.Sum256([]byte(req.SetupToken))
+	if s.setupTokenHash == nil || subtle.ConstantTimeCompare(s.setupTokenHash, hash[:]) != 1 {
+		return nil, fmt.Errorf("invalid setup token")
+	}
+
+	exists, err := s.superUserExists(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		s.setupTokenHash = nil
+		return nil, fmt.Errorf("invalid setup token")
+	}
+
+	user, err := s.userSvc.newUser(ctx, &models.CreateUserRequest{
+		Username:      req.Username,
+		Email:         req.Email,
+		Password:      req.Password,
+		EmailVerified: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	user.IsSuperUser = true
+
+	if err := s.userSvc.insert(ctx, user); err != nil {
+		return nil, err
+	}
+
+	s.setupTokenHash = nil
+
+	sai.Logger().Info("Superuser created with setup token", zap.String("username", user.Username))
+
+	user.ClearSecrets()
+	return user, nil
+}
+
+func (s *BootstrapService) superUserExists(ctx *saiTypes.RequestCtx) (bool, error) {
+	superUser := true
+	_, total, err := s.userRepo.List(ctx, &types.UserFilterRequest{
+		PaginationRequest: types.PaginationRequest{Limit: 1},
+		SuperUser:         &superUser,
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to look up superusers: %w", err)
+	}
+
+	return total > 0, nil
+}
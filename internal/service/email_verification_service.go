@@ -0,0 +1,227 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/saiset-co/sai-auth/internal/models"
+	"github.com/saiset-co/sai-auth/internal/repository"
+	"github.com/saiset-co/sai-auth/pkg/notifier"
+	"github.com/saiset-co/sai-auth/types"
+	"github.com/saiset-co/sai-service/sai"
+	saiTypes "github.com/saiset-co/sai-service/types"
+	"go.uber.org/zap"
+)
+
+const (
+	EmailVerificationOff        = "off"
+	EmailVerificationOptional   = "optional"
+	EmailVerificationRequired   = "required"
+	EmailVerificationRestricted = "restricted"
+
+	defaultEmailVerificationTTL = 24 * time.Hour
+	defaultResendInterval       = time.Minute
+)
+
+// VerificationThrottledError is returned when a verification mail was sent
+// too recently.
+type VerificationThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *VerificationThrottledError) Error() string {
+	return "verification email was sent recently, retry later"
+}
+
+type EmailVerificationService struct {
+	userRepo        repository.UserRepository
+	tokenRepo       repository.TokenRepository
+	actionTokenRepo repository.ActionTokenRepository
+	authService     *AuthService
+	notifier        notifier.Notifier
+	config          *types.SaiAuthConfig
+}
+
+func NewEmailVerificationService(
+	userRepo repository.UserRepository,
+	tokenRepo repository.TokenRepository,
+	actionTokenRepo repository.ActionTokenRepository,
+	authService *AuthService,
+	notifier notifier.Notifier,
+	config *types.SaiAuthConfig,
+) (*EmailVerificationService, error) {
+	verification := &config.EmailVerification
+
+	switch verification.Mode {
+	case "":
+		verification.Mode = EmailVerificationOff
+	case EmailVerificationOff, EmailVerificationOptional, EmailVerificationRequired, EmailVerificationRestricted:
+	default:
+		return nil, fmt.Errorf("unknown email verification mode %q", verification.Mode)
+	}
+
+	if verification.TokenTTL <= 0 {
+		verification.TokenTTL = defaultEmailVerificationTTL
+	}
+	if verification.ResendInterval <= 0 {
+		verification.ResendInterval = defaultResendInterval
+	}
+
+	return &EmailVerificationService{
+		userRepo:        userRepo,
+		tokenRepo:       tokenRepo,
+		actionTokenRepo: actionTokenRepo,
+		authService:     authService,
+		notifier:        notifier,
+		config:          config,
+	}, nil
+}
+
+func (s *EmailVerificationService) Enabled() bool {
+	return s.config.EmailVerification.Mode != EmailVerificationOff
+}
+
+// Start issues a fresh verification token for the user's current email and
+// mails it, replacing any earlier token. It is a no-op when verification is
+// off or the email is already verified.
+func (s *EmailVerificationService) Start(ctx *saiTypes.RequestCtx, user *models.User) error {
+	if !s.Enabled() || user.EmailVerified || user.Email == "" {
+		return nil
+	}
+
+	if err := s.actionTokenRepo.DeleteByUserID(ctx, user.InternalID, models.ActionTokenEmailVerification); err != nil {
+		sai.Logger().Warn("Failed to drop previous verification tokens", zap.Error(err), zap.String("user_id", user.InternalID))
+	}
+
+	token, err := s.authService.generateRandomString(32)
+	if err != nil {
+		return fmt.Errorf("failed to generate verification token: %w", err)
+	}
+
+	now := time.Now()
+	err = s.actionTokenRepo.Create(ctx, &models.ActionToken{
+		InternalID: uuid.New().String(),
+		UserID:     user.InternalID,
+		Purpose:    models.ActionTokenEmailVerification,
+		Token:      token,
+		ExpiresAt:  now.Add(s.config.EmailVerification.TokenTTL).UnixNano(),
+		CrTime:     now.UnixNano(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store verification token: %w", err)
+	}
+
+	err = s.userRepo.Update(ctx,
+		map[string]interface{}{"internal_id": user.InternalID},
+		map[string]interface{}{"$set": map[string]interface{}{"email_verification_sent_at": now.UnixNano()}},
+	)
+	if err != nil {
+		sai.Logger().Warn("Failed to record verification mail", zap.Error(err), zap.String("user_id", user.InternalID))
+	}
+
+	notifyInBackground(s.notifier, &notifier.Message{
+		To:      user.Email,
+		Subject: "Confirm your email",
+		Body:    s.verificationBody(user, token),
+	}, user.InternalID)
+
+	return nil
+}
+
+// Verify consumes a verification token. The token is bound to the email it
+// was sent to, so it is rejected if the email changed in the meantime.
+func (s *EmailVerificationService) Verify(ctx *saiTypes.RequestCtx, req *models.VerifyEmailRequest) error {
+	actionToken, err := s.actionTokenRepo.GetByToken(ctx, models.ActionTokenEmailVerification, req.Token)
+	if err != nil {
+		return fmt.Errorf("invalid or expired verification token")
+	}
+
+	s.actionTokenRepo.Delete(ctx, actionToken.InternalID)
+
+	user, err := s.userRepo.GetByID(ctx, actionToken.UserID)
+	if err != nil || actionToken.CrTime < user.EmailVerificationSentAt {
+		return fmt.Errorf("invalid or expired verification token")
+	}
+
+	if user.EmailVerified {
+		return nil
+	}
+
+	err = s.userRepo.Update(ctx,
+		map[string]interface{}{"internal_id": user.InternalID},
+		map[string]interface{}{
+			"$set":   map[string]interface{}{"email_verified": true, "ch_time": time.Now().UnixNano()},
+			"$unset": map[string]interface{}{"email_verification_sent_at": ""},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to verify email: %w", err)
+	}
+
+	user.EmailVerified = true
+	s.authService.refreshSessionPermissions(ctx, user)
+
+	s.authService.recordSecurityEvent(ctx, &models.SecurityEvent{
+		Type:    models.SecurityEventEmailVerified,
+		UserID:  user.InternalID,
+		Details: map[string]interface{}{"email": user.Email},
+	})
+
+	return nil
+}
+
+// Resend mails a new verification token. Signed-in users are identified by
+// their access token and told when they are throttled; anonymous requests by
+// login always succeed so they cannot be used to probe accounts.
+func (s *EmailVerificationService) Resend(ctx *saiTypes.RequestCtx, accessToken string, req *models.ResendVerificationRequest) error {
+	if !s.Enabled() {
+		return fmt.Errorf("email verification is disabled")
+	}
+
+	var user *models.User
+	var err error
+
+	if accessToken != "" {
+		_, user, err = s.authService.sessionByAccessToken(ctx, accessToken, req.IP, req.UserAgent, "resend_verification")
+		if err != nil {
+			return err
+		}
+	} else {
+		user, err = s.authService.findUser(ctx, req.User)
+		if err != nil || !user.IsActive {
+			return nil
+		}
+	}
+
+	if user.EmailVerified {
+		if accessToken != "" {
+			return fmt.Errorf("email already verified")
+		}
+		return nil
+	}
+
+	next := time.Unix(0, user.EmailVerificationSentAt).Add(s.config.EmailVerification.ResendInterval)
+	if wait := time.Until(next); wait > 0 {
+		if accessToken != "" {
+			return &VerificationThrottledError{RetryAfter: wait}
+		}
+		return nil
+	}
+
+	return s.Start(ctx, user)
+}
+
+func (s *EmailVerificationService) verificationBody(user *models.User, token string) string {
+	ttl := s.config.EmailVerification.TokenTTL.String()
+
+	link, ok := tokenLink(s.config.EmailVerification.URL, token)
+	if !ok {
+		return fmt.Sprintf("Hello %s,\n\nUse this token to confirm your email address: %s\n\nIt expires in %s.",
+			user.Username, token, ttl)
+	}
+
+	return fmt.Sprintf("Hello %s,\n\nOpen this link to confirm your email address:\n%s\n\nIt expires in %s.",
+		user.Username, link, ttl)
+}
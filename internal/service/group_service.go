@@ -0,0 +1,473 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/saiset-co/sai-auth/internal/models"
+	"github.com/saiset-co/sai-auth/internal/repository"
+	"github.com/saiset-co/sai-auth/types"
+	saiTypes "github.com/saiset-co/sai-service/types"
+)
+
+type GroupService struct {
+	groupRepo     repository.GroupRepository
+	roleRepo      repository.RoleRepository
+	userRepo      repository.UserRepository
+	permissionSvc *PermissionService
+	userService   *UserService
+}
+
+func NewGroupService(
+	groupRepo repository.GroupRepository,
+	roleRepo repository.RoleRepository,
+	userRepo repository.UserRepository,
+	permissionSvc *PermissionService,
+	userService *UserService,
+) *GroupService {
+	return &GroupService{
+		groupRepo:     groupRepo,
+		roleRepo:      roleRepo,
+		userRepo:      userRepo,
+		permissionSvc: permissionSvc,
+		userService:   userService,
+	}
+}
+
+func (s *GroupService) Create(ctx *saiTypes.RequestCtx, req *models.CreateGroupRequest) (*models.Group, error) {
+	if _, err := s.groupRepo.GetByName(ctx, req.Name); err == nil {
+		return nil, fmt.Errorf("group name already exists")
+	}
+
+	now := time.Now().UnixNano()
+	group := &models.Group{
+		InternalID:   uuid.New().String(),
+		Name:         req.Name,
+		IsActive:     true,
+		ParentGroups: uniqueIDs(req.ParentGroups),
+		Roles:        uniqueIDs(req.Roles),
+		Members:      uniqueIDs(req.Members),
+		Data:         req.Data,
+		CrTime:       now,
+		ChTime:       now,
+	}
+
+	if req.IsActive != nil {
+		group.IsActive = *req.IsActive
+	}
+
+	if group.Data == nil {
+		group.Data = make(map[string]interface{})
+	}
+
+	if err := s.validateRoles(ctx, group.Roles); err != nil {
+		return nil, err
+	}
+
+	if err := s.validateGroupHierarchy(ctx, group.InternalID, group.ParentGroups, 0); err != nil {
+		return nil, err
+	}
+
+	if len(group.Roles) > 0 || len(group.ParentGroups) > 0 {
+		if err := s.checkGroupRoleChange(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.checkMembers(ctx, group, group.Members); err != nil {
+		return nil, err
+	}
+
+	if err := s.groupRepo.Create(ctx, group); err != nil {
+		return nil, fmt.Errorf("failed to create group: %w", err)
+	}
+
+	s.recompileUsers(ctx, group.Members)
+
+	return group, nil
+}
+
+func (s *GroupService) GetByID(ctx *saiTypes.RequestCtx, id string) (*models.Group, error) {
+	return s.groupRepo.GetByID(ctx, id)
+}
+
+func (s *GroupService) List(ctx *saiTypes.RequestCtx, filter *types.GroupFilterRequest) ([]*models.Group, int64, error) {
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.Limit < 1 {
+		filter.Limit = 20
+	}
+	if filter.Limit > 100 {
+		filter.Limit = 100
+	}
+
+	return s.groupRepo.List(ctx, filter)
+}
+
+// Update changes a group; members of the group and of its nested groups get
+// their sessions recompiled when roles, parents or the active flag change.
+func (s *GroupService) Update(ctx *saiTypes.RequestCtx, groupID string, req *models.UpdateGroupRequest) error {
+	group, err := s.groupRepo.GetByID(ctx, groupID)
+	if err != nil {
+		return err
+	}
+
+	data := map[string]interface{}{"ch_time": time.Now().UnixNano()}
+	rolesChanged := false
+
+	if req.Name != nil && *req.Name != group.Name {
+		if *req.Name == "" {
+			return fmt.Errorf("group name is required")
+		}
+		if _, err := s.groupRepo.GetByName(ctx, *req.Name); err == nil {
+			return fmt.Errorf("group name already exists")
+		}
+		data["name"] = *req.Name
+	}
+
+	if req.Roles != nil {
+		roles := uniqueIDs(req.Roles)
+		if err := s.validateRoles(ctx, roles); err != nil {
+			return err
+		}
+		data["roles"] = roles
+		rolesChanged = true
+	}
+
+	if req.ParentGroups != nil {
+		parents := uniqueIDs(req.ParentGroups)
+		if err := s.validateGroupHierarchy(ctx, group.InternalID, parents, 0); err != nil {
+			return err
+		}
+		data["parent_groups"] = parents
+		rolesChanged = true
+	}
+
+	if req.IsActive != nil && *req.IsActive != group.IsActive {
+		data["is_active"] = *req.IsActive
+		rolesChanged = true
+	}
+
+	if req.Data != nil {
+		data["data"] = req.Data
+	}
+
+	if rolesChanged {
+		if err := s.checkGroupRoleChange(ctx); err != nil {
+			return err
+		}
+	}
+
+	err = s.groupRepo.Update(ctx,
+		map[string]interface{}{"internal_id": groupID},
+		map[string]interface{}{"$set": data},
+	)
+	if err != nil {
+		return err
+	}
+
+	if rolesChanged {
+		s.recompileUsers(ctx, s.affectedMembers(ctx, groupID))
+	}
+
+	return nil
+}
+
+// Delete removes a group and unlinks it from its nested groups.
+func (s *GroupService) Delete(ctx *saiTypes.RequestCtx, groupID string) error {
+	if _, err := s.groupRepo.GetByID(ctx, groupID); err != nil {
+		return err
+	}
+
+	if err := s.checkGroupRoleChange(ctx); err != nil {
+		return err
+	}
+
+	members := s.affectedMembers(ctx, groupID)
+
+	children, _, err := s.groupRepo.List(ctx, &types.GroupFilterRequest{
+		PaginationRequest: types.PaginationRequest{Limit: groupScanLimit},
+		Parent:            groupID,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, child := range children {
+		err := s.groupRepo.Update(ctx,
+			map[string]interface{}{"internal_id": child.InternalID},
+			map[string]interface{}{"$set": map[string]interface{}{
+				"parent_groups": withoutIDs(child.ParentGroups, []string{groupID}),
+				"ch_time":       time.Now().UnixNano(),
+			}},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := s.groupRepo.Delete(ctx, map[string]interface{}{"internal_id": groupID}); err != nil {
+		return err
+	}
+
+	s.recompileUsers(ctx, members)
+
+	return nil
+}
+
+func (s *GroupService) AddMembers(ctx *saiTypes.RequestCtx, groupID string, userIDs []string) error {
+	group, err := s.groupRepo.GetByID(ctx, groupID)
+	if err != nil {
+		return err
+	}
+
+	userIDs = uniqueIDs(userIDs)
+	if err := s.checkMembers(ctx, group, userIDs); err != nil {
+		return err
+	}
+
+	return s.setMembers(ctx, groupID, uniqueIDs(append(group.Members, userIDs...)), userIDs)
+}
+
+func (s *GroupService) RemoveMembers(ctx *saiTypes.RequestCtx, groupID string, userIDs []string) error {
+	group, err := s.groupRepo.GetByID(ctx, groupID)
+	if err != nil {
+		return err
+	}
+
+	userIDs = uniqueIDs(userIDs)
+	if err := s.checkMembers(ctx, group, userIDs); err != nil {
+		return err
+	}
+
+	return s.setMembers(ctx, groupID, withoutIDs(group.Members, userIDs), userIDs)
+}
+
+func (s *GroupService) setMembers(ctx *saiTypes.RequestCtx, groupID string, members, changed []string) error {
+	err := s.groupRepo.Update(ctx,
+		map[string]interface{}{"internal_id": groupID},
+		map[string]interface{}{"$set": map[string]interface{}{
+			"members": members,
+			"ch_time": time.Now().UnixNano(),
+		}},
+	)
+	if err != nil {
+		return err
+	}
+
+	s.recompileUsers(ctx, changed)
+
+	return nil
+}
+
+// RoleMembers returns the users that get roleID through a group.
+func (s *GroupService) RoleMembers(ctx *saiTypes.RequestCtx, roleID string) []string {
+	groups, _, err := s.groupRepo.List(ctx, &types.GroupFilterRequest{
+		PaginationRequest: types.PaginationRequest{Limit: groupScanLimit},
+		Role:              roleID,
+	})
+	if err != nil {
+		return nil
+	}
+
+	var members []string
+	for _, group := range groups {
+		members = append(members, s.affectedMembers(ctx, group.InternalID)...)
+	}
+
+	return uniqueIDs(members)
+}
+
+// RemoveRole drops a deleted role from every group that gives it.
+func (s *GroupService) RemoveRole(ctx *saiTypes.RequestCtx, roleID string) error {
+	groups, _, err := s.groupRepo.List(ctx, &types.GroupFilterRequest{
+		PaginationRequest: types.PaginationRequest{Limit: groupScanLimit},
+		Role:              roleID,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, group := range groups {
+		err := s.groupRepo.Update(ctx,
+			map[string]interface{}{"internal_id": group.InternalID},
+			map[string]interface{}{"$set": map[string]interface{}{
+				"roles":   withoutIDs(group.Roles, []string{roleID}),
+				"ch_time": time.Now().UnixNano(),
+			}},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// affectedMembers returns the members of a group and of every group nested
+// in it, since all of them inherit its roles.
+func (s *GroupService) affectedMembers(ctx *saiTypes.RequestCtx, groupID string) []string {
+	var members []string
+	visited := make(map[string]bool)
+	current := []string{groupID}
+
+	for depth := 0; len(current) > 0 && depth <= 5; depth++ {
+		var next []string
+		for _, id := range current {
+			if visited[id] {
+				continue
+			}
+			visited[id] = true
+
+			if group, err := s.groupRepo.GetByID(ctx, id); err == nil {
+				members = append(members, group.Members...)
+			}
+
+			children, _, err := s.groupRepo.List(ctx, &types.GroupFilterRequest{
+				PaginationRequest: types.PaginationRequest{Limit: groupScanLimit},
+				Parent:            id,
+			})
+			if err != nil {
+				continue
+			}
+			for _, child := range children {
+				next = append(next, child.InternalID)
+			}
+		}
+		current = next
+	}
+
+	return uniqueIDs(members)
+}
+
+func (s *GroupService) recompileUsers(ctx *saiTypes.RequestCtx, userIDs []string) {
+	for _, userID := range userIDs {
+		s.userService.recompileUserPermissions(ctx, map[string]interface{}{"internal_id": userID})
+	}
+}
+
+func (s *GroupService) validateRoles(ctx *saiTypes.RequestCtx, roleIDs []string) error {
+	for _, roleID := range roleIDs {
+		if _, err := s.roleRepo.GetByID(ctx, roleID); err != nil {
+			return fmt.Errorf("role %s not found", roleID)
+		}
+	}
+	return nil
+}
+
+func (s *GroupService) validateGroupHierarchy(ctx *saiTypes.RequestCtx, groupID string, parentGroups []string, depth int) error {
+	if depth > 5 {
+		return fmt.Errorf("maximum group nesting depth (5) exceeded")
+	}
+
+	for _, parentGroupID := range parentGroups {
+		if parentGroupID == groupID {
+			return fmt.Errorf("circular group dependency detected")
+		}
+
+		parentGroup, err := s.groupRepo.GetByID(ctx, parentGroupID)
+		if err != nil {
+			return fmt.Errorf("parent group %s not found", parentGroupID)
+		}
+
+		if err := s.validateGroupHierarchy(ctx, groupID, parentGroup.ParentGroups, depth+1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkMembers makes sure the users exist and that the caller may grant or
+// remove every role the group gives, including roles of parent groups.
+func (s *GroupService) checkMembers(ctx *saiTypes.RequestCtx, group *models.Group, userIDs []string) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	roleIDs := append([]string{}, group.Roles...)
+	if len(group.ParentGroups) > 0 {
+		parents, err := s.groupRepo.GetByIDs(ctx, group.ParentGroups)
+		if err != nil {
+			return err
+		}
+		for _, parent := range parents {
+			roleIDs = append(roleIDs, s.groupRoles(ctx, parent, make(map[string]bool), 0)...)
+		}
+	}
+	roleIDs = uniqueIDs(roleIDs)
+
+	for _, userID := range userIDs {
+		user, err := s.userRepo.GetByID(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("user %s not found", userID)
+		}
+
+		if err := s.userService.checkGrants(ctx, roleIDs, user.Data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *GroupService) groupRoles(ctx *saiTypes.RequestCtx, group *models.Group, visited map[string]bool, depth int) []string {
+	if depth > 5 || visited[group.InternalID] {
+		return nil
+	}
+	visited[group.InternalID] = true
+
+	roleIDs := append([]string{}, group.Roles...)
+	for _, parentID := range group.ParentGroups {
+		if parent, err := s.groupRepo.GetByID(ctx, parentID); err == nil {
+			roleIDs = append(roleIDs, s.groupRoles(ctx, parent, visited, depth+1)...)
+		}
+	}
+
+	return roleIDs
+}
+
+// checkGroupRoleChange only lets callers who may grant any role on anyone
+// change the roles a group gives, since that affects every member.
+func (s *GroupService) checkGroupRoleChange(ctx *saiTypes.RequestCtx) error {
+	grants, restricted, err := s.userService.callerGrants(ctx)
+	if err != nil || !restricted || s.permissionSvc.UnrestrictedGrant(grants) {
+		return err
+	}
+
+	return &RoleGrantError{Result: models.RoleGrantResult{
+		RoleID: "*",
+		Reason: "Group roles can only be changed by callers who may grant any role",
+	}}
+}
+
+func uniqueIDs(ids []string) []string {
+	seen := make(map[string]bool, len(ids))
+	result := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		result = append(result, id)
+	}
+	return result
+}
+
+func withoutIDs(ids, drop []string) []string {
+	dropped := make(map[string]bool, len(drop))
+	for _, id := range drop {
+		dropped[id] = true
+	}
+
+	result := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if !dropped[id] {
+			result = append(result, id)
+		}
+	}
+	return result
+}
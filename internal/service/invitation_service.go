@@ -0,0 +1,280 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/saiset-co/sai-auth/internal/models"
+	"github.com/saiset-co/sai-auth/internal/repository"
+	"github.com/saiset-co/sai-auth/pkg/notifier"
+	"github.com/saiset-co/sai-auth/types"
+	"github.com/saiset-co/sai-service/sai"
+	saiTypes "github.com/saiset-co/sai-service/types"
+	"go.uber.org/zap"
+)
+
+const defaultInvitationTTL = 7 * 24 * time.Hour
+
+type InvitationService struct {
+	invitationRepo repository.InvitationRepository
+	userRepo       repository.UserRepository
+	roleRepo       repository.RoleRepository
+	userSvc        *UserService
+	authService    *AuthService
+	lockoutSvc     *LockoutService
+	notifier       notifier.Notifier
+	config         *types.SaiAuthConfig
+}
+
+func NewInvitationService(
+	invitationRepo repository.InvitationRepository,
+	userRepo repository.UserRepository,
+	roleRepo repository.RoleRepository,
+	userSvc *UserService,
+	authService *AuthService,
+	notifier notifier.Notifier,
+	config *types.SaiAuthConfig,
+) *InvitationService {
+	if config.Invitation.TTL <= 0 {
+		config.Invitation.TTL = defaultInvitationTTL
+	}
+
+	return &InvitationService{
+		invitationRepo: invitationRepo,
+		userRepo:       userRepo,
+		roleRepo:       roleRepo,
+		userSvc:        userSvc,
+		authService:    authService,
+		notifier:       notifier,
+		config:         config,
+	}
+}
+
+func (s *InvitationService) SetLockoutService(lockoutSvc *LockoutService) {
+	s.lockoutSvc = lockoutSvc
+}
+
+// Create stores an invitation and mails its token. A newer invitation for
+// the same email revokes the pending one. The token is also returned so it
+// can be handed over by other means.
+func (s *InvitationService) Create(ctx *saiTypes.RequestCtx, req *models.CreateInvitationRequest) (*models.Invitation, error) {
+	if _, err := s.userRepo.GetByEmail(ctx, req.Email); err == nil {
+		return nil, fmt.Errorf("email already exists")
+	}
+
+	if len(req.Roles) > 10 {
+		return nil, fmt.Errorf("maximum 10 roles per user exceeded")
+	}
+
+	for _, roleID := range req.Roles {
+		if _, err := s.roleRepo.GetByID(ctx, roleID); err != nil {
+			return nil, fmt.Errorf("role %s not found", roleID)
+		}
+	}
+
+	if err := s.userSvc.checkGrants(ctx, req.Roles, req.Data); err != nil {
+		return nil, err
+	}
+
+	token, err := s.authService.generateRandomString(32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate invitation token: %w", err)
+	}
+
+	err = s.invitationRepo.Update(ctx,
+		map[string]interface{}{"email": req.Email, "status": models.InvitationPending},
+		map[string]interface{}{"$set": map[string]interface{}{"status": models.InvitationRevoked, "ch_time": time.Now().UnixNano()}},
+	)
+	if err != nil {
+		sai.Logger().Warn("Failed to revoke previous invitations", zap.Error(err), zap.String("email", req.Email))
+	}
+
+	now := time.Now()
+	invitation := &models.Invitation{
+		InternalID: uuid.New().String(),
+		Email:      req.Email,
+		Roles:      req.Roles,
+		Data:       req.Data,
+		Token:      token,
+		Status:     models.InvitationPending,
+		ExpiresAt:  now.Add(s.config.Invitation.TTL).UnixNano(),
+		CrTime:     now.UnixNano(),
+	}
+
+	if invitation.Roles == nil {
+		invitation.Roles = []string{}
+	}
+	if invitation.Data == nil {
+		invitation.Data = make(map[string]interface{})
+	}
+	if adminID, ok := ctx.UserValue("user_id").(string); ok {
+		invitation.InvitedBy = adminID
+	}
+
+	if err := s.invitationRepo.Create(ctx, invitation); err != nil {
+		return nil, fmt.Errorf("failed to create invitation: %w", err)
+	}
+
+	notifyInBackground(s.notifier, &notifier.Message{
+		To:      invitation.Email,
+		Subject: "You are invited",
+		Body:    s.invitationBody(token),
+	}, invitation.InternalID)
+
+	return invitation, nil
+}
+
+func (s *InvitationService) List(ctx *saiTypes.RequestCtx, filter *types.InvitationFilterRequest) ([]*models.Invitation, int64, error) {
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.Limit < 1 {
+		filter.Limit = 20
+	}
+	if filter.Limit > 100 {
+		filter.Limit = 100
+	}
+
+	invitations, total, err := s.invitationRepo.List(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	now := time.Now().UnixNano()
+	for _, invitation := range invitations {
+		if invitation.Status == models.InvitationPending && invitation.ExpiresAt < now {
+			invitation.Status = models.InvitationExpired
+		}
+	}
+
+	return invitations, total, nil
+}
+
+func (s *InvitationService) Revoke(ctx *saiTypes.RequestCtx, invitationID string) error {
+	invitation, err := s.invitationRepo.GetByID(ctx, invitationID)
+	if err != nil {
+		return fmt.Errorf("invitation not found")
+	}
+
+	if invitation.Status != models.InvitationPending {
+		return fmt.Errorf("invitation is not pending")
+	}
+
+	err = s.invitationRepo.Update(ctx,
+		map[string]interface{}{"internal_id": invitationID},
+		map[string]interface{}{"$set": map[string]interface{}{"status": models.InvitationRevoked, "ch_time": time.Now().UnixNano()}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to revoke invitation: %w", err)
+	}
+
+	return nil
+}
+
+// Accept creates the invited user with the chosen username and password and
+// assigns the invitation's roles. Receiving the token proves the email, so
+// the account starts verified.
+func (s *InvitationService) Accept(ctx *saiTypes.RequestCtx, req *models.AcceptInvitationRequest) (*models.User, error) {
+	if s.lockoutSvc != nil {
+		if err := s.lockoutSvc.CheckIP(ctx, req.IP); err != nil {
+			return nil, err
+		}
+	}
+
+	invitation, err := s.invitationRepo.GetByToken(ctx, req.Token)
+	if err != nil || invitation.Status != models.InvitationPending || invitation.ExpiresAt < time.Now().UnixNano() {
+		if s.lockoutSvc != nil {
+			s.lockoutSvc.RecordFailure(ctx, nil, req.Username, req.IP, req.UserAgent)
+		}
+		return nil, fmt.Errorf("invalid or expired invitation")
+	}
+
+	user, err := s.userSvc.newUser(ctx, &models.CreateUserRequest{
+		Username:      req.Username,
+		Email:         invitation.Email,
+		Password:      req.Password,
+		EmailVerified: true,
+		Data:          invitation.Data,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The transition only applies while the invitation is still pending, and
+	// the fresh user ID shows whose write won, so of two concurrent accepts
+	// only one creates a user.
+	err = s.invitationRepo.Update(ctx,
+		map[string]interface{}{"internal_id": invitation.InternalID, "status": models.InvitationPending},
+		map[string]interface{}{"$set": map[string]interface{}{
+			"status":      models.InvitationAccepted,
+			"user_id":     user.InternalID,
+			"accepted_at": time.Now().UnixNano(),
+			"ch_time":     time.Now().UnixNano(),
+		}},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to accept invitation: %w", err)
+	}
+
+	accepted, err := s.invitationRepo.GetByID(ctx, invitation.InternalID)
+	if err != nil || accepted.Status != models.InvitationAccepted || accepted.UserID != user.InternalID {
+		return nil, fmt.Errorf("invalid or expired invitation")
+	}
+
+	if err := s.userSvc.insert(ctx, user); err != nil {
+		s.reopen(ctx, invitation.InternalID, user.InternalID)
+		return nil, err
+	}
+
+	if len(invitation.Roles) > 0 {
+		if err := s.userSvc.AssignRoles(ctx, user.InternalID, &models.AssignRolesRequest{RoleIDs: invitation.Roles}); err != nil {
+			sai.Logger().Error("Failed to assign invitation roles",
+				zap.Error(err),
+				zap.String("user_id", user.InternalID),
+				zap.String("invitation_id", invitation.InternalID))
+
+			// Without its roles the account is not what was invited; remove it
+			// so the invitation can be accepted again.
+			if delErr := s.userSvc.userRepo.Delete(ctx, map[string]interface{}{"internal_id": user.InternalID}); delErr != nil {
+				sai.Logger().Error("Failed to remove invited user", zap.Error(delErr), zap.String("user_id", user.InternalID))
+			} else {
+				s.reopen(ctx, invitation.InternalID, user.InternalID)
+			}
+			return nil, fmt.Errorf("failed to assign roles: %w", err)
+		}
+		user.Roles = invitation.Roles
+	}
+
+	user.ClearSecrets()
+	return user, nil
+}
+
+// reopen puts an invitation accepted for userID back to pending after the
+// user could not be created.
+func (s *InvitationService) reopen(ctx *saiTypes.RequestCtx, invitationID, userID string) {
+	err := s.invitationRepo.Update(ctx,
+		map[string]interface{}{"internal_id": invitationID, "user_id": userID},
+		map[string]interface{}{
+			"$set":   map[string]interface{}{"status": models.InvitationPending, "ch_time": time.Now().UnixNano()},
+			"$unset": map[string]interface{}{"user_id": "", "accepted_at": ""},
+		},
+	)
+	if err != nil {
+		sai.Logger().Error("Failed to reopen invitation", zap.Error(err), zap.String("invitation_id", invitationID))
+	}
+}
+
+func (s *InvitationService) invitationBody(token string) string {
+	ttl := s.config.Invitation.TTL.String()
+
+	link, ok := tokenLink(s.config.Invitation.URL, token)
+	if !ok {
+		return fmt.Sprintf("Hello,\n\nYou have been invited to create an account. Use this invitation token to sign up: %s\n\nIt expires in %s.",
+			token, ttl)
+	}
+
+	return fmt.Sprintf("Hello,\n\nYou have been invited to create an account. Open this link to sign up:\n%s\n\nIt expires in %s.",
+		link, ttl)
+}
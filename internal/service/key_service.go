@@ -0,0 +1,202 @@
+package service
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/saiset-co/sai-auth/internal/models"
+	"github.com/saiset-co/sai-auth/internal/repository"
+	"github.com/saiset-co/sai-auth/pkg/jwt"
+	"github.com/saiset-co/sai-auth/types"
+	"github.com/saiset-co/sai-service/sai"
+	saiTypes "github.com/saiset-co/sai-service/types"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultKeyRotationInterval = 7 * 24 * time.Hour
+	keyReloadInterval          = time.Minute
+)
+
+// KeyService owns the JWT signing keys. Keys live in storage so that every
+// instance signs with and publishes the same set; the in-memory copy is
+// reloaded periodically to pick up rotations made by other instances.
+type KeyService struct {
+	keyRepo repository.SigningKeyRepository
+	config  *types.SaiAuthConfig
+
+	mu       sync.Mutex
+	keys     []*models.SigningKey
+	signers  map[string]crypto.Signer
+	loadedAt time.Time
+}
+
+func NewKeyService(keyRepo repository.SigningKeyRepository, config *types.SaiAuthConfig) (*KeyService, error) {
+	if config.JWT.Algorithm == "" {
+		config.JWT.Algorithm = jwt.AlgorithmEdDSA
+	}
+
+	if config.JWT.Algorithm != jwt.AlgorithmEdDSA && config.JWT.Algorithm != jwt.AlgorithmRS256 {
+		return nil, fmt.Errorf("unsupported jwt algorithm %q", config.JWT.Algorithm)
+	}
+
+	if config.JWT.KeyRotationInterval <= 0 {
+		config.JWT.KeyRotationInterval = defaultKeyRotationInterval
+	}
+
+	if config.JWT.Issuer == "" {
+		config.JWT.Issuer = jwt.DefaultIssuer
+	}
+
+	return &KeyService{
+		keyRepo: keyRepo,
+		config:  config,
+		signers: make(map[string]crypto.Signer),
+	}, nil
+}
+
+// SigningKey returns the key new access tokens are signed with, rotating in a
+// fresh one when the current key has reached the end of its signing period.
+func (s *KeyService) SigningKey(ctx *saiTypes.RequestCtx) (*jwt.SigningKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(ctx); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UnixNano()
+	for _, key := range s.keys {
+		if key.Algorithm == s.config.JWT.Algorithm && key.SignUntil > now {
+			return &jwt.SigningKey{KeyID: key.InternalID, Algorithm: key.Algorithm, PrivateKey: s.signers[key.InternalID]}, nil
+		}
+	}
+
+	key, err := s.rotate(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rotate signing key: %w", err)
+	}
+
+	return &jwt.SigningKey{KeyID: key.InternalID, Algorithm: key.Algorithm, PrivateKey: s.signers[key.InternalID]}, nil
+}
+
+// JWKS returns the public halves of every key that may still have live
+// tokens signed with it.
+func (s *KeyService) JWKS(ctx *saiTypes.RequestCtx) (*jwt.JWKSet, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(ctx); err != nil {
+		return nil, err
+	}
+
+	set := &jwt.JWKSet{Keys: make([]jwt.JWK, 0, len(s.keys))}
+	now := time.Now().UnixNano()
+	for _, key := range s.keys {
+		if key.ExpiresAt <= now {
+			continue
+		}
+
+		jwk, err := jwt.NewJWK(key.InternalID, key.Algorithm, s.signers[key.InternalID].Public())
+		if err != nil {
+			return nil, err
+		}
+		set.Keys = append(set.Keys, *jwk)
+	}
+
+	return set, nil
+}
+
+func (s *KeyService) load(ctx *saiTypes.RequestCtx) error {
+	if s.keys != nil && time.Since(s.loadedAt) < keyReloadInterval {
+		return nil
+	}
+
+	keys, err := s.keyRepo.ListActive(ctx, time.Now().UnixNano())
+	if err != nil {
+		return fmt.Errorf("failed to load signing keys: %w", err)
+	}
+
+	signers := make(map[string]crypto.Signer, len(keys))
+	loaded := make([]*models.SigningKey, 0, len(keys))
+	for _, key := range keys {
+		signer, err := s.parsePrivateKey(key.PrivateKey)
+		if err != nil {
+			sai.Logger().Warn("Skipping unreadable signing key", zap.Error(err), zap.String("kid", key.InternalID))
+			continue
+		}
+
+		signers[key.InternalID] = signer
+		loaded = append(loaded, key)
+	}
+
+	s.keys = loaded
+	s.signers = signers
+	s.loadedAt = time.Now()
+
+	return nil
+}
+
+func (s *KeyService) rotate(ctx *saiTypes.RequestCtx) (*models.SigningKey, error) {
+	signer, err := jwt.GenerateKey(s.config.JWT.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(signer)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	signUntil := now.Add(s.config.JWT.KeyRotationInterval)
+
+	key := &models.SigningKey{
+		InternalID: uuid.New().String(),
+		Algorithm:  s.config.JWT.Algorithm,
+		PrivateKey: base64.StdEncoding.EncodeToString(der),
+		SignUntil:  signUntil.UnixNano(),
+		ExpiresAt:  signUntil.Add(s.config.AccessTokenTTL).UnixNano(),
+		CrTime:     now.UnixNano(),
+	}
+
+	if err := s.keyRepo.Create(ctx, key); err != nil {
+		return nil, err
+	}
+
+	if err := s.keyRepo.DeleteExpired(ctx, now.UnixNano()); err != nil {
+		sai.Logger().Warn("Failed to prune expired signing keys", zap.Error(err))
+	}
+
+	sai.Logger().Info("Rotated JWT signing key", zap.String("kid", key.InternalID), zap.String("alg", key.Algorithm))
+
+	s.keys = append([]*models.SigningKey{key}, s.keys...)
+	s.signers[key.InternalID] = signer
+
+	return key, nil
+}
+
+func (s *KeyService) parsePrivateKey(encoded string) (crypto.Signer, error) {
+	der, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("signing key is not a signer")
+	}
+
+	return signer, nil
+}
@@ -0,0 +1,312 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/saiset-co/sai-auth/internal/models"
+	"github.com/saiset-co/sai-auth/internal/repository"
+	"github.com/saiset-co/sai-auth/types"
+	"github.com/saiset-co/sai-service/sai"
+	saiTypes "github.com/saiset-co/sai-service/types"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultMaxUserFailures  = 5
+	defaultMaxIPFailures    = 50
+	defaultFailureWindow    = 15 * time.Minute
+	defaultLockDuration     = 15 * time.Minute
+	defaultBackoffBase      = time.Second
+	defaultBackoffMax       = 30 * time.Second
+	defaultMaxRegistrations = 10
+)
+
+// LoginThrottledError rejects a login attempt before the password is checked.
+type LoginThrottledError struct {
+	Reason     string
+	RetryAfter time.Duration
+	Locked     bool
+}
+
+func (e *LoginThrottledError) Error() string {
+	return e.Reason
+}
+
+type LockoutService struct {
+	userRepo  repository.UserRepository
+	eventRepo repository.SecurityEventRepository
+	config    *types.SaiAuthConfig
+}
+
+func NewLockoutService(userRepo repository.UserRepository, eventRepo repository.SecurityEventRepository, config *types.SaiAuthConfig) *LockoutService {
+	if config.Registration.MaxPerIP == 0 {
+		config.Registration.MaxPerIP = defaultMaxRegistrations
+	}
+
+	lockout := &config.Lockout
+	if lockout.MaxUserFailures == 0 {
+		lockout.MaxUserFailures = defaultMaxUserFailures
+	}
+	if lockout.MaxIPFailures == 0 {
+		lockout.MaxIPFailures = defaultMaxIPFailures
+	}
+	if lockout.FailureWindow <= 0 {
+		lockout.FailureWindow = defaultFailureWindow
+	}
+	if lockout.LockDuration <= 0 {
+		lockout.LockDuration = defaultLockDuration
+	}
+	if lockout.BackoffBase <= 0 {
+		lockout.BackoffBase = defaultBackoffBase
+	}
+	if lockout.BackoffMax <= 0 {
+		lockout.BackoffMax = defaultBackoffMax
+	}
+
+	return &LockoutService{
+		userRepo:  userRepo,
+		eventRepo: eventRepo,
+		config:    config,
+	}
+}
+
+// CheckIP refuses addresses that produced too many failures within the
+// failure window, whichever accounts they targeted.
+func (s *LockoutService) CheckIP(ctx *saiTypes.RequestCtx, ip string) error {
+	if s.config.Lockout.MaxIPFailures < 0 || ip == "" {
+		return nil
+	}
+
+	if s.countIPEvents(ctx, models.SecurityEventLoginFailed, ip) >= int64(s.config.Lockout.MaxIPFailures) {
+		return &LoginThrottledError{
+			Reason:     "too many failed login attempts from this address",
+			RetryAfter: s.config.Lockout.FailureWindow,
+		}
+	}
+
+	return nil
+}
+
+// CheckRegistration applies the login IP check to registrations and also
+// caps the number of accounts registered from one address.
+func (s *LockoutService) CheckRegistration(ctx *saiTypes.RequestCtx, ip string) error {
+	if err := s.CheckIP(ctx, ip); err != nil {
+		return err
+	}
+
+	if s.config.Registration.MaxPerIP < 0 || ip == "" {
+		return nil
+	}
+
+	if s.countIPEvents(ctx, models.SecurityEventUserRegistered, ip) >= int64(s.config.Registration.MaxPerIP) {
+		return &LoginThrottledError{
+			Reason:     "too many registrations from this address",
+			RetryAfter: s.config.Lockout.FailureWindow,
+		}
+	}
+
+	return nil
+}
+
+func (s *LockoutService) RecordRegistration(ctx *saiTypes.RequestCtx, user *models.User, ip, userAgent string) {
+	s.recordEvent(ctx, &models.SecurityEvent{
+		Type:      models.SecurityEventUserRegistered,
+		UserID:    user.InternalID,
+		IP:        ip,
+		UserAgent: userAgent,
+	})
+}
+
+// countIPEvents counts events of eventType from ip within the failure
+// window. Storage errors count as zero so an outage does not block logins.
+func (s *LockoutService) countIPEvents(ctx *saiTypes.RequestCtx, eventType, ip string) int64 {
+	_, count, err := s.eventRepo.List(ctx, &types.SecurityEventFilterRequest{
+		PaginationRequest: types.PaginationRequest{Limit: 1},
+		Type:              eventType,
+		IP:                ip,
+		Since:             time.Now().Add(-s.config.Lockout.FailureWindow).UnixNano(),
+	})
+	if err != nil {
+		sai.Logger().Error("Failed to count security events", zap.Error(err), zap.String("type", eventType), zap.String("ip", ip))
+		return 0
+	}
+
+	return count
+}
+
+// CheckUser refuses locked accounts and attempts made before the backoff
+// delay of the previous failure has passed.
+func (s *LockoutService) CheckUser(user *models.User) error {
+	now := time.Now().UnixNano()
+
+	if user.LockedUntil > now {
+		return &LoginThrottledError{
+			Reason:     "account is temporarily locked",
+			RetryAfter: time.Duration(user.LockedUntil - now),
+			Locked:     true,
+		}
+	}
+
+	if user.FailedLogins > 0 {
+		next := user.LastFailedLoginAt + int64(s.backoff(user.FailedLogins))
+		if now < next {
+			return &LoginThrottledError{
+				Reason:     "too many failed login attempts, retry later",
+				RetryAfter: time.Duration(next - now),
+			}
+		}
+	}
+
+	return nil
+}
+
+// RecordFailure logs a failed attempt and, for known users, bumps their
+// counter and locks the account once it reaches MaxUserFailures.
+func (s *LockoutService) RecordFailure(ctx *saiTypes.RequestCtx, user *models.User, login, ip, userAgent string) {
+	event := &models.SecurityEvent{
+		Type:      models.SecurityEventLoginFailed,
+		IP:        ip,
+		UserAgent: userAgent,
+		Details:   map[string]interface{}{"login": login},
+	}
+
+	if user != nil {
+		event.UserID = user.InternalID
+		s.countUserFailure(ctx, user, ip, userAgent)
+	}
+
+	s.recordEvent(ctx, event)
+}
+
+func (s *LockoutService) countUserFailure(ctx *saiTypes.RequestCtx, user *models.User, ip, userAgent string) {
+	filter := map[string]interface{}{"internal_id": user.InternalID}
+
+	err := s.userRepo.Update(ctx, filter, map[string]interface{}{
+		"$inc": map[string]interface{}{"failed_logins": 1},
+		"$set": map[string]interface{}{"last_failed_login_at": time.Now().UnixNano()},
+	})
+	if err != nil {
+		sai.Logger().Error("Failed to count login failure", zap.Error(err), zap.String("user_id", user.InternalID))
+		return
+	}
+
+	if s.config.Lockout.MaxUserFailures < 0 {
+		return
+	}
+
+	current, err := s.userRepo.GetByID(ctx, user.InternalID)
+	if err != nil || current.FailedLogins < s.config.Lockout.MaxUserFailures {
+		return
+	}
+
+	lockedUntil := time.Now().Add(s.config.Lockout.LockDuration).UnixNano()
+
+	err = s.userRepo.Update(ctx, filter, map[string]interface{}{
+		"$set":   map[string]interface{}{"failed_logins": 0, "locked_until": lockedUntil},
+		"$unset": map[string]interface{}{"last_failed_login_at": ""},
+	})
+	if err != nil {
+		sai.Logger().Error("Failed to lock account", zap.Error(err), zap.String("user_id", user.InternalID))
+		return
+	}
+
+	sai.Logger().Warn("Account locked after repeated login failures",
+		zap.String("user_id", user.InternalID),
+		zap.Int("failures", current.FailedLogins),
+		zap.String("ip", ip))
+
+	s.recordEvent(ctx, &models.SecurityEvent{
+		Type:      models.SecurityEventAccountLocked,
+		UserID:    user.InternalID,
+		IP:        ip,
+		UserAgent: userAgent,
+		Details: map[string]interface{}{
+			"failures":     current.FailedLogins,
+			"locked_until": lockedUntil,
+		},
+	})
+}
+
+func (s *LockoutService) RecordSuccess(ctx *saiTypes.RequestCtx, user *models.User) {
+	if user.FailedLogins == 0 && user.LockedUntil == 0 {
+		return
+	}
+
+	if err := s.reset(ctx, user.InternalID); err != nil {
+		sai.Logger().Error("Failed to reset login failures", zap.Error(err), zap.String("user_id", user.InternalID))
+	}
+}
+
+func (s *LockoutService) Unlock(ctx *saiTypes.RequestCtx, userID string) error {
+	if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+		return fmt.Errorf("user not found")
+	}
+
+	if err := s.reset(ctx, userID); err != nil {
+		return fmt.Errorf("failed to unlock user: %w", err)
+	}
+
+	event := &models.SecurityEvent{
+		Type:   models.SecurityEventAccountUnlocked,
+		UserID: userID,
+	}
+	if adminID, ok := ctx.UserValue("user_id").(string); ok {
+		event.Details = map[string]interface{}{"unlocked_by": adminID}
+	}
+	s.recordEvent(ctx, event)
+
+	return nil
+}
+
+func (s *LockoutService) LoginFailures(ctx *saiTypes.RequestCtx, filter *types.SecurityEventFilterRequest) ([]*models.SecurityEvent, int64, error) {
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.Limit < 1 {
+		filter.Limit = 20
+	}
+	if filter.Limit > 100 {
+		filter.Limit = 100
+	}
+
+	filter.Type = models.SecurityEventLoginFailed
+
+	return s.eventRepo.List(ctx, filter)
+}
+
+func (s *LockoutService) reset(ctx *saiTypes.RequestCtx, userID string) error {
+	return s.userRepo.Update(ctx,
+		map[string]interface{}{"internal_id": userID},
+		map[string]interface{}{
+			"$set":   map[string]interface{}{"failed_logins": 0},
+			"$unset": map[string]interface{}{"locked_until": "", "last_failed_login_at": ""},
+		},
+	)
+}
+
+// backoff doubles the delay with every consecutive failure, capped at
+// BackoffMax.
+func (s *LockoutService) backoff(failures int) time.Duration {
+	delay := s.config.Lockout.BackoffBase
+	for i := 1; i < failures && delay < s.config.Lockout.BackoffMax; i++ {
+		delay *= 2
+	}
+
+	if delay > s.config.Lockout.BackoffMax {
+		delay = s.config.Lockout.BackoffMax
+	}
+
+	return delay
+}
+
+func (s *LockoutService) recordEvent(ctx *saiTypes.RequestCtx, event *models.SecurityEvent) {
+	event.InternalID = uuid.New().String()
+	event.CrTime = time.Now().UnixNano()
+
+	if err := s.eventRepo.Create(ctx, event); err != nil {
+		sai.Logger().Error("Failed to record security event", zap.Error(err), zap.String("type", event.Type))
+	}
+}
@@ -0,0 +1,293 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/saiset-co/sai-auth/internal/models"
+	"github.com/saiset-co/sai-auth/internal/repository"
+	"github.com/saiset-co/sai-auth/pkg/totp"
+	"github.com/saiset-co/sai-auth/types"
+	saiTypes "github.com/saiset-co/sai-service/types"
+)
+
+const (
+	recoveryCodesCount       = 10
+	defaultMFAChallengeTTL   = 5 * time.Minute
+	defaultMFAMaxAttempts    = 5
+	defaultMFAOpenChallenges = 3
+	defaultMFAIssuer         = "sai-auth"
+	recoveryCodeGroupLength  = 4
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type MFAService struct {
+	userRepo      repository.UserRepository
+	challengeRepo repository.MFAChallengeRepository
+	config        *types.SaiAuthConfig
+	recoveryKey   []byte
+}
+
+func NewMFAService(userRepo repository.UserRepository, challengeRepo repository.MFAChallengeRepository, config *types.SaiAuthConfig) *MFAService {
+	if config.MFA.Issuer == "" {
+		config.MFA.Issuer = defaultMFAIssuer
+	}
+	if config.MFA.ChallengeTTL <= 0 {
+		config.MFA.ChallengeTTL = defaultMFAChallengeTTL
+	}
+	if config.MFA.MaxAttempts <= 0 {
+		config.MFA.MaxAttempts = defaultMFAMaxAttempts
+	}
+	if config.MFA.MaxOpenChallenges <= 0 {
+		config.MFA.MaxOpenChallenges = defaultMFAOpenChallenges
+	}
+
+	recoveryKey := sha256.Sum256([]byte("sai-auth:recovery-codes:" + config.SecretKey))
+
+	return &MFAService{
+		userRepo:      userRepo,
+		challengeRepo: challengeRepo,
+		config:        config,
+		recoveryKey:   recoveryKey[:],
+	}
+}
+
+// Enroll stores a pending TOTP secret. MFA is not enforced until the secret
+// is confirmed with a valid code.
+func (s *MFAService) Enroll(ctx *saiTypes.RequestCtx, user *models.User) (*models.MFAEnrollResponse, error) {
+	if user.MFAEnabled {
+		return nil, fmt.Errorf("mfa already enabled")
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate mfa secret: %w", err)
+	}
+
+	err = s.userRepo.Update(ctx,
+		map[string]interface{}{"internal_id": user.InternalID},
+		map[string]interface{}{"$set": map[string]interface{}{"mfa_pending_secret": secret}},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store mfa secret: %w", err)
+	}
+
+	return &models.MFAEnrollResponse{
+		Secret: secret,
+		URI:    totp.URI(secret, s.config.MFA.Issuer, user.Username),
+	}, nil
+}
+
+// Confirm activates the pending secret and returns the recovery codes. They
+// are only ever returned here; storage keeps their hashes.
+func (s *MFAService) Confirm(ctx *saiTypes.RequestCtx, user *models.User, code string) (*models.MFAConfirmResponse, error) {
+	if user.MFAEnabled {
+		return nil, fmt.Errorf("mfa already enabled")
+	}
+
+	if user.MFAPendingSecret == "" {
+		return nil, fmt.Errorf("mfa enrollment not started")
+	}
+
+	step, ok := totp.Validate(user.MFAPendingSecret, code, time.Now(), 0)
+	if !ok {
+		return nil, fmt.Errorf("invalid mfa code")
+	}
+
+	codes, hashes, err := s.generateRecoveryCodes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate recovery codes: %w", err)
+	}
+
+	err = s.userRepo.Update(ctx,
+		map[string]interface{}{"internal_id": user.InternalID},
+		map[string]interface{}{
+			"$set": map[string]interface{}{
+				"mfa_enabled":        true,
+				"mfa_secret":         user.MFAPendingSecret,
+				"mfa_recovery_codes": hashes,
+				"mfa_last_step":      step,
+				"ch_time":            time.Now().UnixNano(),
+			},
+			"$unset": map[string]interface{}{"mfa_pending_secret": ""},
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to enable mfa: %w", err)
+	}
+
+	return &models.MFAConfirmResponse{RecoveryCodes: codes}, nil
+}
+
+func (s *MFAService) Disable(ctx *saiTypes.RequestCtx, user *models.User, code string) error {
+	if !user.MFAEnabled {
+		return fmt.Errorf("mfa is not enabled")
+	}
+
+	if err := s.verifyCode(ctx, user, code); err != nil {
+		return err
+	}
+
+	err := s.userRepo.Update(ctx,
+		map[string]interface{}{"internal_id": user.InternalID},
+		map[string]interface{}{
+			"$set": map[string]interface{}{
+				"mfa_enabled": false,
+				"ch_time":     time.Now().UnixNano(),
+			},
+			"$unset": map[string]interface{}{
+				"mfa_secret":         "",
+				"mfa_pending_secret": "",
+				"mfa_recovery_codes": "",
+				"mfa_last_step":      "",
+			},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to disable mfa: %w", err)
+	}
+
+	return nil
+}
+
+// CreateChallenge opens a login challenge, first dropping the oldest open
+// ones so that no more than MaxOpenChallenges exist for the user.
+func (s *MFAService) CreateChallenge(ctx *saiTypes.RequestCtx, user *models.User, renew bool, tenantID string) (*models.MFAChallenge, error) {
+	open, err := s.challengeRepo.ListByUserID(ctx, user.InternalID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := s.config.MFA.MaxOpenChallenges - 1; i < len(open); i++ {
+		if err := s.challengeRepo.Delete(ctx, open[i].InternalID); err != nil {
+			return nil, err
+		}
+	}
+
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	challenge := &models.MFAChallenge{
+		InternalID: uuid.New().String(),
+		UserID:     user.InternalID,
+		Token:      hex.EncodeToString(token),
+		Renew:      renew,
+		TenantID:   tenantID,
+		ExpiresAt:  now.Add(s.config.MFA.ChallengeTTL).UnixNano(),
+		CrTime:     now.UnixNano(),
+	}
+
+	if err := s.challengeRepo.Create(ctx, challenge); err != nil {
+		return nil, err
+	}
+
+	return challenge, nil
+}
+
+// OpenChallenge looks up a login challenge and its user without consuming
+// it, so the caller can apply lockout before a code is checked.
+func (s *MFAService) OpenChallenge(ctx *saiTypes.RequestCtx, token string) (*models.User, *models.MFAChallenge, error) {
+	challenge, err := s.challengeRepo.GetByToken(ctx, token)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid or expired mfa token")
+	}
+
+	if challenge.Attempts >= s.config.MFA.MaxAttempts {
+		s.challengeRepo.Delete(ctx, challenge.InternalID)
+		return nil, nil, fmt.Errorf("too many mfa attempts")
+	}
+
+	user, err := s.userRepo.GetByID(ctx, challenge.UserID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid or expired mfa token")
+	}
+
+	return user, challenge, nil
+}
+
+// VerifyChallenge consumes a login challenge once code checks out. A challenge
+// is dropped after MaxAttempts wrong codes so it cannot be brute forced.
+func (s *MFAService) VerifyChallenge(ctx *saiTypes.RequestCtx, user *models.User, challenge *models.MFAChallenge, code string) error {
+	if err := s.verifyCode(ctx, user, code); err != nil {
+		if challenge.Attempts+1 >= s.config.MFA.MaxAttempts {
+			s.challengeRepo.Delete(ctx, challenge.InternalID)
+		} else {
+			s.challengeRepo.IncrementAttempts(ctx, challenge.InternalID)
+		}
+		return err
+	}
+
+	s.challengeRepo.Delete(ctx, challenge.InternalID)
+
+	return nil
+}
+
+// verifyCode accepts either a current TOTP code or an unused recovery code,
+// burning whichever was used.
+func (s *MFAService) verifyCode(ctx *saiTypes.RequestCtx, user *models.User, code string) error {
+	if step, ok := totp.Validate(user.MFASecret, code, time.Now(), user.MFALastStep); ok {
+		return s.userRepo.Update(ctx,
+			map[string]interface{}{"internal_id": user.InternalID},
+			map[string]interface{}{"$set": map[string]interface{}{"mfa_last_step": step}},
+		)
+	}
+
+	hash := s.hashRecoveryCode(code)
+	for _, stored := range user.MFARecoveryCodes {
+		if hmac.Equal([]byte(stored), []byte(hash)) {
+			return s.userRepo.Update(ctx,
+				map[string]interface{}{"internal_id": user.InternalID},
+				map[string]interface{}{"$pull": map[string]interface{}{"mfa_recovery_codes": hash}},
+			)
+		}
+	}
+
+	return fmt.Errorf("invalid mfa code")
+}
+
+func (s *MFAService) generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodesCount)
+	hashes := make([]string, 0, recoveryCodesCount)
+
+	for i := 0; i < recoveryCodesCount; i++ {
+		raw := make([]byte, 10)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, err
+		}
+
+		encoded := strings.ToLower(recoveryCodeEncoding.EncodeToString(raw))
+
+		groups := make([]string, 0, len(encoded)/recoveryCodeGroupLength)
+		for j := 0; j < len(encoded); j += recoveryCodeGroupLength {
+			groups = append(groups, encoded[j:j+recoveryCodeGroupLength])
+		}
+
+		code := strings.Join(groups, "-")
+		codes = append(codes, code)
+		hashes = append(hashes, s.hashRecoveryCode(code))
+	}
+
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode hashes with a key derived from the secret for recovery
+// codes only.
+func (s *MFAService) hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(code)))
+
+	mac := hmac.New(sha256.New, s.recoveryKey)
+	mac.Write([]byte("recovery:" + normalized))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"github.com/saiset-co/sai-auth/pkg/notifier"
+	"github.com/saiset-co/sai-service/sai"
+	"go.uber.org/zap"
+)
+
+const notificationTimeout = 30 * time.Second
+
+// notifyInBackground sends msg without holding up the request, so response
+// times do not reveal whether a mail went out.
+func notifyInBackground(n notifier.Notifier, msg *notifier.Message, userID string) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), notificationTimeout)
+		defer cancel()
+
+		if err := n.Send(ctx, msg); err != nil {
+			sai.Logger().Error("Failed to send notification",
+				zap.Error(err),
+				zap.String("user_id", userID),
+				zap.String("subject", msg.Subject))
+		}
+	}()
+}
+
+// tokenLink appends token to baseURL as the "token" query parameter. It
+// returns false when no usable URL is configured.
+func tokenLink(baseURL, token string) (string, bool) {
+	if baseURL == "" {
+		return "", false
+	}
+
+	link, err := url.Parse(baseURL)
+	if err != nil {
+		return "", false
+	}
+
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+
+	return link.String(), true
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/saiset-co/sai-auth/internal/models"
+	"github.com/saiset-co/sai-auth/pkg/hasher"
+	"github.com/saiset-co/sai-auth/types"
+)
+
+const (
+	defaultPasswordMinLength = 8
+	defaultPasswordMaxLength = 72
+)
+
+//go:embed common_passwords.txt
+var commonPasswordsList string
+
+// PasswordPolicyError lists every rule a password broke so the caller can fix
+// them all at once.
+type PasswordPolicyError struct {
+	Violations []string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return "password does not meet policy: " + strings.Join(e.Violations, "; ")
+}
+
+type PasswordPolicy struct {
+	config          *types.PasswordPolicyConfig
+	hasher          hasher.Hasher
+	commonPasswords map[string]bool
+}
+
+func NewPasswordPolicy(config *types.PasswordPolicyConfig, passwordHasher hasher.Hasher) *PasswordPolicy {
+	if config.MinLength <= 0 {
+		config.MinLength = defaultPasswordMinLength
+	}
+	if config.MaxLength <= 0 {
+		config.MaxLength = defaultPasswordMaxLength
+	}
+
+	policy := &PasswordPolicy{config: config, hasher: passwordHasher}
+
+	if config.DenyCommon {
+		policy.commonPasswords = make(map[string]bool)
+		for _, line := range strings.Split(commonPasswordsList, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				policy.commonPasswords[strings.ToLower(line)] = true
+			}
+		}
+	}
+
+	return policy
+}
+
+// Validate checks password against the composition rules. user may be nil for
+// accounts that do not exist yet; when set, the password may not contain the
+// username or reuse a recent password.
+func (p *PasswordPolicy) Validate(password string, user *models.User) error {
+	var violations []string
+
+	length := len([]rune(password))
+	if length < p.config.MinLength {
+		violations = append(violations, fmt.Sprintf("must be at least %d characters", p.config.MinLength))
+	}
+	if len(password) > p.config.MaxLength {
+		violations = append(violations, fmt.Sprintf("must be at most %d bytes", p.config.MaxLength))
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+
+	if p.config.RequireUpper && !hasUpper {
+		violations = append(violations, "must contain an uppercase letter")
+	}
+	if p.config.RequireLower && !hasLower {
+		violations = append(violations, "must contain a lowercase letter")
+	}
+	if p.config.RequireDigit && !hasDigit {
+		violations = append(violations, "must contain a digit")
+	}
+	if p.config.RequireSymbol && !hasSymbol {
+		violations = append(violations, "must contain a symbol")
+	}
+
+	if p.commonPasswords[strings.ToLower(password)] {
+		violations = append(violations, "is too common")
+	}
+
+	if user != nil {
+		if user.Username != "" && strings.Contains(strings.ToLower(password), strings.ToLower(user.Username)) {
+			violations = append(violations, "must not contain the username")
+		}
+
+		if p.reused(password, user) {
+			violations = append(violations, fmt.Sprintf("must differ from the last %d passwords", p.config.HistorySize))
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+
+	return nil
+}
+
+func (p *PasswordPolicy) reused(password string, user *models.User) bool {
+	if p.config.HistorySize <= 0 {
+		return false
+	}
+
+	if user.PasswordHash != "" && p.hasher.Verify(user.PasswordHash, password) {
+		return true
+	}
+
+	for _, hash := range user.PasswordHistory {
+		if p.hasher.Verify(hash, password) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// History returns the password history to store once user switches to a new
+// password: the current hash followed by the older ones, trimmed so that
+// together with the new hash it covers HistorySize passwords.
+func (p *PasswordPolicy) History(user *models.User) []string {
+	if p.config.HistorySize <= 1 || user.PasswordHash == "" {
+		return []string{}
+	}
+
+	history := append([]string{user.PasswordHash}, user.PasswordHistory...)
+	if len(history) > p.config.HistorySize-1 {
+		history = history[:p.config.HistorySize-1]
+	}
+
+	return history
+}
+
+// Expired reports whether the user has to change the password before logging
+// in. Accounts that predate password tracking count from their creation.
+func (p *PasswordPolicy) Expired(user *models.User) bool {
+	if p.config.MaxAge <= 0 {
+		return false
+	}
+
+	changedAt := user.PasswordChangedAt
+	if changedAt == 0 {
+		changedAt = user.CrTime
+	}
+	if changedAt == 0 {
+		return false
+	}
+
+	return time.Now().UnixNano() > changedAt+int64(p.config.MaxAge)
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/saiset-co/sai-auth/internal/models"
+	"github.com/saiset-co/sai-auth/internal/repository"
+	"github.com/saiset-co/sai-auth/pkg/notifier"
+	"github.com/saiset-co/sai-auth/types"
+	"github.com/saiset-co/sai-service/sai"
+	saiTypes "github.com/saiset-co/sai-service/types"
+	"go.uber.org/zap"
+)
+
+const defaultPasswordResetTTL = time.Hour
+
+type PasswordResetService struct {
+	userRepo        repository.UserRepository
+	tokenRepo       repository.TokenRepository
+	actionTokenRepo repository.ActionTokenRepository
+	authService     *AuthService
+	notifier        notifier.Notifier
+	config          *types.SaiAuthConfig
+}
+
+func NewPasswordResetService(
+	userRepo repository.UserRepository,
+	tokenRepo repository.TokenRepository,
+	actionTokenRepo repository.ActionTokenRepository,
+	authService *AuthService,
+	notifier notifier.Notifier,
+	config *types.SaiAuthConfig,
+) *PasswordResetService {
+	if config.PasswordReset.TokenTTL <= 0 {
+		config.PasswordReset.TokenTTL = defaultPasswordResetTTL
+	}
+
+	return &PasswordResetService{
+		userRepo:        userRepo,
+		tokenRepo:       tokenRepo,
+		actionTokenRepo: actionTokenRepo,
+		authService:     authService,
+		notifier:        notifier,
+		config:          config,
+	}
+}
+
+// Forgot mails a reset token to the user. It reports success for unknown or
+// inactive users too, so the endpoint cannot be used to probe accounts, and
+// delivery happens in the background for the same reason.
+func (s *PasswordResetService) Forgot(ctx *saiTypes.RequestCtx, req *models.ForgotPasswordRequest) error {
+	user, err := s.authService.findUser(ctx, req.User)
+	if err != nil || !user.IsActive || user.Email == "" {
+		return nil
+	}
+
+	if err := s.actionTokenRepo.DeleteByUserID(ctx, user.InternalID, models.ActionTokenPasswordReset); err != nil {
+		sai.Logger().Warn("Failed to drop previous reset tokens", zap.Error(err), zap.String("user_id", user.InternalID))
+	}
+
+	token, err := s.authService.generateRandomString(32)
+	if err != nil {
+		return fmt.Errorf("failed to generate reset token: %w", err)
+	}
+
+	now := time.Now()
+	err = s.actionTokenRepo.Create(ctx, &models.ActionToken{
+		InternalID: uuid.New().String(),
+		UserID:     user.InternalID,
+		Purpose:    models.ActionTokenPasswordReset,
+		Token:      token,
+		ExpiresAt:  now.Add(s.config.PasswordReset.TokenTTL).UnixNano(),
+		CrTime:     now.UnixNano(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store reset token: %w", err)
+	}
+
+	notifyInBackground(s.notifier, &notifier.Message{
+		To:      user.Email,
+		Subject: "Password reset",
+		Body:    s.resetBody(user, token),
+	}, user.InternalID)
+
+	return nil
+}
+
+// Reset consumes a reset token, sets the new password and revokes every
+// session of the user.
+func (s *PasswordResetService) Reset(ctx *saiTypes.RequestCtx, req *models.ResetPasswordRequest) error {
+	actionToken, err := s.actionTokenRepo.GetByToken(ctx, models.ActionTokenPasswordReset, req.Token)
+	if err != nil {
+		return fmt.Errorf("invalid or expired reset token")
+	}
+
+	user, err := s.userRepo.GetByID(ctx, actionToken.UserID)
+	if err != nil || !user.IsActive {
+		s.actionTokenRepo.Delete(ctx, actionToken.InternalID)
+		return fmt.Errorf("invalid or expired reset token")
+	}
+
+	fields, err := s.authService.PasswordChange(user, req.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	if err := s.actionTokenRepo.Delete(ctx, actionToken.InternalID); err != nil {
+		return fmt.Errorf("failed to consume reset token: %w", err)
+	}
+
+	err = s.userRepo.Update(ctx,
+		map[string]interface{}{"internal_id": user.InternalID},
+		map[string]interface{}{"$set": fields},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	if err := s.tokenRepo.DeleteByUserID(ctx, user.InternalID); err != nil {
+		sai.Logger().Error("Failed to revoke sessions after password reset", zap.Error(err), zap.String("user_id", user.InternalID))
+	}
+
+	s.authService.recordSecurityEvent(ctx, &models.SecurityEvent{
+		Type:      models.SecurityEventPasswordReset,
+		UserID:    user.InternalID,
+		IP:        req.IP,
+		UserAgent: req.UserAgent,
+	})
+
+	return nil
+}
+
+func (s *PasswordResetService) resetBody(user *models.User, token string) string {
+	ttl := s.config.PasswordReset.TokenTTL.String()
+
+	link, ok := tokenLink(s.config.PasswordReset.URL, token)
+	if !ok {
+		return fmt.Sprintf("Hello %s,\n\nUse this token to reset your password: %s\n\nIt expires in %s. If you did not ask for a reset, ignore this message.",
+			user.Username, token, ttl)
+	}
+
+	return fmt.Sprintf("Hello %s,\n\nOpen this link to reset your password:\n%s\n\nIt expires in %s. If you did not ask for a reset, ignore this message.",
+		user.Username, link, ttl)
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"sync"
+
+	"github.com/saiset-co/sai-auth/internal/models"
+	"github.com/saiset-co/sai-auth/pkg/pathmatch"
+)
+
+// sessionIndexLimit bounds the number of cached session indexes.
+const sessionIndexLimit = 10000
+
+// PermissionIndex looks up compiled permissions by microservice, method and
+// path, returning the matches most specific first.
+type PermissionIndex struct {
+	permissions []models.CompiledPermission
+	paths       map[string]*pathmatch.Index
+}
+
+func NewPermissionIndex(permissions []models.CompiledPermission) *PermissionIndex {
+	index := &PermissionIndex{
+		permissions: permissions,
+		paths:       make(map[string]*pathmatch.Index),
+	}
+
+	for i := range permissions {
+		key := indexKey(permissions[i].Microservice, permissions[i].Method)
+		paths, ok := index.paths[key]
+		if !ok {
+			paths = pathmatch.NewIndex()
+			index.paths[key] = paths
+		}
+		paths.Add(permissions[i].Path, i)
+	}
+
+	return index
+}
+
+// PermissionMatch is a permission covering a request with the path segments
+// captured by its template.
+type PermissionMatch struct {
+	Permission *models.CompiledPermission
+	PathParams map[string]string
+}
+
+// Match returns the permissions covering the request, most specific first.
+func (i *PermissionIndex) Match(microservice, method, path string) []PermissionMatch {
+	paths, ok := i.paths[indexKey(microservice, method)]
+	if !ok {
+		return nil
+	}
+
+	results := paths.Lookup(path)
+	matches := make([]PermissionMatch, len(results))
+	for n, result := range results {
+		matches[n] = PermissionMatch{
+			Permission: &i.permissions[result.ID],
+			PathParams: result.Params,
+		}
+	}
+	return matches
+}
+
+func indexKey(microservice, method string) string {
+	return microservice + " " + method
+}
+
+// sessionIndexes caches the permission index of each session until its
+// compiled permissions change, which always bumps the token's ch_time.
+type sessionIndexes struct {
+	mu      sync.Mutex
+	entries map[string]sessionIndex
+}
+
+type sessionIndex struct {
+	version int64
+	index   *PermissionIndex
+}
+
+func (c *sessionIndexes) get(token *models.Token) *PermissionIndex {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cached, ok := c.entries[token.InternalID]; ok && cached.version == token.UpdatedAt {
+		return cached.index
+	}
+
+	if c.entries == nil || len(c.entries) >= sessionIndexLimit {
+		c.entries = make(map[string]sessionIndex)
+	}
+
+	index := NewPermissionIndex(token.CompiledPermissions)
+	c.entries[token.InternalID] = sessionIndex{version: token.UpdatedAt, index: index}
+
+	return index
+}
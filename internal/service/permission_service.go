@@ -4,26 +4,47 @@ import (
 	"fmt"
 	"github.com/saiset-co/sai-auth/internal/models"
 	"github.com/saiset-co/sai-auth/internal/repository"
+	"github.com/saiset-co/sai-auth/pkg/jwt"
+	"github.com/saiset-co/sai-auth/pkg/pathmatch"
+	"github.com/saiset-co/sai-auth/types"
 	saiTypes "github.com/saiset-co/sai-service/types"
+	"sort"
 	"strings"
+	"time"
 )
 
+const tenantPlaceholder = "$.tenant_id"
+
+// groupScanLimit bounds the groups read in one storage call when resolving
+// membership.
+const groupScanLimit = 1000
+
 type PermissionService struct {
-	roleRepo repository.RoleRepository
+	roleRepo  repository.RoleRepository
+	groupRepo repository.GroupRepository
+	sessions  sessionIndexes
 }
 
-func NewPermissionService(roleRepo repository.RoleRepository) *PermissionService {
+func NewPermissionService(roleRepo repository.RoleRepository, groupRepo repository.GroupRepository) *PermissionService {
 	return &PermissionService{
-		roleRepo: roleRepo,
+		roleRepo:  roleRepo,
+		groupRepo: groupRepo,
 	}
 }
 
-func (s *PermissionService) CompilePermissions(ctx *saiTypes.RequestCtx, user *models.User) ([]models.CompiledPermission, error) {
-	if len(user.Roles) == 0 {
+// CompilePermissions merges the permissions of the user's roles and the
+// roles of their groups for a session in tenantID, skipping assignments
+// outside their time window and roles of other tenants.
+func (s *PermissionService) CompilePermissions(ctx *saiTypes.RequestCtx, user *models.User, tenantID string) ([]models.CompiledPermission, error) {
+	roleIDs, sources, err := s.effectiveRoles(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	if len(roleIDs) == 0 {
 		return []models.CompiledPermission{}, nil
 	}
 
-	allRoles, err := s.collectAllRoles(ctx, user.Roles, make(map[string]bool), 0)
+	allRoles, err := s.collectAllRoles(ctx, roleIDs, tenantID, make(map[string]bool), 0)
 	if err != nil {
 		return nil, err
 	}
@@ -31,13 +52,32 @@ func (s *PermissionService) CompilePermissions(ctx *saiTypes.RequestCtx, user *m
 	permissionMap := make(map[string]*models.CompiledPermission)
 
 	for _, role := range allRoles {
+		provenance := sources[role.InternalID]
+		if len(provenance) == 0 {
+			provenance = []string{role.InternalID}
+		}
+
 		for _, permission := range role.Permissions {
+			if tenantID == "" && usesTenantPlaceholder(&permission) {
+				continue
+			}
+
+			if permission.Effect == models.EffectDeny {
+				key := fmt.Sprintf("deny:%s:%s:%s", permission.Microservice, permission.Method, permission.Path)
+				if existing, exists := permissionMap[key]; exists {
+					existing.InheritedFrom = append(existing.InheritedFrom, provenance...)
+				} else {
+					permissionMap[key] = compileDeny(&permission, provenance)
+				}
+				continue
+			}
+
 			key := fmt.Sprintf("%s:%s:%s", permission.Microservice, permission.Method, permission.Path)
 
 			if existing, exists := permissionMap[key]; exists {
-				s.mergePermissions(existing, &permission, role.InternalID, user)
+				s.mergePermissions(existing, &permission, provenance, user, tenantID)
 			} else {
-				compiled := s.compilePermission(&permission, role.InternalID, user)
+				compiled := s.compilePermission(&permission, provenance, user, tenantID)
 				permissionMap[key] = compiled
 			}
 		}
@@ -48,10 +88,143 @@ func (s *PermissionService) CompilePermissions(ctx *saiTypes.RequestCtx, user *m
 		result = append(result, *permission)
 	}
 
+	sort.Slice(result, func(i, j int) bool {
+		a, b := &result[i], &result[j]
+		if a.Microservice != b.Microservice {
+			return a.Microservice < b.Microservice
+		}
+		if a.Method != b.Method {
+			return a.Method < b.Method
+		}
+		if a.Path != b.Path {
+			return pathmatch.Less(a.Path, b.Path)
+		}
+		return a.Effect > b.Effect
+	})
+
 	return result, nil
 }
 
-func (s *PermissionService) collectAllRoles(ctx *saiTypes.RequestCtx, roleIDs []string, visited map[string]bool, depth int) ([]*models.Role, error) {
+// usesTenantPlaceholder reports whether the permission pins params to the
+// session tenant. Such permissions are left out of sessions without a tenant
+// rather than compiled with an empty value that would not restrict anything.
+func usesTenantPlaceholder(permission *models.Permission) bool {
+	for _, params := range [][]models.Params{permission.RequiredParams, permission.RestrictedParams} {
+		for _, param := range params {
+			if param.Value == tenantPlaceholder {
+				return true
+			}
+			for _, value := range append(append([]string{}, param.AnyValue...), param.AllValues...) {
+				if value == tenantPlaceholder {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// effectiveRoles returns the user's role IDs, direct ones first, with where
+// each came from: the role ID for a direct assignment and "group:<id>/<role>"
+// for a role of a group the user belongs to directly or through nesting.
+func (s *PermissionService) effectiveRoles(ctx *saiTypes.RequestCtx, user *models.User) ([]string, map[string][]string, error) {
+	var roleIDs []string
+	sources := make(map[string][]string)
+
+	add := func(roleID, source string) {
+		if _, exists := sources[roleID]; !exists {
+			roleIDs = append(roleIDs, roleID)
+		}
+		sources[roleID] = append(sources[roleID], source)
+	}
+
+	for _, roleID := range user.ActiveRoles(time.Now().UnixNano()) {
+		add(roleID, roleID)
+	}
+
+	groups, err := s.UserGroups(ctx, user.InternalID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, group := range groups {
+		for _, roleID := range group.Roles {
+			add(roleID, fmt.Sprintf("group:%s/%s", group.InternalID, roleID))
+		}
+	}
+
+	return roleIDs, sources, nil
+}
+
+// HasRoles reports whether the user has roles of their own or through a
+// group.
+func (s *PermissionService) HasRoles(ctx *saiTypes.RequestCtx, user *models.User) bool {
+	if len(user.Roles) > 0 {
+		return true
+	}
+
+	groups, err := s.UserGroups(ctx, user.InternalID)
+	if err != nil {
+		return false
+	}
+
+	for _, group := range groups {
+		if len(group.Roles) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// UserGroups returns the active groups the user is a member of and their
+// active ancestors. Inactive groups pass nothing on to their members.
+func (s *PermissionService) UserGroups(ctx *saiTypes.RequestCtx, userID string) ([]*models.Group, error) {
+	if s.groupRepo == nil || userID == "" {
+		return nil, nil
+	}
+
+	current, _, err := s.groupRepo.List(ctx, &types.GroupFilterRequest{
+		PaginationRequest: types.PaginationRequest{Limit: groupScanLimit},
+		Member:            userID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load groups: %w", err)
+	}
+
+	var result []*models.Group
+	visited := make(map[string]bool)
+
+	for depth := 0; len(current) > 0; depth++ {
+		if depth > 5 {
+			return nil, fmt.Errorf("maximum group nesting depth exceeded")
+		}
+
+		var parentIDs []string
+		for _, group := range current {
+			if visited[group.InternalID] || !group.IsActive {
+				continue
+			}
+			visited[group.InternalID] = true
+
+			result = append(result, group)
+			parentIDs = append(parentIDs, group.ParentGroups...)
+		}
+
+		if len(parentIDs) == 0 {
+			break
+		}
+
+		current, err = s.groupRepo.GetByIDs(ctx, parentIDs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load groups: %w", err)
+		}
+	}
+
+	return result, nil
+}
+
+func (s *PermissionService) collectAllRoles(ctx *saiTypes.RequestCtx, roleIDs []string, tenantID string, visited map[string]bool, depth int) ([]*models.Role, error) {
 	if depth > 5 {
 		return nil, fmt.Errorf("maximum role inheritance depth exceeded")
 	}
@@ -74,12 +247,16 @@ func (s *PermissionService) collectAllRoles(ctx *saiTypes.RequestCtx, roleIDs []
 			continue
 		}
 
+		if role.TenantID != "" && role.TenantID != tenantID {
+			continue
+		}
+
 		allRoles = append(allRoles, role)
 		parentRoleIDs = append(parentRoleIDs, role.ParentRoles...)
 	}
 
 	if len(parentRoleIDs) > 0 {
-		parentRoles, err := s.collectAllRoles(ctx, parentRoleIDs, visited, depth+1)
+		parentRoles, err := s.collectAllRoles(ctx, parentRoleIDs, tenantID, visited, depth+1)
 		if err != nil {
 			return nil, err
 		}
@@ -89,7 +266,47 @@ func (s *PermissionService) collectAllRoles(ctx *saiTypes.RequestCtx, roleIDs []
 	return allRoles, nil
 }
 
-func (s *PermissionService) compilePermission(permission *models.Permission, roleID string, user *models.User) *models.CompiledPermission {
+// compileDeny keeps only the endpoint of a deny; its params are never
+// merged into an allow for the same endpoint.
+func compileDeny(permission *models.Permission, provenance []string) *models.CompiledPermission {
+	return &models.CompiledPermission{
+		Microservice:     permission.Microservice,
+		Method:           permission.Method,
+		Path:             permission.Path,
+		Effect:           models.EffectDeny,
+		Rates:            []models.Rate{},
+		RequiredParams:   []models.Params{},
+		RestrictedParams: []models.Params{},
+		InheritedFrom:    append([]string{}, provenance...),
+	}
+}
+
+// ValidatePermissions checks the permissions of a role before it is saved.
+func (s *PermissionService) ValidatePermissions(permissions []models.Permission) error {
+	if len(permissions) > 50 {
+		return fmt.Errorf("maximum 50 permissions per role exceeded")
+	}
+
+	for _, permission := range permissions {
+		if err := pathmatch.Validate(permission.Path); err != nil {
+			return err
+		}
+
+		switch permission.Effect {
+		case "", models.EffectAllow:
+		case models.EffectDeny:
+			if len(permission.Rates) > 0 || len(permission.RequiredParams) > 0 || len(permission.RestrictedParams) > 0 {
+				return fmt.Errorf("deny permission %s %s cannot have params or rates", permission.Method, permission.Path)
+			}
+		default:
+			return fmt.Errorf("permission effect must be %q or %q", models.EffectAllow, models.EffectDeny)
+		}
+	}
+
+	return nil
+}
+
+func (s *PermissionService) compilePermission(permission *models.Permission, provenance []string, user *models.User, tenantID string) *models.CompiledPermission {
 	compiled := &models.CompiledPermission{
 		Microservice:     permission.Microservice,
 		Method:           permission.Method,
@@ -97,29 +314,29 @@ func (s *PermissionService) compilePermission(permission *models.Permission, rol
 		Rates:            permission.Rates,
 		RequiredParams:   make([]models.Params, 0, len(permission.RequiredParams)),
 		RestrictedParams: make([]models.Params, 0, len(permission.RestrictedParams)),
-		InheritedFrom:    []string{roleID},
+		InheritedFrom:    append([]string{}, provenance...),
 	}
 
 	for _, param := range permission.RequiredParams {
-		processedParam := s.processPlaceholders(param, user)
+		processedParam := s.processPlaceholders(param, user, tenantID)
 		compiled.RequiredParams = append(compiled.RequiredParams, processedParam)
 	}
 
 	for _, param := range permission.RestrictedParams {
-		processedParam := s.processPlaceholders(param, user)
+		processedParam := s.processPlaceholders(param, user, tenantID)
 		compiled.RestrictedParams = append(compiled.RestrictedParams, processedParam)
 	}
 
 	return compiled
 }
 
-func (s *PermissionService) mergePermissions(existing *models.CompiledPermission, newPerm *models.Permission, roleID string, user *models.User) {
-	existing.InheritedFrom = append(existing.InheritedFrom, roleID)
+func (s *PermissionService) mergePermissions(existing *models.CompiledPermission, newPerm *models.Permission, provenance []string, user *models.User, tenantID string) {
+	existing.InheritedFrom = append(existing.InheritedFrom, provenance...)
 
 	existing.Rates = append(existing.Rates, newPerm.Rates...)
 
 	for _, param := range newPerm.RequiredParams {
-		processedParam := s.processPlaceholders(param, user)
+		processedParam := s.processPlaceholders(param, user, tenantID)
 		found := false
 		for i, existingParam := range existing.RequiredParams {
 			if existingParam.Param == param.Param {
@@ -134,7 +351,7 @@ func (s *PermissionService) mergePermissions(existing *models.CompiledPermission
 	}
 
 	for _, param := range newPerm.RestrictedParams {
-		processedParam := s.processPlaceholders(param, user)
+		processedParam := s.processPlaceholders(param, user, tenantID)
 		found := false
 		for i, existingParam := range existing.RestrictedParams {
 			if existingParam.Param == param.Param {
@@ -199,17 +416,17 @@ func (s *PermissionService) mergeParams(existing, new models.Params) models.Para
 	return result
 }
 
-func (s *PermissionService) processPlaceholders(param models.Params, user *models.User) models.Params {
+func (s *PermissionService) processPlaceholders(param models.Params, user *models.User, tenantID string) models.Params {
 	result := param
 
 	if param.Value != "" && strings.HasPrefix(param.Value, "$.") {
-		result.Value = s.resolvePlaceholder(param.Value, user)
+		result.Value = s.resolvePlaceholder(param.Value, user, tenantID)
 	}
 
 	if len(param.AnyValue) > 0 {
 		for i, value := range param.AnyValue {
 			if strings.HasPrefix(value, "$.") {
-				resolved := s.resolvePlaceholder(value, user)
+				resolved := s.resolvePlaceholder(value, user, tenantID)
 				if strings.Contains(resolved, ",") {
 					values := strings.Split(resolved, ",")
 					result.AnyValue = append(result.AnyValue[:i], append(values, result.AnyValue[i+1:]...)...)
@@ -223,7 +440,7 @@ func (s *PermissionService) processPlaceholders(param models.Params, user *model
 	if len(param.AllValues) > 0 {
 		for i, value := range param.AllValues {
 			if strings.HasPrefix(value, "$.") {
-				resolved := s.resolvePlaceholder(value, user)
+				resolved := s.resolvePlaceholder(value, user, tenantID)
 				if strings.Contains(resolved, ",") {
 					values := strings.Split(resolved, ",")
 					result.AllValues = append(result.AllValues[:i], append(values, result.AllValues[i+1:]...)...)
@@ -237,7 +454,7 @@ func (s *PermissionService) processPlaceholders(param models.Params, user *model
 	return result
 }
 
-func (s *PermissionService) resolvePlaceholder(placeholder string, user *models.User) string {
+func (s *PermissionService) resolvePlaceholder(placeholder string, user *models.User, tenantID string) string {
 	path := strings.TrimPrefix(placeholder, "$.")
 	parts := strings.Split(path, ".")
 
@@ -245,6 +462,10 @@ func (s *PermissionService) resolvePlaceholder(placeholder string, user *models.
 		return user.InternalID
 	}
 
+	if len(parts) == 1 && parts[0] == "tenant_id" {
+		return tenantID
+	}
+
 	if len(parts) >= 2 && parts[0] == "data" {
 		if user.Data == nil {
 			return ""
@@ -275,14 +496,34 @@ func (s *PermissionService) resolvePlaceholder(placeholder string, user *models.
 	return ""
 }
 
-func (s *PermissionService) CheckPermission(ctx *saiTypes.RequestCtx, permissions []models.CompiledPermission, microservice, method, path string, requestParams map[string]interface{}) (*models.VerifyResponse, error) {
+// SessionIndex returns the permission index of a session, reusing the one
+// built for the same version of its compiled permissions.
+func (s *PermissionService) SessionIndex(token *models.Token) *PermissionIndex {
+	return s.sessions.get(token)
+}
+
+// CheckPermission evaluates the request against the most specific matching
+// allow: exact paths win over templates, templates over wildcards and longer
+// prefixes over shorter ones. A matching deny refuses the request however
+// specific the allow is. Segments captured by a path template are checked
+// as "path.<name>" params and returned in PathParams.
+func (s *PermissionService) CheckPermission(ctx *saiTypes.RequestCtx, index *PermissionIndex, microservice, method, path string, requestParams map[string]interface{}) (*models.VerifyResponse, error) {
 	var matchedPermission *models.CompiledPermission
+	var pathParams map[string]string
+
+	for _, match := range index.Match(microservice, method, path) {
+		perm := match.Permission
+		if perm.Effect == models.EffectDeny {
+			return &models.VerifyResponse{
+				Allowed:    false,
+				Reason:     fmt.Sprintf("Access to %s %s %s is denied", microservice, method, path),
+				Permission: fmt.Sprintf("deny:%s:%s:%s", perm.Microservice, perm.Method, perm.Path),
+			}, nil
+		}
 
-	for i := range permissions {
-		perm := &permissions[i]
-		if perm.Microservice == microservice && perm.Method == method && s.matchPath(perm.Path, path) {
+		if matchedPermission == nil {
 			matchedPermission = perm
-			break
+			pathParams = match.PathParams
 		}
 	}
 
@@ -293,8 +534,13 @@ func (s *PermissionService) CheckPermission(ctx *saiTypes.RequestCtx, permission
 		}, nil
 	}
 
+	checkedParams := requestParams
+	if len(pathParams) > 0 {
+		checkedParams = withPathParams(requestParams, pathParams)
+	}
+
 	for _, restriction := range matchedPermission.RestrictedParams {
-		if value := s.getNestedValue(requestParams, restriction.Param); value != nil {
+		if value := s.getNestedValue(checkedParams, restriction.Param); value != nil {
 			if s.isRestricted(value, restriction) {
 				return &models.VerifyResponse{
 					Allowed: false,
@@ -315,7 +561,7 @@ func (s *PermissionService) CheckPermission(ctx *saiTypes.RequestCtx, permission
 	}
 
 	for _, requirement := range matchedPermission.RequiredParams {
-		value := s.getNestedValue(requestParams, requirement.Param)
+		value := s.getNestedValue(checkedParams, requirement.Param)
 		if value != nil {
 			if !s.satisfiesRequirement(value, requirement) {
 				return &models.VerifyResponse{
@@ -344,9 +590,29 @@ func (s *PermissionService) CheckPermission(ctx *saiTypes.RequestCtx, permission
 	return &models.VerifyResponse{
 		Allowed:        true,
 		ModifiedParams: modifiedParams,
+		Permission:     fmt.Sprintf("%s:%s:%s", matchedPermission.Microservice, matchedPermission.Method, matchedPermission.Path),
+		Rates:          matchedPermission.Rates,
+		PathParams:     pathParams,
 	}, nil
 }
 
+// withPathParams adds the captured path segments under "path" for checking
+// params; they take the place of a request param of that name.
+func withPathParams(requestParams map[string]interface{}, pathParams map[string]string) map[string]interface{} {
+	params := make(map[string]interface{}, len(requestParams)+1)
+	for key, value := range requestParams {
+		params[key] = value
+	}
+
+	captured := make(map[string]interface{}, len(pathParams))
+	for name, value := range pathParams {
+		captured[name] = value
+	}
+	params["path"] = captured
+
+	return params
+}
+
 func (s *PermissionService) getNestedValue(data map[string]interface{}, path string) interface{} {
 	if !strings.Contains(path, ".") {
 		return data[path]
@@ -510,23 +776,125 @@ func (s *PermissionService) isValueRestricted(value interface{}, restriction mod
 	return false
 }
 
-func (s *PermissionService) matchPath(permissionPath, requestPath string) bool {
-	// Exact match
-	if permissionPath == requestPath {
-		return true
+// CompactPermissions reduces compiled permissions to the entries embedded in
+// signed access tokens. Entries carrying params or rates are flagged so that
+// providers defer those decisions to the auth service.
+func (s *PermissionService) CompactPermissions(permissions []models.CompiledPermission) []jwt.Permission {
+	compact := make([]jwt.Permission, 0, len(permissions))
+	for _, permission := range permissions {
+		compact = append(compact, jwt.Permission{
+			Microservice: permission.Microservice,
+			Method:       permission.Method,
+			Path:         permission.Path,
+			Deny:         permission.Effect == models.EffectDeny,
+			Remote: permission.Effect == models.EffectDeny ||
+				len(permission.RequiredParams) > 0 ||
+				len(permission.RestrictedParams) > 0 ||
+				len(permission.Rates) > 0,
+		})
+	}
+
+	return compact
+}
+
+// CompileGrants collects the grantable roles of the user's roles, including
+// inherited ones, with scope placeholders resolved against the user.
+func (s *PermissionService) CompileGrants(ctx *saiTypes.RequestCtx, user *models.User, tenantID string) ([]models.GrantableRole, error) {
+	roleIDs, _, err := s.effectiveRoles(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	if len(roleIDs) == 0 {
+		return []models.GrantableRole{}, nil
 	}
 
-	// Wildcard match - /api/v1/* matches /api/v1/, /api/v1/documents, etc.
-	if strings.HasSuffix(permissionPath, "/*") {
-		prefix := strings.TrimSuffix(permissionPath, "/*")
-		return strings.HasPrefix(requestPath, prefix)
+	allRoles, err := s.collectAllRoles(ctx, roleIDs, tenantID, make(map[string]bool), 0)
+	if err != nil {
+		return nil, err
 	}
 
-	// Wildcard match - /api/v1* matches /api/v1, /api/v1/, /api/v1/documents, etc.
-	if strings.HasSuffix(permissionPath, "*") {
-		prefix := strings.TrimSuffix(permissionPath, "*")
-		return strings.HasPrefix(requestPath, prefix)
+	var grants []models.GrantableRole
+	for _, role := range allRoles {
+		for _, grant := range role.GrantableRoles {
+			compiled := models.GrantableRole{
+				RoleID: grant.RoleID,
+				Scope:  make([]models.Params, 0, len(grant.Scope)),
+			}
+			for _, param := range grant.Scope {
+				compiled.Scope = append(compiled.Scope, s.processPlaceholders(param, user, tenantID))
+			}
+			grants = append(grants, compiled)
+		}
+	}
+
+	return grants, nil
+}
+
+// CheckGrant explains whether grants allow assigning or removing roleID on a
+// user with targetData. Any matching grant is enough.
+func (s *PermissionService) CheckGrant(grants []models.GrantableRole, roleID string, targetData map[string]interface{}) models.RoleGrantResult {
+	result := models.RoleGrantResult{
+		RoleID: roleID,
+		Reason: fmt.Sprintf("Role %s is not grantable", roleID),
+	}
+
+	for _, grant := range grants {
+		if grant.RoleID != roleID && grant.RoleID != "*" {
+			continue
+		}
+
+		if param, ok := s.outOfScope(grant.Scope, targetData); ok {
+			result.Reason = fmt.Sprintf("Role %s is only grantable to users whose %s matches the grant scope", roleID, param)
+			continue
+		}
+
+		return models.RoleGrantResult{RoleID: roleID, Allowed: true}
 	}
 
+	return result
+}
+
+// UnrestrictedGrant reports whether grants allow any role on any user.
+func (s *PermissionService) UnrestrictedGrant(grants []models.GrantableRole) bool {
+	for _, grant := range grants {
+		if grant.RoleID == "*" && len(grant.Scope) == 0 {
+			return true
+		}
+	}
 	return false
 }
+
+// InGrantScope reports whether a user with targetData is within the scope of
+// grant.
+func (s *PermissionService) InGrantScope(grant models.GrantableRole, targetData map[string]interface{}) bool {
+	_, out := s.outOfScope(grant.Scope, targetData)
+	return !out
+}
+
+// GrantScopeParams lists the user data params the scopes of grants look at.
+func (s *PermissionService) GrantScopeParams(grants []models.GrantableRole) []string {
+	var params []string
+	for _, grant := range grants {
+		for _, param := range grant.Scope {
+			params = append(params, param.Param)
+		}
+	}
+	return params
+}
+
+// outOfScope returns the first scope param the target data does not satisfy.
+func (s *PermissionService) outOfScope(scope []models.Params, targetData map[string]interface{}) (string, bool) {
+	for _, param := range scope {
+		// A placeholder the granting user has no value for limits nothing,
+		// so it must not match everyone.
+		if param.Value == "" && len(param.AnyValue) == 0 && len(param.AllValues) == 0 {
+			return param.Param, true
+		}
+
+		value := s.getNestedValue(targetData, param.Param)
+		if value == nil || !s.satisfiesRequirement(value, param) {
+			return param.Param, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"time"
+
+	"github.com/saiset-co/sai-auth/internal/models"
+	"github.com/saiset-co/sai-auth/internal/repository"
+	"github.com/saiset-co/sai-auth/types"
+	saiTypes "github.com/saiset-co/sai-service/types"
+)
+
+const (
+	RegistrationDisabled = "disabled"
+	RegistrationOpen     = "open"
+	RegistrationInvite   = "invite"
+	RegistrationApproval = "approval"
+)
+
+type RegistrationService struct {
+	userRepo   repository.UserRepository
+	roleRepo   repository.RoleRepository
+	userSvc    *UserService
+	lockoutSvc *LockoutService
+	config     *types.SaiAuthConfig
+}
+
+func NewRegistrationService(
+	userRepo repository.UserRepository,
+	roleRepo repository.RoleRepository,
+	userSvc *UserService,
+	lockoutSvc *LockoutService,
+	config *types.SaiAuthConfig,
+) (*RegistrationService, error) {
+	registration := &config.Registration
+	registration.DefaultRoles = nonEmpty(registration.DefaultRoles)
+	registration.InviteCodes = nonEmpty(registration.InviteCodes)
+
+	switch registration.Mode {
+	case "":
+		registration.Mode = RegistrationDisabled
+	case RegistrationDisabled, RegistrationOpen, RegistrationApproval:
+	case RegistrationInvite:
+		if len(registration.InviteCodes) == 0 {
+			return nil, fmt.Errorf("registration mode invite requires invite_codes")
+		}
+	default:
+		return nil, fmt.Errorf("unknown registration mode %q", registration.Mode)
+	}
+
+	return &RegistrationService{
+		userRepo:   userRepo,
+		roleRepo:   roleRepo,
+		userSvc:    userSvc,
+		lockoutSvc: lockoutSvc,
+		config:     config,
+	}, nil
+}
+
+// Register creates an account from the public endpoint. Self-registered
+// users never become superusers and only get the configured default roles;
+// in approval mode they stay inactive until approved.
+func (s *RegistrationService) Register(ctx *saiTypes.RequestCtx, req *models.RegisterRequest) (*models.User, error) {
+	if s.config.Registration.Mode == RegistrationDisabled {
+		return nil, fmt.Errorf("registration is disabled")
+	}
+
+	if s.lockoutSvc != nil {
+		if err := s.lockoutSvc.CheckRegistration(ctx, req.IP); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.config.Registration.Mode == RegistrationInvite && !s.validInviteCode(req.InviteCode) {
+		if s.lockoutSvc != nil {
+			s.lockoutSvc.RecordFailure(ctx, nil, req.Username, req.IP, req.UserAgent)
+		}
+		return nil, fmt.Errorf("invalid invite code")
+	}
+
+	roleIDs, err := s.defaultRoleIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userSvc.newUser(ctx, &models.CreateUserRequest{
+		Username: req.Username,
+		Email:    req.Email,
+		Password: req.Password,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	user.Roles = roleIDs
+	if s.config.Registration.Mode == RegistrationApproval {
+		user.IsActive = false
+		user.RegistrationStatus = models.RegistrationPending
+	}
+
+	if err := s.userSvc.insert(ctx, user); err != nil {
+		return nil, err
+	}
+
+	if s.lockoutSvc != nil {
+		s.lockoutSvc.RecordRegistration(ctx, user, req.IP, req.UserAgent)
+	}
+
+	user.ClearSecrets()
+	return user, nil
+}
+
+func (s *RegistrationService) Approve(ctx *saiTypes.RequestCtx, userID string) error {
+	if _, err := s.pendingUser(ctx, userID); err != nil {
+		return err
+	}
+
+	err := s.userRepo.Update(ctx,
+		map[string]interface{}{"internal_id": userID},
+		map[string]interface{}{
+			"$set":   map[string]interface{}{"is_active": true, "ch_time": time.Now().UnixNano()},
+			"$unset": map[string]interface{}{"registration_status": ""},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to approve user: %w", err)
+	}
+
+	return nil
+}
+
+// Reject deletes a pending registration so the username and email can be
+// used again.
+func (s *RegistrationService) Reject(ctx *saiTypes.RequestCtx, userID string) error {
+	if _, err := s.pendingUser(ctx, userID); err != nil {
+		return err
+	}
+
+	return s.userSvc.Delete(ctx, map[string]interface{}{"internal_id": userID})
+}
+
+func (s *RegistrationService) pendingUser(ctx *saiTypes.RequestCtx, userID string) (*models.User, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	if user.RegistrationStatus != models.RegistrationPending {
+		return nil, fmt.Errorf("user is not pending approval")
+	}
+
+	return user, nil
+}
+
+func (s *RegistrationService) defaultRoleIDs(ctx *saiTypes.RequestCtx) ([]string, error) {
+	roleIDs := make([]string, 0, len(s.config.Registration.DefaultRoles))
+
+	for _, name := range s.config.Registration.DefaultRoles {
+		role, err := s.roleRepo.GetByName(ctx, "", name)
+		if err != nil {
+			return nil, fmt.Errorf("default role %q not found", name)
+		}
+		roleIDs = append(roleIDs, role.InternalID)
+	}
+
+	return roleIDs, nil
+}
+
+// nonEmpty drops blank entries left by unset environment variables in list
+// settings.
+func nonEmpty(values []string) []string {
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		if value != "" {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+func (s *RegistrationService) validInviteCode(code string) bool {
+	if code == "" {
+		return false
+	}
+
+	valid := false
+	for _, inviteCode := range s.config.Registration.InviteCodes {
+		if subtle.ConstantTimeCompare([]byte(code), []byte(inviteCode)) == 1 {
+			valid = true
+		}
+	}
+
+	return valid
+}
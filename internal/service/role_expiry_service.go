@@ -0,0 +1,126 @@
+package service
+
+import (
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"go.uber.org/zap"
+
+	"github.com/saiset-co/sai-auth/internal/models"
+	"github.com/saiset-co/sai-auth/internal/repository"
+	"github.com/saiset-co/sai-auth/types"
+	"github.com/saiset-co/sai-service/sai"
+	saiTypes "github.com/saiset-co/sai-service/types"
+)
+
+const roleExpiryBatchSize = 100
+
+// RoleExpiryService removes expired time-bound roles and recompiles the
+// permissions of users whose role windows opened or closed.
+type RoleExpiryService struct {
+	userRepo repository.UserRepository
+	userSvc  *UserService
+	config   *types.SaiAuthConfig
+}
+
+func NewRoleExpiryService(userRepo repository.UserRepository, userSvc *UserService, config *types.SaiAuthConfig) *RoleExpiryService {
+	if config.RoleExpiry.CheckInterval <= 0 {
+		config.RoleExpiry.CheckInterval = time.Minute
+	}
+
+	return &RoleExpiryService{
+		userRepo: userRepo,
+		userSvc:  userSvc,
+		config:   config,
+	}
+}
+
+// Start checks role windows every CheckInterval in the background.
+func (s *RoleExpiryService) Start() {
+	go func() {
+		ticker := time.NewTicker(s.config.RoleExpiry.CheckInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			ctx := &saiTypes.RequestCtx{RequestCtx: &fasthttp.RequestCtx{}}
+			if err := s.prune(ctx); err != nil {
+				sai.Logger().Warn("Role expiry check failed", zap.Error(err))
+			}
+		}
+	}()
+}
+
+func (s *RoleExpiryService) prune(ctx *saiTypes.RequestCtx) error {
+	now := time.Now().UnixNano()
+
+	users, _, err := s.userRepo.List(ctx, &types.UserFilterRequest{
+		PaginationRequest: types.PaginationRequest{Page: 1, Limit: roleExpiryBatchSize},
+		RoleWindowsDue:    now,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		roles, assignments, expired := dueAssignments(user, now)
+
+		err := s.userRepo.Update(ctx,
+			map[string]interface{}{"internal_id": user.InternalID},
+			map[string]interface{}{"$set": map[string]interface{}{
+				"roles":            roles,
+				"role_assignments": assignments,
+			}},
+		)
+		if err != nil {
+			sai.Logger().Error("Failed to update role assignments", zap.String("user_id", user.InternalID), zap.Error(err))
+			continue
+		}
+
+		if err := s.userSvc.recompileUserPermissions(ctx, map[string]interface{}{"internal_id": user.InternalID}); err != nil {
+			sai.Logger().Error("Failed to recompile permissions", zap.String("user_id", user.InternalID), zap.Error(err))
+			continue
+		}
+
+		if len(expired) > 0 {
+			sai.Logger().Info("Expired roles removed", zap.String("user_id", user.InternalID), zap.Strings("roles", expired))
+		}
+	}
+
+	return nil
+}
+
+// dueAssignments drops expired windows together with their roles and clears
+// not_before once it has passed, so the user is not selected again until the
+// next window boundary.
+func dueAssignments(user *models.User, now int64) ([]string, []models.RoleAssignment, []string) {
+	var expired []string
+	assignments := make([]models.RoleAssignment, 0, len(user.RoleAssignments))
+
+	for _, assignment := range user.RoleAssignments {
+		if assignment.ExpiresAt != 0 && assignment.ExpiresAt <= now {
+			expired = append(expired, assignment.RoleID)
+			continue
+		}
+		if assignment.NotBefore != 0 && assignment.NotBefore <= now {
+			assignment.NotBefore = 0
+		}
+		if assignment.NotBefore == 0 && assignment.ExpiresAt == 0 {
+			continue
+		}
+		assignments = append(assignments, assignment)
+	}
+
+	drop := make(map[string]bool, len(expired))
+	for _, roleID := range expired {
+		drop[roleID] = true
+	}
+
+	roles := make([]string, 0, len(user.Roles))
+	for _, roleID := range user.Roles {
+		if !drop[roleID] {
+			roles = append(roles, roleID)
+		}
+	}
+
+	return roles, assignments, expired
+}
@@ -16,6 +16,8 @@ type RoleService struct {
 	userRepo      repository.UserRepository
 	permissionSvc *PermissionService
 	userService   *UserService
+	groupSvc      *GroupService
+	tenantSvc     *TenantService
 }
 
 func NewRoleService(
@@ -32,29 +34,52 @@ func NewRoleService(
 	}
 }
 
+func (s *RoleService) SetGroupService(groupSvc *GroupService) {
+	s.groupSvc = groupSvc
+}
+
+func (s *RoleService) SetTenantService(tenantSvc *TenantService) {
+	s.tenantSvc = tenantSvc
+}
+
 func (s *RoleService) Create(ctx *saiTypes.RequestCtx, req *models.CreateRoleRequest) (*models.Role, error) {
-	_, err := s.roleRepo.GetByName(ctx, req.Name)
+	_, err := s.roleRepo.GetByName(ctx, req.TenantID, req.Name)
 	if err == nil {
 		return nil, fmt.Errorf("role name already exists")
 	}
 
-	if len(req.Permissions) > 50 {
-		return nil, fmt.Errorf("maximum 50 permissions per role exceeded")
+	if req.TenantID != "" {
+		if s.tenantSvc == nil {
+			return nil, fmt.Errorf("tenant not found")
+		}
+		if _, err := s.tenantSvc.GetByID(ctx, req.TenantID); err != nil {
+			return nil, fmt.Errorf("tenant not found")
+		}
+	}
+
+	if err := s.permissionSvc.ValidatePermissions(req.Permissions); err != nil {
+		return nil, err
 	}
 
 	role := &models.Role{
-		InternalID:  uuid.New().String(),
-		Name:        req.Name,
-		IsActive:    true,
-		ParentRoles: req.ParentRoles,
-		Permissions: req.Permissions,
-		Data:        req.Data,
+		InternalID:     uuid.New().String(),
+		TenantID:       req.TenantID,
+		Name:           req.Name,
+		IsActive:       true,
+		ParentRoles:    req.ParentRoles,
+		Permissions:    req.Permissions,
+		GrantableRoles: req.GrantableRoles,
+		Data:           req.Data,
 	}
 
 	if req.IsActive != nil {
 		role.IsActive = *req.IsActive
 	}
 
+	if role.GrantableRoles == nil {
+		role.GrantableRoles = []models.GrantableRole{}
+	}
+
 	if role.Data == nil {
 		role.Data = make(map[string]interface{})
 	}
@@ -63,6 +88,10 @@ func (s *RoleService) Create(ctx *saiTypes.RequestCtx, req *models.CreateRoleReq
 		return nil, err
 	}
 
+	if err := s.validateParentTenants(ctx, role.TenantID, req.ParentRoles); err != nil {
+		return nil, err
+	}
+
 	err = s.roleRepo.Create(ctx, role)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create role: %w", err)
@@ -75,8 +104,8 @@ func (s *RoleService) GetByID(ctx *saiTypes.RequestCtx, id string) (*models.Role
 	return s.roleRepo.GetByID(ctx, id)
 }
 
-func (s *RoleService) GetByName(ctx *saiTypes.RequestCtx, name string) (*models.Role, error) {
-	return s.roleRepo.GetByName(ctx, name)
+func (s *RoleService) GetByName(ctx *saiTypes.RequestCtx, tenantID, name string) (*models.Role, error) {
+	return s.roleRepo.GetByName(ctx, tenantID, name)
 }
 
 func (s *RoleService) List(ctx *saiTypes.RequestCtx, filter *types.RoleFilterRequest) ([]*models.Role, int64, error) {
@@ -106,9 +135,21 @@ func (s *RoleService) Update(ctx *saiTypes.RequestCtx, filter, data map[string]i
 		}
 	}
 
+	if _, exists := data["tenant_id"]; exists {
+		return fmt.Errorf("tenant_id cannot be changed")
+	}
+
 	if permissions, exists := data["permissions"]; exists {
-		if permSlice, ok := permissions.([]models.Permission); ok && len(permSlice) > 50 {
-			return fmt.Errorf("maximum 50 permissions per role exceeded")
+		var permSlice []models.Permission
+		raw, err := ctx.Marshal(permissions)
+		if err == nil {
+			err = ctx.Unmarshal(raw, &permSlice)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid permissions: %w", err)
+		}
+		if err := s.permissionSvc.ValidatePermissions(permSlice); err != nil {
+			return err
 		}
 	}
 
@@ -172,12 +213,25 @@ func (s *RoleService) Delete(ctx *saiTypes.RequestCtx, filter map[string]interfa
 
 				s.userRepo.Update(ctx,
 					map[string]interface{}{"internal_id": user.InternalID},
-					map[string]interface{}{"$set": map[string]interface{}{"roles": newRoles}},
+					map[string]interface{}{"$set": map[string]interface{}{
+						"roles":            newRoles,
+						"role_assignments": withoutAssignments(user.RoleAssignments, []string{roleID}),
+					}},
 				)
 
 				s.userService.recompileUserPermissions(ctx, map[string]interface{}{"internal_id": user.InternalID})
 			}
 		}
+
+		if s.groupSvc != nil {
+			members := s.groupSvc.RoleMembers(ctx, roleID)
+			if err := s.groupSvc.RemoveRole(ctx, roleID); err != nil {
+				return err
+			}
+			for _, userID := range members {
+				s.userService.recompileUserPermissions(ctx, map[string]interface{}{"internal_id": userID})
+			}
+		}
 	}
 
 	return s.roleRepo.Delete(ctx, filter)
@@ -194,13 +248,17 @@ func (s *RoleService) GetRolePermissions(ctx *saiTypes.RequestCtx, roleID string
 		return nil, err
 	}
 
+	if s.groupSvc != nil {
+		userIDs = uniqueIDs(append(userIDs, s.groupSvc.RoleMembers(ctx, roleID)...))
+	}
+
 	dummyUser := &models.User{
 		InternalID: "dummy",
 		Roles:      []string{roleID},
 		Data:       make(map[string]interface{}),
 	}
 
-	permissions, err := s.permissionSvc.CompilePermissions(ctx, dummyUser)
+	permissions, err := s.permissionSvc.CompilePermissions(ctx, dummyUser, role.TenantID)
 	if err != nil {
 		return nil, err
 	}
@@ -242,6 +300,23 @@ func (s *RoleService) validateRoleHierarchy(ctx *saiTypes.RequestCtx, roleID str
 	return nil
 }
 
+// validateParentTenants keeps tenant roles from inheriting another tenant's
+// roles and global roles from inheriting tenant roles.
+func (s *RoleService) validateParentTenants(ctx *saiTypes.RequestCtx, tenantID string, parentRoles []string) error {
+	for _, parentRoleID := range parentRoles {
+		parentRole, err := s.roleRepo.GetByID(ctx, parentRoleID)
+		if err != nil {
+			return fmt.Errorf("parent role %s not found", parentRoleID)
+		}
+
+		if parentRole.TenantID != "" && parentRole.TenantID != tenantID {
+			return fmt.Errorf("parent role %s belongs to another tenant", parentRoleID)
+		}
+	}
+
+	return nil
+}
+
 func (s *RoleService) recompileRolePermissions(ctx *saiTypes.RequestCtx, roleID string) {
 	users, _, err := s.userRepo.List(ctx, &types.UserFilterRequest{})
 	if err != nil {
@@ -261,6 +336,12 @@ func (s *RoleService) recompileRolePermissions(ctx *saiTypes.RequestCtx, roleID
 			s.userService.recompileUserPermissions(ctx, map[string]interface{}{"internal_id": user.InternalID})
 		}
 	}
+
+	if s.groupSvc != nil {
+		for _, userID := range s.groupSvc.RoleMembers(ctx, roleID) {
+			s.userService.recompileUserPermissions(ctx, map[string]interface{}{"internal_id": userID})
+		}
+	}
 }
 
 func (s *RoleService) matchesFilter(role *models.Role, filter map[string]interface{}) bool {
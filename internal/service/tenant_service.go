@@ -0,0 +1,207 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/saiset-co/sai-auth/internal/models"
+	"github.com/saiset-co/sai-auth/internal/repository"
+	"github.com/saiset-co/sai-auth/types"
+	saiTypes "github.com/saiset-co/sai-service/types"
+)
+
+type TenantService struct {
+	tenantRepo  repository.TenantRepository
+	userRepo    repository.UserRepository
+	userService *UserService
+}
+
+func NewTenantService(
+	tenantRepo repository.TenantRepository,
+	userRepo repository.UserRepository,
+	userService *UserService,
+) *TenantService {
+	return &TenantService{
+		tenantRepo:  tenantRepo,
+		userRepo:    userRepo,
+		userService: userService,
+	}
+}
+
+func (s *TenantService) Create(ctx *saiTypes.RequestCtx, req *models.CreateTenantRequest) (*models.Tenant, error) {
+	if _, err := s.tenantRepo.GetByName(ctx, req.Name); err == nil {
+		return nil, fmt.Errorf("tenant name already exists")
+	}
+
+	now := time.Now().UnixNano()
+	tenant := &models.Tenant{
+		InternalID: uuid.New().String(),
+		Name:       req.Name,
+		IsActive:   true,
+		Data:       req.Data,
+		CrTime:     now,
+		ChTime:     now,
+	}
+
+	if req.IsActive != nil {
+		tenant.IsActive = *req.IsActive
+	}
+
+	if tenant.Data == nil {
+		tenant.Data = make(map[string]interface{})
+	}
+
+	if err := s.tenantRepo.Create(ctx, tenant); err != nil {
+		return nil, fmt.Errorf("failed to create tenant: %w", err)
+	}
+
+	return tenant, nil
+}
+
+func (s *TenantService) GetByID(ctx *saiTypes.RequestCtx, id string) (*models.Tenant, error) {
+	return s.tenantRepo.GetByID(ctx, id)
+}
+
+func (s *TenantService) List(ctx *saiTypes.RequestCtx, filter *types.TenantFilterRequest) ([]*models.Tenant, int64, error) {
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.Limit < 1 {
+		filter.Limit = 20
+	}
+	if filter.Limit > 100 {
+		filter.Limit = 100
+	}
+
+	return s.tenantRepo.List(ctx, filter)
+}
+
+// Update changes a tenant. Sessions of a deactivated tenant stay stored but
+// are refused by /verify until it is activated again.
+func (s *TenantService) Update(ctx *saiTypes.RequestCtx, tenantID string, req *models.UpdateTenantRequest) error {
+	tenant, err := s.tenantRepo.GetByID(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+
+	data := map[string]interface{}{"ch_time": time.Now().UnixNano()}
+
+	if req.Name != nil && *req.Name != tenant.Name {
+		if *req.Name == "" {
+			return fmt.Errorf("tenant name is required")
+		}
+		if _, err := s.tenantRepo.GetByName(ctx, *req.Name); err == nil {
+			return fmt.Errorf("tenant name already exists")
+		}
+		data["name"] = *req.Name
+	}
+
+	if req.IsActive != nil {
+		data["is_active"] = *req.IsActive
+	}
+
+	if req.Data != nil {
+		data["data"] = req.Data
+	}
+
+	return s.tenantRepo.Update(ctx,
+		map[string]interface{}{"internal_id": tenantID},
+		map[string]interface{}{"$set": data},
+	)
+}
+
+func (s *TenantService) AddMembers(ctx *saiTypes.RequestCtx, tenantID string, userIDs []string) error {
+	return s.changeMembers(ctx, tenantID, userIDs, func(tenants []string) []string {
+		return uniqueIDs(append(tenants, tenantID))
+	})
+}
+
+// RemoveMembers takes users out of a tenant and ends their sessions in it.
+func (s *TenantService) RemoveMembers(ctx *saiTypes.RequestCtx, tenantID string, userIDs []string) error {
+	return s.changeMembers(ctx, tenantID, userIDs, func(tenants []string) []string {
+		return withoutIDs(tenants, []string{tenantID})
+	})
+}
+
+func (s *TenantService) changeMembers(ctx *saiTypes.RequestCtx, tenantID string, userIDs []string, change func([]string) []string) error {
+	if _, err := s.tenantRepo.GetByID(ctx, tenantID); err != nil {
+		return err
+	}
+
+	users := make([]*models.User, 0, len(userIDs))
+	for _, userID := range uniqueIDs(userIDs) {
+		user, err := s.userRepo.GetByID(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("user %s not found", userID)
+		}
+		users = append(users, user)
+	}
+
+	for _, user := range users {
+		err := s.userRepo.Update(ctx,
+			map[string]interface{}{"internal_id": user.InternalID},
+			map[string]interface{}{"$set": map[string]interface{}{"tenants": change(user.Tenants)}},
+		)
+		if err != nil {
+			return err
+		}
+
+		s.userService.recompileUserPermissions(ctx, map[string]interface{}{"internal_id": user.InternalID})
+	}
+
+	return nil
+}
+
+// SessionTenant picks the tenant a new session acts in: the requested one,
+// which the user must belong to, or the only tenant of a single-tenant user.
+// Superusers may act in any tenant. Without a tenant only global roles apply.
+func (s *TenantService) SessionTenant(ctx *saiTypes.RequestCtx, user *models.User, requested string) (string, error) {
+	if requested == "" {
+		if len(user.Tenants) != 1 {
+			return "", nil
+		}
+		requested = user.Tenants[0]
+	}
+
+	if !isTenantMember(user, requested) {
+		return "", fmt.Errorf("user is not a member of this tenant")
+	}
+
+	tenant, err := s.tenantRepo.GetByID(ctx, requested)
+	if err != nil {
+		return "", fmt.Errorf("tenant not found")
+	}
+
+	if !tenant.IsActive {
+		return "", fmt.Errorf("tenant is inactive")
+	}
+
+	return tenant.InternalID, nil
+}
+
+// TenantActive reports whether sessions in tenantID may still be used.
+func (s *TenantService) TenantActive(ctx *saiTypes.RequestCtx, tenantID string) bool {
+	if tenantID == "" {
+		return true
+	}
+
+	tenant, err := s.tenantRepo.GetByID(ctx, tenantID)
+	return err == nil && tenant.IsActive
+}
+
+// isTenantMember reports whether user may hold a session in tenantID.
+func isTenantMember(user *models.User, tenantID string) bool {
+	if tenantID == "" || user.IsSuperUser {
+		return true
+	}
+
+	for _, id := range user.Tenants {
+		if id == tenantID {
+			return true
+		}
+	}
+
+	return false
+}
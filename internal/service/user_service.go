@@ -1,20 +1,71 @@
 package service
 
 import (
+	"errors"
 	"fmt"
+	"strings"
+	"time"
+
 	"github.com/google/uuid"
 
 	"github.com/saiset-co/sai-auth/internal/models"
 	"github.com/saiset-co/sai-auth/internal/repository"
 	"github.com/saiset-co/sai-auth/types"
+	"github.com/saiset-co/sai-service/sai"
 	saiTypes "github.com/saiset-co/sai-service/types"
+	"go.uber.org/zap"
 )
 
+// protectedUserFields cannot be written through the generic update endpoint,
+// neither as a whole nor through a dotted path into them.
+var protectedUserFields = []string{
+	"is_super_user",
+	"IsSuperUser",
+	"mfa_secret",
+	"mfa_pending_secret",
+	"mfa_recovery_codes",
+	"mfa_last_step",
+	"password_hash",
+	"password_history",
+	"password_changed_at",
+	"email_verification_sent_at",
+	"registration_status",
+	"role_assignments",
+	"tenants",
+}
+
+// allowedUpdateOperators are the update operators the generic update
+// endpoint accepts; the field checks below only understand these two.
+var allowedUpdateOperators = map[string]bool{
+	"$set":   true,
+	"$unset": true,
+}
+
+// superUserScanLimit bounds the superusers read in one storage call.
+const superUserScanLimit = 1000
+
+// userScanPageSize is the page size used to read every user a filter selects.
+const userScanPageSize = 100
+
+// ErrLastSuperUser is returned when a change would leave no active superuser.
+var ErrLastSuperUser = errors.New("cannot remove the last superuser")
+
+// RoleGrantError is returned when the caller may not assign or remove a role.
+type RoleGrantError struct {
+	Result models.RoleGrantResult
+}
+
+func (e *RoleGrantError) Error() string {
+	return e.Result.Reason
+}
+
 type UserService struct {
 	userRepo      repository.UserRepository
 	tokenRepo     repository.TokenRepository
 	permissionSvc *PermissionService
 	authService   *AuthService
+	lockoutSvc    *LockoutService
+	verifySvc     *EmailVerificationService
 }
 
 func NewUserService(
@@ -33,7 +84,31 @@ func (s *UserService) SetAuthService(authService *AuthService) {
 	s.authService = authService
 }
 
+func (s *UserService) SetLockoutService(lockoutSvc *LockoutService) {
+	s.lockoutSvc = lockoutSvc
+}
+
+func (s *UserService) SetEmailVerificationService(verifySvc *EmailVerificationService) {
+	s.verifySvc = verifySvc
+}
+
 func (s *UserService) Create(ctx *saiTypes.RequestCtx, req *models.CreateUserRequest) (*models.User, error) {
+	user, err := s.newUser(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.insert(ctx, user); err != nil {
+		return nil, err
+	}
+
+	user.ClearSecrets()
+	return user, nil
+}
+
+// newUser checks that req can become an account and builds the user with a
+// hashed password. The user is not stored yet.
+func (s *UserService) newUser(ctx *saiTypes.RequestCtx, req *models.CreateUserRequest) (*models.User, error) {
 	_, err := s.userRepo.GetByUsername(ctx, req.Username)
 	if err == nil {
 		return nil, fmt.Errorf("username already exists")
@@ -44,25 +119,25 @@ func (s *UserService) Create(ctx *saiTypes.RequestCtx, req *models.CreateUserReq
 		return nil, fmt.Errorf("email already exists")
 	}
 
-	passwordHash, err := s.authService.HashPassword(req.Password)
-	if err != nil {
-		return nil, fmt.Errorf("failed to hash password: %w", err)
+	if err := s.authService.ValidatePassword(req.Password, &models.User{Username: req.Username}); err != nil {
+		return nil, err
 	}
 
-	userCount, err := s.userRepo.CountUsers(ctx)
+	passwordHash, err := s.authService.HashPassword(req.Password)
 	if err != nil {
-		return nil, fmt.Errorf("failed to count users: %w", err)
+		return nil, fmt.Errorf("failed to hash password: %w", err)
 	}
 
 	user := &models.User{
-		InternalID:   uuid.New().String(),
-		Username:     req.Username,
-		Email:        req.Email,
-		PasswordHash: passwordHash,
-		IsActive:     true,
-		IsSuperUser:  userCount == 0,
-		Roles:        []string{},
-		Data:         req.Data,
+		InternalID:        uuid.New().String(),
+		Username:          req.Username,
+		Email:             req.Email,
+		PasswordHash:      passwordHash,
+		PasswordChangedAt: time.Now().UnixNano(),
+		EmailVerified:     req.EmailVerified,
+		IsActive:          true,
+		Roles:             []string{},
+		Data:              req.Data,
 	}
 
 	if req.IsActive != nil {
@@ -73,13 +148,22 @@ func (s *UserService) Create(ctx *saiTypes.RequestCtx, req *models.CreateUserReq
 		user.Data = make(map[string]interface{})
 	}
 
-	err = s.userRepo.Create(ctx, user)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create user: %w", err)
+	return user, nil
+}
+
+// insert stores a user built by newUser and starts email verification.
+func (s *UserService) insert(ctx *saiTypes.RequestCtx, user *models.User) error {
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
 	}
 
-	user.PasswordHash = ""
-	return user, nil
+	if s.verifySvc != nil {
+		if err := s.verifySvc.Start(ctx, user); err != nil {
+			sai.Logger().Error("Failed to start email verification", zap.Error(err), zap.String("user_id", user.InternalID))
+		}
+	}
+
+	return nil
 }
 
 func (s *UserService) GetByID(ctx *saiTypes.RequestCtx, id string) (*models.User, error) {
@@ -88,7 +172,7 @@ func (s *UserService) GetByID(ctx *saiTypes.RequestCtx, id string) (*models.User
 		return nil, err
 	}
 
-	user.PasswordHash = ""
+	user.ClearSecrets()
 	return user, nil
 }
 
@@ -109,7 +193,7 @@ func (s *UserService) List(ctx *saiTypes.RequestCtx, filter *types.UserFilterReq
 	}
 
 	for _, user := range users {
-		user.PasswordHash = ""
+		user.ClearSecrets()
 		user.IsSuperUser = false
 	}
 
@@ -117,8 +201,13 @@ func (s *UserService) List(ctx *saiTypes.RequestCtx, filter *types.UserFilterReq
 }
 
 func (s *UserService) Update(ctx *saiTypes.RequestCtx, filter, data map[string]interface{}) error {
+	if err := s.guardSuperUsers(ctx, filter); err != nil {
+		return err
+	}
+
 	hasOperators := false
 	rolesUpdated := false
+	emailUpdated := false
 
 	for key := range data {
 		if len(key) > 0 && key[0] == '$' {
@@ -128,58 +217,85 @@ func (s *UserService) Update(ctx *saiTypes.RequestCtx, filter, data map[string]i
 	}
 
 	var updateData map[string]interface{}
+	var passwordUserID string
 
 	if hasOperators {
 		updateData = data
 
-		for _, opValue := range data {
-			if opMap, ok := opValue.(map[string]interface{}); ok {
-				if _, exists := opMap["is_super_user"]; exists {
-					delete(opMap, "is_super_user")
-				}
-				if _, exists := opMap["IsSuperUser"]; exists {
-					delete(opMap, "IsSuperUser")
-				}
+		for op, opValue := range data {
+			opMap, ok := opValue.(map[string]interface{})
+			if !ok || !allowedUpdateOperators[op] {
+				return fmt.Errorf("update operator %s is not allowed", op)
+			}
 
-				if _, exists := opMap["roles"]; exists {
+			for key := range opMap {
+				if isProtectedUserField(key) {
+					return fmt.Errorf("field %s cannot be updated", key)
+				}
+				if updatedField(key) == "roles" {
 					rolesUpdated = true
 				}
+			}
 
-				if password, exists := opMap["password"]; exists {
-					if passwordStr, ok := password.(string); ok {
-						hashedPassword, err := s.authService.HashPassword(passwordStr)
-						if err != nil {
-							return fmt.Errorf("failed to hash password: %w", err)
-						}
-						opMap["password_hash"] = hashedPassword
-						delete(opMap, "password")
-					}
+			if _, exists := opMap["email"]; exists && op == "$set" {
+				emailUpdated = true
+				resetEmailVerified(opMap)
+			}
+
+			if password, exists := opMap["password"]; exists {
+				userID, err := s.applyPassword(ctx, filter, opMap, password)
+				if err != nil {
+					return err
 				}
+				passwordUserID = userID
 			}
 		}
 	} else {
+		for key := range data {
+			if isProtectedUserField(key) {
+				return fmt.Errorf("field %s cannot be updated", key)
+			}
+			if updatedField(key) == "roles" {
+				rolesUpdated = true
+			}
+		}
+
 		if password, exists := data["password"]; exists {
-			if passwordStr, ok := password.(string); ok {
-				hashedPassword, err := s.authService.HashPassword(passwordStr)
-				if err != nil {
-					return fmt.Errorf("failed to hash password: %w", err)
-				}
-				data["password_hash"] = hashedPassword
-				delete(data, "password")
+			userID, err := s.applyPassword(ctx, filter, data, password)
+			if err != nil {
+				return err
 			}
+			passwordUserID = userID
+		}
+
+		if _, exists := data["email"]; exists {
+			emailUpdated = true
+			resetEmailVerified(data)
 		}
 
 		updateData = map[string]interface{}{"$set": data}
+	}
 
-		if _, exists := data["roles"]; exists {
-			rolesUpdated = true
+	if rolesUpdated {
+		if err := s.checkRoleUpdate(ctx); err != nil {
+			return err
 		}
+	}
 
-		if _, exists := data["is_super_user"]; exists {
-			delete(data, "is_super_user")
-		}
-		if _, exists := data["IsSuperUser"]; exists {
-			delete(data, "IsSuperUser")
+	if err := s.checkScopeDataUpdate(ctx, filter, updateData); err != nil {
+		return err
+	}
+
+	var emailUserIDs []string
+	if emailUpdated {
+		emailUserIDs = s.matchingUserIDs(ctx, filter)
+	}
+
+	var deactivated []*models.User
+	if deactivates(updateData) {
+		var err error
+		if deactivated, err = s.matchingActiveSuperUsers(ctx, filter); err != nil {
+			return err
 		}
 	}
 
@@ -188,6 +304,20 @@ func (s *UserService) Update(ctx *saiTypes.RequestCtx, filter, data map[string]i
 		return err
 	}
 
+	if len(deactivated) > 0 {
+		if err := s.ensureSuperUserRemains(ctx, deactivated, "is_active"); err != nil {
+			return err
+		}
+	}
+
+	if passwordUserID != "" {
+		s.tokenRepo.DeleteByUserID(ctx, passwordUserID)
+	}
+
+	for _, userID := range emailUserIDs {
+		s.restartEmailVerification(ctx, userID)
+	}
+
 	if rolesUpdated {
 		return s.recompileUserPermissions(ctx, filter)
 	}
@@ -195,27 +325,177 @@ func (s *UserService) Update(ctx *saiTypes.RequestCtx, filter, data map[string]i
 	return nil
 }
 
+// applyPassword replaces a plaintext password in fields with the hashed
+// password fields. Policy and history are per user, so the filter has to
+// select a single user by internal_id.
+func (s *UserService) applyPassword(ctx *saiTypes.RequestCtx, filter, fields map[string]interface{}, password interface{}) (string, error) {
+	passwordStr, ok := password.(string)
+	if !ok {
+		return "", fmt.Errorf("password must be a string")
+	}
+
+	userID, ok := filter["internal_id"].(string)
+	if !ok || userID == "" {
+		return "", fmt.Errorf("password can only be changed for a single user selected by internal_id")
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return "", fmt.Errorf("user not found")
+	}
+
+	passwordFields, err := s.authService.PasswordChange(user, passwordStr)
+	if err != nil {
+		return "", err
+	}
+
+	delete(fields, "password")
+	for key, value := range passwordFields {
+		fields[key] = value
+	}
+
+	return userID, nil
+}
+
+// updatedField returns the top-level field an update key writes, so that
+// "roles.0" counts as a write to roles.
+func updatedField(key string) string {
+	field, _, _ := strings.Cut(key, ".")
+	return field
+}
+
+// isProtectedUserField reports whether key writes one of the
+// protectedUserFields or a path inside one.
+func isProtectedUserField(key string) bool {
+	field := updatedField(key)
+	for _, protected := range protectedUserFields {
+		if field == protected {
+			return true
+		}
+	}
+	return false
+}
+
+// resetEmailVerified marks a changed email as unverified unless the update
+// sets the flag explicitly.
+func resetEmailVerified(fields map[string]interface{}) {
+	if _, exists := fields["email_verified"]; !exists {
+		fields["email_verified"] = false
+	}
+}
+
+func (s *UserService) matchingUserIDs(ctx *saiTypes.RequestCtx, filter map[string]interface{}) []string {
+	users, err := s.matchingUsers(ctx, filter)
+	if err != nil {
+		return nil
+	}
+
+	ids := make([]string, 0, len(users))
+	for _, user := range users {
+		ids = append(ids, user.InternalID)
+	}
+
+	return ids
+}
+
+// matchingUsers returns every user an update or delete filter selects. The
+// filter is applied by storage and the result is read page by page, so it
+// is not limited to the default page of List.
+func (s *UserService) matchingUsers(ctx *saiTypes.RequestCtx, filter map[string]interface{}) ([]*models.User, error) {
+	var matched []*models.User
+	for page := 1; ; page++ {
+		users, total, err := s.userRepo.List(ctx, &types.UserFilterRequest{
+			PaginationRequest: types.PaginationRequest{Page: page, Limit: userScanPageSize},
+			Match:             filter,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		matched = append(matched, users...)
+		if len(users) < userScanPageSize || int64(len(matched)) >= total {
+			return matched, nil
+		}
+	}
+}
+
+// restartEmailVerification mails a verification token for a changed email
+// and applies the verification mode to the user's existing sessions.
+func (s *UserService) restartEmailVerification(ctx *saiTypes.RequestCtx, userID string) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return
+	}
+
+	s.authService.enforceEmailVerification(ctx, user)
+
+	if s.verifySvc != nil {
+		if err := s.verifySvc.Start(ctx, user); err != nil {
+			sai.Logger().Error("Failed to start email verification", zap.Error(err), zap.String("user_id", user.InternalID))
+		}
+	}
+}
+
 func (s *UserService) Delete(ctx *saiTypes.RequestCtx, filter map[string]interface{}) error {
-	users, _, err := s.userRepo.List(ctx, &types.UserFilterRequest{})
+	if err := s.guardSuperUsers(ctx, filter); err != nil {
+		return err
+	}
+
+	// Superusers are demoted before the delete so that concurrent removals
+	// cannot both pass the last-superuser check.
+	demoted, err := s.matchingActiveSuperUsers(ctx, filter)
 	if err != nil {
 		return err
 	}
+	if len(demoted) > 0 {
+		if err := s.removeSuperUsers(ctx, demoted, "is_super_user"); err != nil {
+			return err
+		}
+	}
+
+	users, err := s.matchingUsers(ctx, filter)
+	if err != nil {
+		s.restoreSuperUsers(ctx, demoted, "is_super_user")
+		return err
+	}
 
 	for _, user := range users {
-		if s.matchesFilter(user, filter) {
-			s.tokenRepo.DeleteByUserID(ctx, user.InternalID)
+		s.tokenRepo.DeleteByUserID(ctx, user.InternalID)
+	}
+
+	err = s.userRepo.Delete(ctx, filter)
+
+	// Superusers the filter did not actually delete get their status back.
+	var survivors []*models.User
+	for _, user := range demoted {
+		if _, getErr := s.userRepo.GetByID(ctx, user.InternalID); getErr == nil {
+			survivors = append(survivors, user)
 		}
 	}
+	s.restoreSuperUsers(ctx, survivors, "is_super_user")
 
-	return s.userRepo.Delete(ctx, filter)
+	return err
 }
 
-func (s *UserService) AssignRoles(ctx *saiTypes.RequestCtx, userID string, roleIDs []string) error {
+func (s *UserService) AssignRoles(ctx *saiTypes.RequestCtx, userID string, req *models.AssignRolesRequest) error {
+	roleIDs := req.RoleIDs
+
+	if req.ExpiresAt != 0 && req.ExpiresAt <= time.Now().UnixNano() {
+		return fmt.Errorf("expires_at must be in the future")
+	}
+	if req.NotBefore != 0 && req.ExpiresAt != 0 && req.NotBefore >= req.ExpiresAt {
+		return fmt.Errorf("not_before must be before expires_at")
+	}
+
 	user, err := s.userRepo.GetByID(ctx, userID)
 	if err != nil {
 		return err
 	}
 
+	if err := s.checkGrants(ctx, roleIDs, user.Data); err != nil {
+		return err
+	}
+
 	roleMap := make(map[string]bool)
 	for _, roleID := range user.Roles {
 		roleMap[roleID] = true
@@ -234,9 +514,20 @@ func (s *UserService) AssignRoles(ctx *saiTypes.RequestCtx, userID string, roleI
 		return fmt.Errorf("maximum 10 roles per user exceeded")
 	}
 
+	assignments := withoutAssignments(user.RoleAssignments, roleIDs)
+	if req.NotBefore != 0 || req.ExpiresAt != 0 {
+		for _, roleID := range roleIDs {
+			assignments = append(assignments, models.RoleAssignment{
+				RoleID:    roleID,
+				NotBefore: req.NotBefore,
+				ExpiresAt: req.ExpiresAt,
+			})
+		}
+	}
+
 	err = s.userRepo.Update(ctx,
 		map[string]interface{}{"internal_id": userID},
-		map[string]interface{}{"$set": map[string]interface{}{"roles": newRoles}},
+		map[string]interface{}{"$set": map[string]interface{}{"roles": newRoles, "role_assignments": assignments}},
 	)
 	if err != nil {
 		return err
@@ -251,6 +542,10 @@ func (s *UserService) RemoveRoles(ctx *saiTypes.RequestCtx, userID string, roleI
 		return err
 	}
 
+	if err := s.checkGrants(ctx, roleIDs, user.Data); err != nil {
+		return err
+	}
+
 	removeMap := make(map[string]bool)
 	for _, roleID := range roleIDs {
 		removeMap[roleID] = true
@@ -265,7 +560,10 @@ func (s *UserService) RemoveRoles(ctx *saiTypes.RequestCtx, userID string, roleI
 
 	err = s.userRepo.Update(ctx,
 		map[string]interface{}{"internal_id": userID},
-		map[string]interface{}{"$set": map[string]interface{}{"roles": newRoles}},
+		map[string]interface{}{"$set": map[string]interface{}{
+			"roles":            newRoles,
+			"role_assignments": withoutAssignments(user.RoleAssignments, roleIDs),
+		}},
 	)
 	if err != nil {
 		return err
@@ -274,53 +572,490 @@ func (s *UserService) RemoveRoles(ctx *saiTypes.RequestCtx, userID string, roleI
 	return s.recompileUserPermissions(ctx, map[string]interface{}{"internal_id": userID})
 }
 
-func (s *UserService) recompileUserPermissions(ctx *saiTypes.RequestCtx, filter map[string]interface{}) error {
-	users, _, err := s.userRepo.List(ctx, &types.UserFilterRequest{})
-	if err != nil {
+// withoutAssignments drops the time windows of roleIDs.
+func withoutAssignments(assignments []models.RoleAssignment, roleIDs []string) []models.RoleAssignment {
+	drop := make(map[string]bool, len(roleIDs))
+	for _, roleID := range roleIDs {
+		drop[roleID] = true
+	}
+
+	result := make([]models.RoleAssignment, 0, len(assignments))
+	for _, assignment := range assignments {
+		if !drop[assignment.RoleID] {
+			result = append(result, assignment)
+		}
+	}
+
+	return result
+}
+
+// checkGrants rejects roles the authenticated caller may not assign to or
+// remove from a user with targetData.
+func (s *UserService) checkGrants(ctx *saiTypes.RequestCtx, roleIDs []string, targetData map[string]interface{}) error {
+	grants, restricted, err := s.callerGrants(ctx)
+	if err != nil || !restricted {
 		return err
 	}
 
-	for _, user := range users {
-		if s.matchesFilter(user, filter) {
-			token, err := s.tokenRepo.GetByUserID(ctx, user.InternalID)
-			if err != nil {
-				continue
-			}
+	for _, roleID := range roleIDs {
+		if result := s.permissionSvc.CheckGrant(grants, roleID, targetData); !result.Allowed {
+			return &RoleGrantError{Result: result}
+		}
+	}
 
-			permissions, err := s.permissionSvc.CompilePermissions(ctx, user)
-			if err != nil {
-				continue
-			}
+	return nil
+}
+
+// checkRoleUpdate only lets callers who may grant any role on anyone change
+// roles through the generic update, which cannot be checked per role.
+func (s *UserService) checkRoleUpdate(ctx *saiTypes.RequestCtx) error {
+	grants, restricted, err := s.callerGrants(ctx)
+	if err != nil || !restricted || s.permissionSvc.UnrestrictedGrant(grants) {
+		return err
+	}
+
+	return &RoleGrantError{Result: models.RoleGrantResult{
+		RoleID: "*",
+		Reason: "Roles can only be changed with assign-roles and remove-roles",
+	}}
+}
+
+// checkScopeDataUpdate keeps callers with scoped grants from moving users
+// into or out of their scope by rewriting the data their grant scopes look
+// at: the user has to be in scope both before and after the change, and no
+// scoped grant may start to apply to them.
+func (s *UserService) checkScopeDataUpdate(ctx *saiTypes.RequestCtx, filter, updateData map[string]interface{}) error {
+	paths := updatedDataPaths(updateData)
+	if len(paths) == 0 {
+		return nil
+	}
+
+	grants, restricted, err := s.callerGrants(ctx)
+	if err != nil || !restricted || s.permissionSvc.UnrestrictedGrant(grants) {
+		return err
+	}
+
+	if !touchesScope(paths, s.permissionSvc.GrantScopeParams(grants)) {
+		return nil
+	}
+
+	denied := &RoleGrantError{Result: models.RoleGrantResult{
+		RoleID: "*",
+		Reason: "User data used by grant scopes can only be changed within the grant scope",
+	}}
+
+	userID, ok := filter["internal_id"].(string)
+	if !ok || userID == "" {
+		return &RoleGrantError{Result: models.RoleGrantResult{
+			RoleID: "*",
+			Reason: "User data used by grant scopes can only be changed for a single user selected by internal_id",
+		}}
+	}
 
-			token.CompiledPermissions = permissions
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("user not found")
+	}
 
-			s.tokenRepo.Update(ctx, token)
+	updated, ok := applyDataUpdate(user.Data, updateData)
+	if !ok {
+		return denied
+	}
+
+	inBefore, inAfter := false, false
+	for _, grant := range grants {
+		if len(grant.Scope) == 0 {
+			continue
 		}
+
+		before := s.permissionSvc.InGrantScope(grant, user.Data)
+		after := s.permissionSvc.InGrantScope(grant, updated)
+		if after && !before {
+			return denied
+		}
+
+		inBefore = inBefore || before
+		inAfter = inAfter || after
+	}
+
+	if !inBefore || !inAfter {
+		return denied
 	}
 
 	return nil
 }
 
-func (s *UserService) matchesFilter(user *models.User, filter map[string]interface{}) bool {
-	for key, value := range filter {
-		switch key {
-		case "internal_id":
-			if user.InternalID != value {
-				return false
+// updatedDataPaths returns the paths inside data an update writes, "" for
+// data as a whole.
+func updatedDataPaths(updateData map[string]interface{}) []string {
+	var paths []string
+	for _, opValue := range updateData {
+		opMap, ok := opValue.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for key := range opMap {
+			if key == "data" {
+				paths = append(paths, "")
+			} else if path, ok := strings.CutPrefix(key, "data."); ok {
+				paths = append(paths, path)
 			}
-		case "username":
-			if user.Username != value {
-				return false
+		}
+	}
+	return paths
+}
+
+// touchesScope reports whether any written path overlaps a scope param.
+func touchesScope(paths, params []string) bool {
+	for _, path := range paths {
+		for _, param := range params {
+			if path == "" || path == param ||
+				strings.HasPrefix(param, path+".") || strings.HasPrefix(path, param+".") {
+				return true
 			}
-		case "email":
-			if user.Email != value {
-				return false
+		}
+	}
+	return false
+}
+
+// applyDataUpdate returns data as it will be after the $set and $unset of
+// updateData. Other operators on data cannot be evaluated and report false.
+func applyDataUpdate(data, updateData map[string]interface{}) (map[string]interface{}, bool) {
+	result := copyData(data)
+
+	for op, opValue := range updateData {
+		opMap, ok := opValue.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		for key, value := range opMap {
+			path, nested := strings.CutPrefix(key, "data.")
+			if key != "data" && !nested {
+				continue
 			}
-		case "is_active":
-			if user.IsActive != value {
-				return false
+
+			switch {
+			case op == "$set" && !nested:
+				replaced, ok := value.(map[string]interface{})
+				if !ok {
+					return nil, false
+				}
+				result = copyData(replaced)
+			case op == "$unset" && !nested:
+				result = map[string]interface{}{}
+			case op == "$set":
+				setDataPath(result, path, value)
+			case op == "$unset":
+				unsetDataPath(result, path)
+			default:
+				return nil, false
 			}
 		}
 	}
-	return true
+
+	return result, true
+}
+
+func copyData(data map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		if nested, ok := value.(map[string]interface{}); ok {
+			value = copyData(nested)
+		}
+		result[key] = value
+	}
+	return result
+}
+
+func setDataPath(data map[string]interface{}, path string, value interface{}) {
+	parts := strings.Split(path, ".")
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := data[part].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			data[part] = next
+		}
+		data = next
+	}
+	data[parts[len(parts)-1]] = value
+}
+
+func unsetDataPath(data map[string]interface{}, path string) {
+	parts := strings.Split(path, ".")
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := data[part].(map[string]interface{})
+		if !ok {
+			return
+		}
+		data = next
+	}
+	delete(data, parts[len(parts)-1])
+}
+
+// callerGrants returns the grants of the authenticated caller. Internal
+// calls without a caller and superusers are not restricted.
+func (s *UserService) callerGrants(ctx *saiTypes.RequestCtx) ([]models.GrantableRole, bool, error) {
+	callerID, _ := ctx.UserValue("user_id").(string)
+	if callerID == "" {
+		return nil, false, nil
+	}
+
+	caller, err := s.userRepo.GetByID(ctx, callerID)
+	if err != nil {
+		return nil, true, nil
+	}
+
+	if caller.IsSuperUser {
+		return nil, false, nil
+	}
+
+	tenantID, _ := ctx.UserValue("tenant_id").(string)
+	grants, err := s.permissionSvc.CompileGrants(ctx, caller, tenantID)
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to compile grants: %w", err)
+	}
+
+	return grants, true, nil
+}
+
+func (s *UserService) Unlock(ctx *saiTypes.RequestCtx, userID string) error {
+	return s.lockoutSvc.Unlock(ctx, userID)
+}
+
+func (s *UserService) LoginFailures(ctx *saiTypes.RequestCtx, filter *types.SecurityEventFilterRequest) ([]*models.SecurityEvent, int64, error) {
+	return s.lockoutSvc.LoginFailures(ctx, filter)
+}
+
+func (s *UserService) ListSuperUsers(ctx *saiTypes.RequestCtx, filter *types.UserFilterRequest) ([]*models.User, int64, error) {
+	if err := s.requireSuperUser(ctx); err != nil {
+		return nil, 0, err
+	}
+
+	superUser := true
+	filter.SuperUser = &superUser
+
+	users, total, err := s.List(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for _, user := range users {
+		user.IsSuperUser = true
+	}
+
+	return users, total, nil
+}
+
+func (s *UserService) PromoteSuperUser(ctx *saiTypes.RequestCtx, userID string) error {
+	return s.setSuperUser(ctx, userID, true)
+}
+
+// DemoteSuperUser revokes superuser status. The last superuser cannot be
+// demoted, otherwise nobody could promote anyone again.
+func (s *UserService) DemoteSuperUser(ctx *saiTypes.RequestCtx, userID string) error {
+	return s.setSuperUser(ctx, userID, false)
+}
+
+func (s *UserService) setSuperUser(ctx *saiTypes.RequestCtx, userID string, superUser bool) error {
+	if err := s.requireSuperUser(ctx); err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("user not found")
+	}
+
+	if user.IsSuperUser == superUser {
+		if superUser {
+			return fmt.Errorf("user is already a superuser")
+		}
+		return fmt.Errorf("user is not a superuser")
+	}
+
+	if !superUser {
+		if err := s.removeSuperUsers(ctx, []*models.User{user}, "is_super_user"); err != nil {
+			if errors.Is(err, ErrLastSuperUser) {
+				return fmt.Errorf("cannot demote the last superuser")
+			}
+			return err
+		}
+	} else {
+		err = s.userRepo.Update(ctx,
+			map[string]interface{}{"internal_id": userID, "is_super_user": false},
+			map[string]interface{}{"$set": map[string]interface{}{"is_super_user": true, "ch_time": time.Now().UnixNano()}},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to update user: %w", err)
+		}
+	}
+
+	eventType := models.SecurityEventSuperUserDemoted
+	if superUser {
+		eventType = models.SecurityEventSuperUserPromoted
+	}
+
+	callerID, _ := ctx.UserValue("user_id").(string)
+
+	sai.Logger().Info("Superuser status changed",
+		zap.String("user_id", userID),
+		zap.String("by", callerID),
+		zap.Bool("is_super_user", superUser))
+
+	s.authService.recordSecurityEvent(ctx, &models.SecurityEvent{
+		Type:    eventType,
+		UserID:  userID,
+		Details: map[string]interface{}{"by": callerID},
+	})
+
+	return nil
+}
+
+// guardSuperUsers keeps callers who are not superusers from changing or
+// deleting superuser accounts, e.g. setting their password or email.
+// Internal calls without a caller are not restricted.
+func (s *UserService) guardSuperUsers(ctx *saiTypes.RequestCtx, filter map[string]interface{}) error {
+	callerID, _ := ctx.UserValue("user_id").(string)
+	if callerID == "" {
+		return nil
+	}
+
+	if caller, err := s.userRepo.GetByID(ctx, callerID); err == nil && caller.IsSuperUser {
+		return nil
+	}
+
+	superUsers, err := s.superUsers(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	if len(superUsers) > 0 {
+		return fmt.Errorf("superuser accounts can only be changed by a superuser")
+	}
+
+	return nil
+}
+
+// matchingActiveSuperUsers returns the active superusers the filter selects.
+func (s *UserService) matchingActiveSuperUsers(ctx *saiTypes.RequestCtx, filter map[string]interface{}) ([]*models.User, error) {
+	superUsers, err := s.superUsers(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []*models.User
+	for _, user := range superUsers {
+		if user.IsActive {
+			matched = append(matched, user)
+		}
+	}
+
+	return matched, nil
+}
+
+// removeSuperUsers clears field ("is_super_user" or "is_active") on the
+// given active superusers and keeps the change only if an active superuser
+// remains afterwards.
+func (s *UserService) removeSuperUsers(ctx *saiTypes.RequestCtx, users []*models.User, field string) error {
+	for _, user := range users {
+		err := s.userRepo.Update(ctx,
+			map[string]interface{}{"internal_id": user.InternalID, field: true},
+			map[string]interface{}{"$set": map[string]interface{}{field: false, "ch_time": time.Now().UnixNano()}},
+		)
+		if err != nil {
+			s.restoreSuperUsers(ctx, users, field)
+			return fmt.Errorf("failed to update user: %w", err)
+		}
+	}
+
+	return s.ensureSuperUserRemains(ctx, users, field)
+}
+
+// ensureSuperUserRemains runs after users lost field. The check follows the
+// write, so concurrent removals that each passed a count beforehand cannot
+// leave the system without a superuser; the change is rolled back instead.
+func (s *UserService) ensureSuperUserRemains(ctx *saiTypes.RequestCtx, users []*models.User, field string) error {
+	superUsers, err := s.superUsers(ctx, nil)
+	if err != nil {
+		s.restoreSuperUsers(ctx, users, field)
+		return err
+	}
+
+	for _, user := range superUsers {
+		if user.IsActive {
+			return nil
+		}
+	}
+
+	s.restoreSuperUsers(ctx, users, field)
+
+	return ErrLastSuperUser
+}
+
+func (s *UserService) restoreSuperUsers(ctx *saiTypes.RequestCtx, users []*models.User, field string) {
+	for _, user := range users {
+		err := s.userRepo.Update(ctx,
+			map[string]interface{}{"internal_id": user.InternalID},
+			map[string]interface{}{"$set": map[string]interface{}{field: true, "ch_time": time.Now().UnixNano()}},
+		)
+		if err != nil {
+			sai.Logger().Error("Failed to restore superuser", zap.Error(err), zap.String("user_id", user.InternalID))
+		}
+	}
+}
+
+// deactivates reports whether an update sets is_active to false or unsets it.
+func deactivates(updateData map[string]interface{}) bool {
+	set, _ := updateData["$set"].(map[string]interface{})
+	active, exists := set["is_active"]
+	unset, _ := updateData["$unset"].(map[string]interface{})
+	_, unsets := unset["is_active"]
+	return exists && active != true || unsets
+}
+
+// superUsers returns the superuser accounts, active or not, that match
+// filter. The filter is applied by storage, so operator filters select the
+// same accounts the update or delete they guard will; a nil filter returns
+// every superuser.
+func (s *UserService) superUsers(ctx *saiTypes.RequestCtx, filter map[string]interface{}) ([]*models.User, error) {
+	isSuperUser := true
+	users, _, err := s.userRepo.List(ctx, &types.UserFilterRequest{
+		PaginationRequest: types.PaginationRequest{Limit: superUserScanLimit},
+		SuperUser:         &isSuperUser,
+		Match:             filter,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load superusers: %w", err)
+	}
+
+	return users, nil
+}
+
+// requireSuperUser checks that the authenticated caller is a superuser.
+func (s *UserService) requireSuperUser(ctx *saiTypes.RequestCtx) error {
+	callerID, _ := ctx.UserValue("user_id").(string)
+	if callerID == "" {
+		return fmt.Errorf("superuser access required")
+	}
+
+	caller, err := s.userRepo.GetByID(ctx, callerID)
+	if err != nil || !caller.IsSuperUser || !caller.IsActive {
+		return fmt.Errorf("superuser access required")
+	}
+
+	return nil
+}
+
+func (s *UserService) recompileUserPermissions(ctx *saiTypes.RequestCtx, filter map[string]interface{}) error {
+	users, err := s.matchingUsers(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		s.authService.refreshSessionPermissions(ctx, user)
+	}
+
+	return nil
 }
@@ -0,0 +1,252 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/saiset-co/sai-auth/internal/models"
+	"github.com/saiset-co/sai-auth/types"
+	saiTypes "github.com/saiset-co/sai-service/types"
+)
+
+// fakeUserRepo stands in for the storage service. List returns whatever
+// resolve makes of the request, so tests decide which users a storage
+// filter selects, and total when it is set.
+type fakeUserRepo struct {
+	users   map[string]*models.User
+	resolve func(filter *types.UserFilterRequest) []*models.User
+	total   int64
+	listed  []*types.UserFilterRequest
+}
+
+func (r *fakeUserRepo) Create(ctx *saiTypes.RequestCtx, user *models.User) error {
+	r.users[user.InternalID] = user
+	return nil
+}
+
+func (r *fakeUserRepo) GetByID(ctx *saiTypes.RequestCtx, id string) (*models.User, error) {
+	if user, ok := r.users[id]; ok {
+		return user, nil
+	}
+	return nil, fmt.Errorf("user not found")
+}
+
+func (r *fakeUserRepo) GetByUsername(ctx *saiTypes.RequestCtx, username string) (*models.User, error) {
+	return nil, fmt.Errorf("user not found")
+}
+
+func (r *fakeUserRepo) GetByEmail(ctx *saiTypes.RequestCtx, email string) (*models.User, error) {
+	return nil, fmt.Errorf("user not found")
+}
+
+// Update applies $set to the user selected by a plain internal_id filter.
+func (r *fakeUserRepo) Update(ctx *saiTypes.RequestCtx, filter, data map[string]interface{}) error {
+	id, _ := filter["internal_id"].(string)
+	user, ok := r.users[id]
+	if !ok {
+		return nil
+	}
+
+	set, _ := data["$set"].(map[string]interface{})
+	if value, ok := set["is_super_user"].(bool); ok {
+		user.IsSuperUser = value
+	}
+	if value, ok := set["is_active"].(bool); ok {
+		user.IsActive = value
+	}
+
+	return nil
+}
+
+func (r *fakeUserRepo) Delete(ctx *saiTypes.RequestCtx, filter map[string]interface{}) error {
+	return nil
+}
+
+func (r *fakeUserRepo) List(ctx *saiTypes.RequestCtx, filter *types.UserFilterRequest) ([]*models.User, int64, error) {
+	r.listed = append(r.listed, filter)
+	var users []*models.User
+	if r.resolve != nil {
+		users = r.resolve(filter)
+	}
+	if r.total > 0 {
+		return users, r.total, nil
+	}
+	return users, int64(len(users)), nil
+}
+
+func (r *fakeUserRepo) GetFirstUser(ctx *saiTypes.RequestCtx) (*models.User, error) {
+	return nil, fmt.Errorf("user not found")
+}
+
+func (r *fakeUserRepo) CountUsers(ctx *saiTypes.RequestCtx) (int64, error) {
+	return int64(len(r.users)), nil
+}
+
+func requestAs(userID string) *saiTypes.RequestCtx {
+	ctx := &saiTypes.RequestCtx{RequestCtx: &fasthttp.RequestCtx{}}
+	if userID != "" {
+		ctx.SetUserValue("user_id", userID)
+	}
+	return ctx
+}
+
+func TestGuardSuperUsers(t *testing.T) {
+	admin := &models.User{InternalID: "admin", Email: "admin@example.com", IsSuperUser: true, IsActive: true}
+	caller := &models.User{InternalID: "caller", IsActive: true}
+
+	tests := []struct {
+		name     string
+		caller   string
+		filter   map[string]interface{}
+		selected []*models.User
+		wantErr  bool
+	}{
+		{"plain internal_id", "caller", map[string]interface{}{"internal_id": "admin"}, []*models.User{admin}, true},
+		{"$eq operator", "caller", map[string]interface{}{"internal_id": map[string]interface{}{"$eq": "admin"}}, []*models.User{admin}, true},
+		{"$regex operator", "caller", map[string]interface{}{"email": map[string]interface{}{"$regex": "^admin"}}, []*models.User{admin}, true},
+		{"$in operator", "caller", map[string]interface{}{"internal_id": map[string]interface{}{"$in": []string{"admin", "other"}}}, []*models.User{admin}, true},
+		{"no superuser selected", "caller", map[string]interface{}{"email": map[string]interface{}{"$regex": "^user"}}, nil, false},
+		{"superuser caller", "admin", map[string]interface{}{"internal_id": "admin"}, []*models.User{admin}, false},
+		{"internal call", "", map[string]interface{}{"internal_id": "admin"}, []*models.User{admin}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeUserRepo{
+				users: map[string]*models.User{"admin": admin, "caller": caller},
+				resolve: func(filter *types.UserFilterRequest) []*models.User {
+					return tt.selected
+				},
+			}
+			svc := NewUserService(repo, nil, nil)
+
+			err := svc.guardSuperUsers(requestAs(tt.caller), tt.filter)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("guardSuperUsers() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			for _, listed := range repo.listed {
+				if listed.SuperUser == nil || !*listed.SuperUser {
+					t.Fatalf("guardSuperUsers() listed users without restricting to superusers")
+				}
+				if !reflect.DeepEqual(listed.Match, tt.filter) {
+					t.Fatalf("guardSuperUsers() sent filter %v to storage, want %v", listed.Match, tt.filter)
+				}
+			}
+		})
+	}
+}
+
+func TestDeleteKeepsLastSuperUser(t *testing.T) {
+	admin := &models.User{InternalID: "admin", IsSuperUser: true, IsActive: true}
+	other := &models.User{InternalID: "other", IsActive: true}
+
+	repo := &fakeUserRepo{users: map[string]*models.User{"admin": admin, "other": other}}
+	repo.resolve = func(filter *types.UserFilterRequest) []*models.User {
+		// Storage resolves the $in filter below to both accounts.
+		var users []*models.User
+		for _, user := range []*models.User{admin, other} {
+			if filter.SuperUser != nil && user.IsSuperUser != *filter.SuperUser {
+				continue
+			}
+			users = append(users, user)
+		}
+		return users
+	}
+	svc := NewUserService(repo, nil, nil)
+
+	filter := map[string]interface{}{"internal_id": map[string]interface{}{"$in": []string{"admin", "other"}}}
+
+	err := svc.Delete(requestAs(""), filter)
+	if !errors.Is(err, ErrLastSuperUser) {
+		t.Fatalf("Delete() error = %v, want %v", err, ErrLastSuperUser)
+	}
+	if !admin.IsSuperUser {
+		t.Fatalf("Delete() left the last superuser demoted")
+	}
+}
+
+func TestUpdateRejectsProtectedWrites(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    map[string]interface{}
+		wantErr bool
+	}{
+		{"plain field", map[string]interface{}{"first_name": "Ann"}, false},
+		{"$set field", map[string]interface{}{"$set": map[string]interface{}{"first_name": "Ann"}}, false},
+		{"$unset field", map[string]interface{}{"$unset": map[string]interface{}{"first_name": ""}}, false},
+		{"protected field", map[string]interface{}{"is_super_user": true}, true},
+		{"$set protected field", map[string]interface{}{"$set": map[string]interface{}{"is_super_user": true}}, true},
+		{"$set dotted tenants", map[string]interface{}{"$set": map[string]interface{}{"tenants.0": "acme"}}, true},
+		{"$set dotted role assignment", map[string]interface{}{"$set": map[string]interface{}{"role_assignments.3": map[string]interface{}{}}}, true},
+		{"$unset dotted recovery code", map[string]interface{}{"$unset": map[string]interface{}{"mfa_recovery_codes.0": ""}}, true},
+		{"dotted protected field", map[string]interface{}{"mfa_recovery_codes.0": "x"}, true},
+		{"$push", map[string]interface{}{"$push": map[string]interface{}{"roles": "admin"}}, true},
+		{"$rename to protected field", map[string]interface{}{"$rename": map[string]interface{}{"first_name": "is_super_user"}}, true},
+		{"operator without fields", map[string]interface{}{"$set": "is_super_user"}, true},
+		{"operator mixed with field", map[string]interface{}{"$set": map[string]interface{}{}, "is_super_user": true}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeUserRepo{users: map[string]*models.User{}}
+			svc := NewUserService(repo, nil, nil)
+
+			err := svc.Update(requestAs(""), map[string]interface{}{"internal_id": "u1"}, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Update() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUpdatedField(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"roles", "roles"},
+		{"roles.0", "roles"},
+		{"data.department", "data"},
+	}
+
+	for _, tt := range tests {
+		if got := updatedField(tt.key); got != tt.want {
+			t.Errorf("updatedField(%s) = %s, want %s", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestMatchingUsersReadsEveryPage(t *testing.T) {
+	var all []*models.User
+	for i := 0; i < 2*userScanPageSize+5; i++ {
+		all = append(all, &models.User{InternalID: fmt.Sprintf("u%d", i)})
+	}
+
+	filter := map[string]interface{}{"email": map[string]interface{}{"$regex": "@example.com$"}}
+
+	repo := &fakeUserRepo{users: map[string]*models.User{}, total: int64(len(all))}
+	repo.resolve = func(req *types.UserFilterRequest) []*models.User {
+		if !reflect.DeepEqual(req.Match, filter) {
+			t.Fatalf("List() got filter %v, want %v", req.Match, filter)
+		}
+		start := (req.Page - 1) * req.Limit
+		if start >= len(all) {
+			return nil
+		}
+		return all[start:min(start+req.Limit, len(all))]
+	}
+	svc := NewUserService(repo, nil, nil)
+
+	users, err := svc.matchingUsers(requestAs(""), filter)
+	if err != nil {
+		t.Fatalf("matchingUsers() error = %v", err)
+	}
+	if len(users) != len(all) {
+		t.Fatalf("matchingUsers() returned %d users, want %d", len(users), len(all))
+	}
+}
@@ -178,6 +178,35 @@ func (r *MongoUserRepository) List(ctx *saiTypes.RequestCtx, filter *types.UserF
 		mongoFilter["is_active"] = *filter.Active
 	}
 
+	if filter.Status != "" {
+		mongoFilter["registration_status"] = filter.Status
+	}
+
+	if filter.Tenant != "" {
+		mongoFilter["tenants"] = map[string]interface{}{"$in": []string{filter.Tenant}}
+	}
+
+	if filter.SuperUser != nil {
+		mongoFilter["is_super_user"] = *filter.SuperUser
+	}
+
+	var and []interface{}
+
+	if filter.RoleWindowsDue > 0 {
+		and = append(and, map[string]interface{}{"$or": []interface{}{
+			map[string]interface{}{"role_assignments.expires_at": map[string]interface{}{"$gt": 0, "$lte": filter.RoleWindowsDue}},
+			map[string]interface{}{"role_assignments.not_before": map[string]interface{}{"$gt": 0, "$lte": filter.RoleWindowsDue}},
+		}})
+	}
+
+	if len(filter.Match) > 0 {
+		and = append(and, filter.Match)
+	}
+
+	if len(and) > 0 {
+		mongoFilter["$and"] = and
+	}
+
 	page := filter.Page
 	if page < 1 {
 		page = 1
@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/saiset-co/sai-auth/internal/models"
+	"github.com/saiset-co/sai-auth/internal/repository"
+	"github.com/saiset-co/sai-service/sai"
+	saiTypes "github.com/saiset-co/sai-service/types"
+)
+
+type MongoActionTokenRepository struct {
+	client saiTypes.ClientManager
+	hasher tokenHasher
+}
+
+func NewMongoActionTokenRepository(secretKey string) repository.ActionTokenRepository {
+	return &MongoActionTokenRepository{
+		client: sai.ClientManager(),
+		hasher: newTokenHasher(secretKey, "action-tokens"),
+	}
+}
+
+func (r *MongoActionTokenRepository) Create(ctx *saiTypes.RequestCtx, token *models.ActionToken) error {
+	document := *token
+	document.TokenHash = r.hashToken(token.Purpose, token.Token)
+	document.Token = ""
+
+	reqData := map[string]interface{}{
+		"collection": "action_tokens",
+		"data":       []interface{}{document},
+	}
+
+	_, statusCode, err := r.client.Call("storage", "POST", "/api/v1/documents", reqData, nil)
+	if err != nil {
+		return err
+	}
+
+	if statusCode >= 400 {
+		return fmt.Errorf("storage request failed with status %d", statusCode)
+	}
+
+	return nil
+}
+
+func (r *MongoActionTokenRepository) GetByToken(ctx *saiTypes.RequestCtx, purpose, token string) (*models.ActionToken, error) {
+	reqData := map[string]interface{}{
+		"collection": "action_tokens",
+		"filter": map[string]interface{}{
+			"purpose":    purpose,
+			"token_hash": r.hashToken(purpose, token),
+		},
+		"limit": 1,
+	}
+
+	response, statusCode, err := r.client.Call("storage", "GET", "/api/v1/documents", reqData, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if statusCode != 200 {
+		return nil, fmt.Errorf("storage request failed with status %d", statusCode)
+	}
+
+	var result struct {
+		Data []models.ActionToken `json:"data"`
+	}
+
+	if err := ctx.Unmarshal(response, &result); err != nil {
+		return nil, err
+	}
+
+	if len(result.Data) == 0 {
+		return nil, fmt.Errorf("token not found")
+	}
+
+	actionToken := &result.Data[0]
+
+	if time.Now().UnixNano() > actionToken.ExpiresAt {
+		r.Delete(ctx, actionToken.InternalID)
+		return nil, fmt.Errorf("token expired")
+	}
+
+	return actionToken, nil
+}
+
+func (r *MongoActionTokenRepository) Delete(ctx *saiTypes.RequestCtx, tokenID string) error {
+	return r.delete(ctx, map[string]interface{}{"internal_id": tokenID})
+}
+
+func (r *MongoActionTokenRepository) DeleteByUserID(ctx *saiTypes.RequestCtx, userID, purpose string) error {
+	return r.delete(ctx, map[string]interface{}{"user_id": userID, "purpose": purpose})
+}
+
+func (r *MongoActionTokenRepository) delete(ctx *saiTypes.RequestCtx, filter map[string]interface{}) error {
+	reqData := map[string]interface{}{
+		"collection": "action_tokens",
+		"filter":     filter,
+	}
+
+	_, statusCode, err := r.client.Call("storage", "DELETE", "/api/v1/documents", reqData, nil)
+	if err != nil {
+		return err
+	}
+
+	if statusCode >= 400 {
+		return fmt.Errorf("storage request failed with status %d", statusCode)
+	}
+
+	return nil
+}
+
+// hashToken binds the hash to the purpose so a token issued for one action
+// cannot be replayed against another.
+func (r *MongoActionTokenRepository) hashToken(purpose, token string) string {
+	return r.hasher.hash(purpose + ":" + token)
+}
@@ -0,0 +1,217 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/saiset-co/sai-auth/internal/models"
+	"github.com/saiset-co/sai-auth/internal/repository"
+	"github.com/saiset-co/sai-auth/types"
+	"github.com/saiset-co/sai-service/sai"
+	saiTypes "github.com/saiset-co/sai-service/types"
+)
+
+type MongoGroupRepository struct {
+	client saiTypes.ClientManager
+}
+
+func NewMongoGroupRepository() repository.GroupRepository {
+	return &MongoGroupRepository{
+		client: sai.ClientManager(),
+	}
+}
+
+func (r *MongoGroupRepository) Create(ctx *saiTypes.RequestCtx, group *models.Group) error {
+	reqData := map[string]interface{}{
+		"collection": "groups",
+		"data":       []interface{}{group},
+	}
+
+	_, statusCode, err := r.client.Call("storage", "POST", "/api/v1/documents", reqData, nil)
+	if err != nil {
+		return err
+	}
+
+	if statusCode >= 400 {
+		return fmt.Errorf("storage request failed with status %d", statusCode)
+	}
+
+	return nil
+}
+
+func (r *MongoGroupRepository) GetByID(ctx *saiTypes.RequestCtx, id string) (*models.Group, error) {
+	return r.getOne(ctx, map[string]interface{}{"internal_id": id})
+}
+
+func (r *MongoGroupRepository) GetByName(ctx *saiTypes.RequestCtx, name string) (*models.Group, error) {
+	return r.getOne(ctx, map[string]interface{}{"name": name})
+}
+
+func (r *MongoGroupRepository) getOne(ctx *saiTypes.RequestCtx, filter map[string]interface{}) (*models.Group, error) {
+	reqData := map[string]interface{}{
+		"collection": "groups",
+		"filter":     filter,
+		"limit":      1,
+	}
+
+	response, statusCode, err := r.client.Call("storage", "GET", "/api/v1/documents", reqData, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if statusCode != 200 {
+		return nil, fmt.Errorf("storage request failed with status %d", statusCode)
+	}
+
+	var result struct {
+		Data []models.Group `json:"data"`
+	}
+
+	if err := ctx.Unmarshal(response, &result); err != nil {
+		return nil, err
+	}
+
+	if len(result.Data) == 0 {
+		return nil, fmt.Errorf("group not found")
+	}
+
+	return &result.Data[0], nil
+}
+
+func (r *MongoGroupRepository) GetByIDs(ctx *saiTypes.RequestCtx, ids []string) ([]*models.Group, error) {
+	if len(ids) == 0 {
+		return []*models.Group{}, nil
+	}
+
+	reqData := map[string]interface{}{
+		"collection": "groups",
+		"filter":     map[string]interface{}{"internal_id": map[string]interface{}{"$in": ids}},
+	}
+
+	response, statusCode, err := r.client.Call("storage", "GET", "/api/v1/documents", reqData, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if statusCode != 200 {
+		return nil, fmt.Errorf("storage request failed with status %d", statusCode)
+	}
+
+	var result struct {
+		Data []models.Group `json:"data"`
+	}
+
+	if err := ctx.Unmarshal(response, &result); err != nil {
+		return nil, err
+	}
+
+	groups := make([]*models.Group, len(result.Data))
+	for i := range result.Data {
+		groups[i] = &result.Data[i]
+	}
+
+	return groups, nil
+}
+
+func (r *MongoGroupRepository) Update(ctx *saiTypes.RequestCtx, filter, data map[string]interface{}) error {
+	reqData := map[string]interface{}{
+		"collection": "groups",
+		"filter":     filter,
+		"data":       data,
+	}
+
+	_, statusCode, err := r.client.Call("storage", "PUT", "/api/v1/documents", reqData, nil)
+	if err != nil {
+		return err
+	}
+
+	if statusCode >= 400 {
+		return fmt.Errorf("storage request failed with status %d", statusCode)
+	}
+
+	return nil
+}
+
+func (r *MongoGroupRepository) Delete(ctx *saiTypes.RequestCtx, filter map[string]interface{}) error {
+	reqData := map[string]interface{}{
+		"collection": "groups",
+		"filter":     filter,
+	}
+
+	_, statusCode, err := r.client.Call("storage", "DELETE", "/api/v1/documents", reqData, nil)
+	if err != nil {
+		return err
+	}
+
+	if statusCode >= 400 {
+		return fmt.Errorf("storage request failed with status %d", statusCode)
+	}
+
+	return nil
+}
+
+func (r *MongoGroupRepository) List(ctx *saiTypes.RequestCtx, filter *types.GroupFilterRequest) ([]*models.Group, int64, error) {
+	mongoFilter := make(map[string]interface{})
+
+	if filter.Search != "" {
+		mongoFilter["name"] = map[string]interface{}{"$regex": filter.Search, "$options": "i"}
+	}
+
+	if filter.Active != nil {
+		mongoFilter["is_active"] = *filter.Active
+	}
+
+	if filter.Member != "" {
+		mongoFilter["members"] = map[string]interface{}{"$in": []string{filter.Member}}
+	}
+
+	if filter.Role != "" {
+		mongoFilter["roles"] = map[string]interface{}{"$in": []string{filter.Role}}
+	}
+
+	if filter.Parent != "" {
+		mongoFilter["parent_groups"] = map[string]interface{}{"$in": []string{filter.Parent}}
+	}
+
+	page := filter.Page
+	if page < 1 {
+		page = 1
+	}
+	limit := filter.Limit
+	if limit < 1 {
+		limit = 20
+	}
+	skip := (page - 1) * limit
+
+	reqData := map[string]interface{}{
+		"collection": "groups",
+		"filter":     mongoFilter,
+		"sort":       map[string]interface{}{"name": 1},
+		"limit":      limit,
+		"skip":       skip,
+	}
+
+	response, statusCode, err := r.client.Call("storage", "GET", "/api/v1/documents", reqData, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if statusCode != 200 {
+		return nil, 0, fmt.Errorf("storage request failed with status %d", statusCode)
+	}
+
+	var result struct {
+		Data  []models.Group `json:"data"`
+		Total int64          `json:"total"`
+	}
+
+	if err := ctx.Unmarshal(response, &result); err != nil {
+		return nil, 0, err
+	}
+
+	groups := make([]*models.Group, len(result.Data))
+	for i := range result.Data {
+		groups[i] = &result.Data[i]
+	}
+
+	return groups, result.Total, nil
+}
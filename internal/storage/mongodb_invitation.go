@@ -0,0 +1,173 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/saiset-co/sai-auth/internal/models"
+	"github.com/saiset-co/sai-auth/internal/repository"
+	"github.com/saiset-co/sai-auth/types"
+	"github.com/saiset-co/sai-service/sai"
+	saiTypes "github.com/saiset-co/sai-service/types"
+)
+
+type MongoInvitationRepository struct {
+	client saiTypes.ClientManager
+	hasher tokenHasher
+}
+
+func NewMongoInvitationRepository(secretKey string) repository.InvitationRepository {
+	return &MongoInvitationRepository{
+		client: sai.ClientManager(),
+		hasher: newTokenHasher(secretKey, "invitations"),
+	}
+}
+
+func (r *MongoInvitationRepository) Create(ctx *saiTypes.RequestCtx, invitation *models.Invitation) error {
+	document := *invitation
+	document.TokenHash = r.hasher.hash(invitation.Token)
+	document.Token = ""
+
+	reqData := map[string]interface{}{
+		"collection": "invitations",
+		"data":       []interface{}{document},
+	}
+
+	_, statusCode, err := r.client.Call("storage", "POST", "/api/v1/documents", reqData, nil)
+	if err != nil {
+		return err
+	}
+
+	if statusCode >= 400 {
+		return fmt.Errorf("storage request failed with status %d", statusCode)
+	}
+
+	return nil
+}
+
+func (r *MongoInvitationRepository) GetByID(ctx *saiTypes.RequestCtx, id string) (*models.Invitation, error) {
+	return r.getOne(ctx, map[string]interface{}{"internal_id": id})
+}
+
+func (r *MongoInvitationRepository) GetByToken(ctx *saiTypes.RequestCtx, token string) (*models.Invitation, error) {
+	return r.getOne(ctx, map[string]interface{}{"token_hash": r.hasher.hash(token)})
+}
+
+func (r *MongoInvitationRepository) getOne(ctx *saiTypes.RequestCtx, filter map[string]interface{}) (*models.Invitation, error) {
+	reqData := map[string]interface{}{
+		"collection": "invitations",
+		"filter":     filter,
+		"limit":      1,
+	}
+
+	response, statusCode, err := r.client.Call("storage", "GET", "/api/v1/documents", reqData, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if statusCode != 200 {
+		return nil, fmt.Errorf("storage request failed with status %d", statusCode)
+	}
+
+	var result struct {
+		Data []models.Invitation `json:"data"`
+	}
+
+	if err := ctx.Unmarshal(response, &result); err != nil {
+		return nil, err
+	}
+
+	if len(result.Data) == 0 {
+		return nil, fmt.Errorf("invitation not found")
+	}
+
+	return &result.Data[0], nil
+}
+
+func (r *MongoInvitationRepository) List(ctx *saiTypes.RequestCtx, filter *types.InvitationFilterRequest) ([]*models.Invitation, int64, error) {
+	mongoFilter := make(map[string]interface{})
+
+	// Expiry is not written back, so pending and expired are told apart by
+	// expires_at.
+	switch filter.Status {
+	case "":
+	case models.InvitationPending:
+		mongoFilter["status"] = models.InvitationPending
+		mongoFilter["expires_at"] = map[string]interface{}{"$gte": time.Now().UnixNano()}
+	case models.InvitationExpired:
+		mongoFilter["status"] = models.InvitationPending
+		mongoFilter["expires_at"] = map[string]interface{}{"$lt": time.Now().UnixNano()}
+	default:
+		mongoFilter["status"] = filter.Status
+	}
+
+	if filter.Email != "" {
+		mongoFilter["email"] = filter.Email
+	}
+
+	if filter.Search != "" {
+		mongoFilter["email"] = map[string]interface{}{"$regex": filter.Search, "$options": "i"}
+	}
+
+	page := filter.Page
+	if page < 1 {
+		page = 1
+	}
+	limit := filter.Limit
+	if limit < 1 {
+		limit = 20
+	}
+	skip := (page - 1) * limit
+
+	reqData := map[string]interface{}{
+		"collection": "invitations",
+		"filter":     mongoFilter,
+		"sort":       map[string]interface{}{"cr_time": -1},
+		"limit":      limit,
+		"skip":       skip,
+	}
+
+	response, statusCode, err := r.client.Call("storage", "GET", "/api/v1/documents", reqData, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if statusCode != 200 {
+		return nil, 0, fmt.Errorf("storage request failed with status %d", statusCode)
+	}
+
+	var result struct {
+		Data  []models.Invitation `json:"data"`
+		Total int64               `json:"total"`
+	}
+
+	if err := ctx.Unmarshal(response, &result); err != nil {
+		return nil, 0, err
+	}
+
+	invitations := make([]*models.Invitation, len(result.Data))
+	for i := range result.Data {
+		invitations[i] = &result.Data[i]
+	}
+
+	return invitations, result.Total, nil
+}
+
+func (r *MongoInvitationRepository) Update(ctx *saiTypes.RequestCtx, filter, data map[string]interface{}) error {
+	reqData := map[string]interface{}{
+		"collection": "invitations",
+		"filter":     filter,
+		"data":       data,
+	}
+
+	_, statusCode, err := r.client.Call("storage", "PUT", "/api/v1/documents", reqData, nil)
+	if err != nil {
+		return err
+	}
+
+	if statusCode >= 400 {
+		return fmt.Errorf("storage request failed with status %d", statusCode)
+	}
+
+	return nil
+}
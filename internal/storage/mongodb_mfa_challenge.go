@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/saiset-co/sai-auth/internal/models"
+	"github.com/saiset-co/sai-auth/internal/repository"
+	"github.com/saiset-co/sai-service/sai"
+	saiTypes "github.com/saiset-co/sai-service/types"
+)
+
+type MongoMFAChallengeRepository struct {
+	client saiTypes.ClientManager
+	hasher tokenHasher
+}
+
+func NewMongoMFAChallengeRepository(secretKey string) repository.MFAChallengeRepository {
+	return &MongoMFAChallengeRepository{
+		client: sai.ClientManager(),
+		hasher: newTokenHasher(secretKey, "mfa-challenges"),
+	}
+}
+
+func (r *MongoMFAChallengeRepository) Create(ctx *saiTypes.RequestCtx, challenge *models.MFAChallenge) error {
+	document := *challenge
+	document.TokenHash = r.hasher.hash(challenge.Token)
+	document.Token = ""
+
+	reqData := map[string]interface{}{
+		"collection": "mfa_challenges",
+		"data":       []interface{}{document},
+	}
+
+	_, statusCode, err := r.client.Call("storage", "POST", "/api/v1/documents", reqData, nil)
+	if err != nil {
+		return err
+	}
+
+	if statusCode >= 400 {
+		return fmt.Errorf("storage request failed with status %d", statusCode)
+	}
+
+	return nil
+}
+
+func (r *MongoMFAChallengeRepository) GetByToken(ctx *saiTypes.RequestCtx, token string) (*models.MFAChallenge, error) {
+	reqData := map[string]interface{}{
+		"collection": "mfa_challenges",
+		"filter":     map[string]interface{}{"token_hash": r.hasher.hash(token)},
+		"limit":      1,
+	}
+
+	response, statusCode, err := r.client.Call("storage", "GET", "/api/v1/documents", reqData, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if statusCode != 200 {
+		return nil, fmt.Errorf("storage request failed with status %d", statusCode)
+	}
+
+	var result struct {
+		Data []models.MFAChallenge `json:"data"`
+	}
+
+	if err := ctx.Unmarshal(response, &result); err != nil {
+		return nil, err
+	}
+
+	if len(result.Data) == 0 {
+		return nil, fmt.Errorf("challenge not found")
+	}
+
+	challenge := &result.Data[0]
+
+	if time.Now().UnixNano() > challenge.ExpiresAt {
+		r.Delete(ctx, challenge.InternalID)
+		return nil, fmt.Errorf("challenge expired")
+	}
+
+	return challenge, nil
+}
+
+// ListByUserID returns the open challenges of a user, newest first.
+func (r *MongoMFAChallengeRepository) ListByUserID(ctx *saiTypes.RequestCtx, userID string) ([]*models.MFAChallenge, error) {
+	reqData := map[string]interface{}{
+		"collection": "mfa_challenges",
+		"filter": map[string]interface{}{
+			"user_id":    userID,
+			"expires_at": map[string]interface{}{"$gt": time.Now().UnixNano()},
+		},
+		"sort": map[string]interface{}{"cr_time": -1},
+	}
+
+	response, statusCode, err := r.client.Call("storage", "GET", "/api/v1/documents", reqData, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if statusCode != 200 {
+		return nil, fmt.Errorf("storage request failed with status %d", statusCode)
+	}
+
+	var result struct {
+		Data []models.MFAChallenge `json:"data"`
+	}
+
+	if err := ctx.Unmarshal(response, &result); err != nil {
+		return nil, err
+	}
+
+	challenges := make([]*models.MFAChallenge, len(result.Data))
+	for i := range result.Data {
+		challenges[i] = &result.Data[i]
+	}
+
+	return challenges, nil
+}
+
+func (r *MongoMFAChallengeRepository) IncrementAttempts(ctx *saiTypes.RequestCtx, challengeID string) error {
+	reqData := map[string]interface{}{
+		"collection": "mfa_challenges",
+		"filter":     map[string]interface{}{"internal_id": challengeID},
+		"data":       map[string]interface{}{"$inc": map[string]interface{}{"attempts": 1}},
+	}
+
+	_, statusCode, err := r.client.Call("storage", "PUT", "/api/v1/documents", reqData, nil)
+	if err != nil {
+		return err
+	}
+
+	if statusCode >= 400 {
+		return fmt.Errorf("storage request failed with status %d", statusCode)
+	}
+
+	return nil
+}
+
+func (r *MongoMFAChallengeRepository) Delete(ctx *saiTypes.RequestCtx, challengeID string) error {
+	reqData := map[string]interface{}{
+		"collection": "mfa_challenges",
+		"filter":     map[string]interface{}{"internal_id": challengeID},
+	}
+
+	_, statusCode, err := r.client.Call("storage", "DELETE", "/api/v1/documents", reqData, nil)
+	if err != nil {
+		return err
+	}
+
+	if statusCode >= 400 {
+		return fmt.Errorf("storage request failed with status %d", statusCode)
+	}
+
+	return nil
+}
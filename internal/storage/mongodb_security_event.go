@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/saiset-co/sai-auth/internal/models"
+	"github.com/saiset-co/sai-auth/internal/repository"
+	"github.com/saiset-co/sai-auth/types"
+	"github.com/saiset-co/sai-service/sai"
+	saiTypes "github.com/saiset-co/sai-service/types"
+)
+
+type MongoSecurityEventRepository struct {
+	client saiTypes.ClientManager
+}
+
+func NewMongoSecurityEventRepository() repository.SecurityEventRepository {
+	return &MongoSecurityEventRepository{
+		client: sai.ClientManager(),
+	}
+}
+
+func (r *MongoSecurityEventRepository) Create(ctx *saiTypes.RequestCtx, event *models.SecurityEvent) error {
+	reqData := map[string]interface{}{
+		"collection": "security_events",
+		"data":       []interface{}{event},
+	}
+
+	_, statusCode, err := r.client.Call("storage", "POST", "/api/v1/documents", reqData, nil)
+	if err != nil {
+		return err
+	}
+
+	if statusCode >= 400 {
+		return fmt.Errorf("storage request failed with status %d", statusCode)
+	}
+
+	return nil
+}
+
+func (r *MongoSecurityEventRepository) List(ctx *saiTypes.RequestCtx, filter *types.SecurityEventFilterRequest) ([]*models.SecurityEvent, int64, error) {
+	mongoFilter := make(map[string]interface{})
+
+	if filter.Type != "" {
+		mongoFilter["type"] = filter.Type
+	}
+
+	if filter.UserID != "" {
+		mongoFilter["user_id"] = filter.UserID
+	}
+
+	if filter.IP != "" {
+		mongoFilter["ip"] = filter.IP
+	}
+
+	if filter.Since > 0 {
+		mongoFilter["cr_time"] = map[string]interface{}{"$gte": filter.Since}
+	}
+
+	page := filter.Page
+	if page < 1 {
+		page = 1
+	}
+	limit := filter.Limit
+	if limit < 1 {
+		limit = 20
+	}
+	skip := (page - 1) * limit
+
+	reqData := map[string]interface{}{
+		"collection": "security_events",
+		"filter":     mongoFilter,
+		"sort":       map[string]interface{}{"cr_time": -1},
+		"limit":      limit,
+		"skip":       skip,
+	}
+
+	response, statusCode, err := r.client.Call("storage", "GET", "/api/v1/documents", reqData, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if statusCode != 200 {
+		return nil, 0, fmt.Errorf("storage request failed with status %d", statusCode)
+	}
+
+	var result struct {
+		Data  []models.SecurityEvent `json:"data"`
+		Total int64                  `json:"total"`
+	}
+
+	if err := ctx.Unmarshal(response, &result); err != nil {
+		return nil, 0, err
+	}
+
+	events := make([]*models.SecurityEvent, len(result.Data))
+	for i := range result.Data {
+		events[i] = &result.Data[i]
+	}
+
+	return events, result.Total, nil
+}
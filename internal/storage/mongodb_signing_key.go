@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/saiset-co/sai-auth/internal/models"
+	"github.com/saiset-co/sai-auth/internal/repository"
+	"github.com/saiset-co/sai-service/sai"
+	saiTypes "github.com/saiset-co/sai-service/types"
+	"go.uber.org/zap"
+)
+
+// MongoSigningKeyRepository keeps JWT signing keys in the "signing_keys"
+// collection. Private keys are sealed with AES-GCM under a key derived from
+// the service secret, so a storage dump alone cannot be used to mint tokens.
+type MongoSigningKeyRepository struct {
+	client saiTypes.ClientManager
+	aead   cipher.AEAD
+}
+
+func NewMongoSigningKeyRepository(secretKey string) (repository.SigningKeyRepository, error) {
+	key := sha256.Sum256([]byte("sai-auth:signing-keys:" + secretKey))
+
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MongoSigningKeyRepository{
+		client: sai.ClientManager(),
+		aead:   aead,
+	}, nil
+}
+
+func (r *MongoSigningKeyRepository) Create(ctx *saiTypes.RequestCtx, key *models.SigningKey) error {
+	document := *key
+
+	sealed, err := r.seal(key.PrivateKey)
+	if err != nil {
+		return err
+	}
+	document.PrivateKey = sealed
+
+	reqData := map[string]interface{}{
+		"collection": "signing_keys",
+		"data":       []interface{}{document},
+	}
+
+	_, statusCode, err := r.client.Call("storage", "POST", "/api/v1/documents", reqData, nil)
+	if err != nil {
+		return err
+	}
+
+	if statusCode >= 400 {
+		return fmt.Errorf("storage request failed with status %d", statusCode)
+	}
+
+	return nil
+}
+
+func (r *MongoSigningKeyRepository) ListActive(ctx *saiTypes.RequestCtx, after int64) ([]*models.SigningKey, error) {
+	reqData := map[string]interface{}{
+		"collection": "signing_keys",
+		"filter": map[string]interface{}{
+			"expires_at": map[string]interface{}{"$gt": after},
+		},
+		"sort": map[string]interface{}{"cr_time": -1},
+	}
+
+	response, statusCode, err := r.client.Call("storage", "GET", "/api/v1/documents", reqData, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if statusCode != 200 {
+		return nil, fmt.Errorf("storage request failed with status %d", statusCode)
+	}
+
+	var result struct {
+		Data []models.SigningKey `json:"data"`
+	}
+
+	if err := ctx.Unmarshal(response, &result); err != nil {
+		return nil, err
+	}
+
+	keys := make([]*models.SigningKey, 0, len(result.Data))
+	for i := range result.Data {
+		key := &result.Data[i]
+
+		privateKey, err := r.open(key.PrivateKey)
+		if err != nil {
+			sai.Logger().Warn("Skipping signing key that cannot be decrypted", zap.String("kid", key.InternalID))
+			continue
+		}
+		key.PrivateKey = privateKey
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func (r *MongoSigningKeyRepository) DeleteExpired(ctx *saiTypes.RequestCtx, before int64) error {
+	reqData := map[string]interface{}{
+		"collection": "signing_keys",
+		"filter": map[string]interface{}{
+			"expires_at": map[string]interface{}{"$lte": before},
+		},
+	}
+
+	_, statusCode, err := r.client.Call("storage", "DELETE", "/api/v1/documents", reqData, nil)
+	if err != nil {
+		return err
+	}
+
+	if statusCode >= 400 {
+		return fmt.Errorf("storage request failed with status %d", statusCode)
+	}
+
+	return nil
+}
+
+func (r *MongoSigningKeyRepository) seal(plaintext string) (string, error) {
+	nonce := make([]byte, r.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := r.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (r *MongoSigningKeyRepository) open(ciphertext string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", err
+	}
+
+	if len(sealed) < r.aead.NonceSize() {
+		return "", fmt.Errorf("sealed key is too short")
+	}
+
+	nonce, data := sealed[:r.aead.NonceSize()], sealed[r.aead.NonceSize():]
+	plaintext, err := r.aead.Open(nil, nonce, data, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/saiset-co/sai-auth/internal/models"
+	"github.com/saiset-co/sai-auth/internal/repository"
+	"github.com/saiset-co/sai-auth/types"
+	"github.com/saiset-co/sai-service/sai"
+	saiTypes "github.com/saiset-co/sai-service/types"
+)
+
+type MongoTenantRepository struct {
+	client saiTypes.ClientManager
+}
+
+func NewMongoTenantRepository() repository.TenantRepository {
+	return &MongoTenantRepository{
+		client: sai.ClientManager(),
+	}
+}
+
+func (r *MongoTenantRepository) Create(ctx *saiTypes.RequestCtx, tenant *models.Tenant) error {
+	reqData := map[string]interface{}{
+		"collection": "tenants",
+		"data":       []interface{}{tenant},
+	}
+
+	_, statusCode, err := r.client.Call("storage", "POST", "/api/v1/documents", reqData, nil)
+	if err != nil {
+		return err
+	}
+
+	if statusCode >= 400 {
+		return fmt.Errorf("storage request failed with status %d", statusCode)
+	}
+
+	return nil
+}
+
+func (r *MongoTenantRepository) GetByID(ctx *saiTypes.RequestCtx, id string) (*models.Tenant, error) {
+	return r.getOne(ctx, map[string]interface{}{"internal_id": id})
+}
+
+func (r *MongoTenantRepository) GetByName(ctx *saiTypes.RequestCtx, name string) (*models.Tenant, error) {
+	return r.getOne(ctx, map[string]interface{}{"name": name})
+}
+
+func (r *MongoTenantRepository) getOne(ctx *saiTypes.RequestCtx, filter map[string]interface{}) (*models.Tenant, error) {
+	reqData := map[string]interface{}{
+		"collection": "tenants",
+		"filter":     filter,
+		"limit":      1,
+	}
+
+	response, statusCode, err := r.client.Call("storage", "GET", "/api/v1/documents", reqData, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if statusCode != 200 {
+		return nil, fmt.Errorf("storage request failed with status %d", statusCode)
+	}
+
+	var result struct {
+		Data []models.Tenant `json:"data"`
+	}
+
+	if err := ctx.Unmarshal(response, &result); err != nil {
+		return nil, err
+	}
+
+	if len(result.Data) == 0 {
+		return nil, fmt.Errorf("tenant not found")
+	}
+
+	return &result.Data[0], nil
+}
+
+func (r *MongoTenantRepository) Update(ctx *saiTypes.RequestCtx, filter, data map[string]interface{}) error {
+	reqData := map[string]interface{}{
+		"collection": "tenants",
+		"filter":     filter,
+		"data":       data,
+	}
+
+	_, statusCode, err := r.client.Call("storage", "PUT", "/api/v1/documents", reqData, nil)
+	if err != nil {
+		return err
+	}
+
+	if statusCode >= 400 {
+		return fmt.Errorf("storage request failed with status %d", statusCode)
+	}
+
+	return nil
+}
+
+func (r *MongoTenantRepository) List(ctx *saiTypes.RequestCtx, filter *types.TenantFilterRequest) ([]*models.Tenant, int64, error) {
+	mongoFilter := make(map[string]interface{})
+
+	if filter.Search != "" {
+		mongoFilter["name"] = map[string]interface{}{"$regex": filter.Search, "$options": "i"}
+	}
+
+	if filter.Active != nil {
+		mongoFilter["is_active"] = *filter.Active
+	}
+
+	page := filter.Page
+	if page < 1 {
+		page = 1
+	}
+	limit := filter.Limit
+	if limit < 1 {
+		limit = 20
+	}
+	skip := (page - 1) * limit
+
+	reqData := map[string]interface{}{
+		"collection": "tenants",
+		"filter":     mongoFilter,
+		"sort":       map[string]interface{}{"name": 1},
+		"limit":      limit,
+		"skip":       skip,
+	}
+
+	response, statusCode, err := r.client.Call("storage", "GET", "/api/v1/documents", reqData, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if statusCode != 200 {
+		return nil, 0, fmt.Errorf("storage request failed with status %d", statusCode)
+	}
+
+	var result struct {
+		Data  []models.Tenant `json:"data"`
+		Total int64           `json:"total"`
+	}
+
+	if err := ctx.Unmarshal(response, &result); err != nil {
+		return nil, 0, err
+	}
+
+	tenants := make([]*models.Tenant, len(result.Data))
+	for i := range result.Data {
+		tenants[i] = &result.Data[i]
+	}
+
+	return tenants, result.Total, nil
+}
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
@@ -11,20 +12,32 @@ import (
 	saiTypes "github.com/saiset-co/sai-service/types"
 )
 
+// ErrRefreshTokenRotated is returned by Update when another request rotated
+// the refresh token first.
+var ErrRefreshTokenRotated = errors.New("refresh token already rotated")
+
 type MongoTokenRepository struct {
 	client saiTypes.ClientManager
+	hasher tokenHasher
 }
 
-func NewMongoTokenRepository() repository.TokenRepository {
+func NewMongoTokenRepository(secretKey string) repository.TokenRepository {
 	return &MongoTokenRepository{
 		client: sai.ClientManager(),
+		hasher: newTokenHasher(secretKey, "tokens"),
 	}
 }
 
 func (r *MongoTokenRepository) Store(ctx *saiTypes.RequestCtx, token *models.Token) error {
+	document := *token
+	document.AccessTokenHash = r.hasher.hash(token.AccessToken)
+	document.RefreshTokenHash = r.hasher.hash(token.RefreshToken)
+	document.AccessToken = ""
+	document.RefreshToken = ""
+
 	reqData := map[string]interface{}{
 		"collection": "tokens",
-		"data":       []interface{}{token},
+		"data":       []interface{}{document},
 	}
 
 	_, _, err := r.client.Call("storage", "POST", "/api/v1/documents", reqData, nil)
@@ -34,8 +47,13 @@ func (r *MongoTokenRepository) Store(ctx *saiTypes.RequestCtx, token *models.Tok
 func (r *MongoTokenRepository) GetByAccessToken(ctx *saiTypes.RequestCtx, accessToken string) (*models.Token, error) {
 	reqData := map[string]interface{}{
 		"collection": "tokens",
-		"filter":     map[string]interface{}{"access_token": accessToken},
-		"limit":      1,
+		"filter": map[string]interface{}{
+			"$or": []interface{}{
+				map[string]interface{}{"access_token_hash": r.hasher.hash(accessToken)},
+				map[string]interface{}{"access_token": accessToken},
+			},
+		},
+		"limit": 1,
 	}
 
 	response, statusCode, err := r.client.Call("storage", "GET", "/api/v1/documents", reqData, nil)
@@ -66,14 +84,21 @@ func (r *MongoTokenRepository) GetByAccessToken(ctx *saiTypes.RequestCtx, access
 		return nil, fmt.Errorf("token expired")
 	}
 
+	r.migrateLegacyToken(ctx, token)
+
 	return token, nil
 }
 
 func (r *MongoTokenRepository) GetByRefreshToken(ctx *saiTypes.RequestCtx, refreshToken string) (*models.Token, error) {
 	reqData := map[string]interface{}{
 		"collection": "tokens",
-		"filter":     map[string]interface{}{"refresh_token": refreshToken},
-		"limit":      1,
+		"filter": map[string]interface{}{
+			"$or": []interface{}{
+				map[string]interface{}{"refresh_token_hash": r.hasher.hash(refreshToken)},
+				map[string]interface{}{"refresh_token": refreshToken},
+			},
+		},
+		"limit": 1,
 	}
 
 	response, statusCode, err := r.client.Call("storage", "GET", "/api/v1/documents", reqData, nil)
@@ -104,9 +129,42 @@ func (r *MongoTokenRepository) GetByRefreshToken(ctx *saiTypes.RequestCtx, refre
 		return nil, fmt.Errorf("refresh token expired")
 	}
 
+	r.migrateLegacyToken(ctx, token)
+
 	return token, nil
 }
 
+func (r *MongoTokenRepository) GetByRotatedRefreshToken(ctx *saiTypes.RequestCtx, refreshToken string) (*models.Token, error) {
+	reqData := map[string]interface{}{
+		"collection": "tokens",
+		"filter":     map[string]interface{}{"rotated_refresh_tokens": r.hasher.hash(refreshToken)},
+		"limit":      1,
+	}
+
+	response, statusCode, err := r.client.Call("storage", "GET", "/api/v1/documents", reqData, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if statusCode != 200 {
+		return nil, fmt.Errorf("storage request failed with status %d", statusCode)
+	}
+
+	var result struct {
+		Data []models.Token `json:"data"`
+	}
+
+	if err := ctx.Unmarshal(response, &result); err != nil {
+		return nil, err
+	}
+
+	if len(result.Data) == 0 {
+		return nil, fmt.Errorf("refresh token not found")
+	}
+
+	return &result.Data[0], nil
+}
+
 func (r *MongoTokenRepository) GetByUserID(ctx *saiTypes.RequestCtx, userID string) (*models.Token, error) {
 	reqData := map[string]interface{}{
 		"collection": "tokens",
@@ -139,18 +197,78 @@ func (r *MongoTokenRepository) GetByUserID(ctx *saiTypes.RequestCtx, userID stri
 	return &result.Data[0], nil
 }
 
+func (r *MongoTokenRepository) ListByUserID(ctx *saiTypes.RequestCtx, userID string) ([]*models.Token, error) {
+	reqData := map[string]interface{}{
+		"collection": "tokens",
+		"filter": map[string]interface{}{
+			"user_id":            userID,
+			"refresh_expires_at": map[string]interface{}{"$gt": time.Now().UnixNano()},
+		},
+		"sort": map[string]interface{}{"cr_time": -1},
+	}
+
+	response, statusCode, err := r.client.Call("storage", "GET", "/api/v1/documents", reqData, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if statusCode != 200 {
+		return nil, fmt.Errorf("storage request failed with status %d", statusCode)
+	}
+
+	var result struct {
+		Data []models.Token `json:"data"`
+	}
+
+	if err := ctx.Unmarshal(response, &result); err != nil {
+		return nil, err
+	}
+
+	tokens := make([]*models.Token, len(result.Data))
+	for i := range result.Data {
+		tokens[i] = &result.Data[i]
+	}
+
+	return tokens, nil
+}
+
+// Update saves the token. When a new refresh token is set, the update only
+// applies while the stored hash is still token.RefreshTokenHash, so of two
+// concurrent rotations only one wins and the other gets
+// ErrRefreshTokenRotated.
 func (r *MongoTokenRepository) Update(ctx *saiTypes.RequestCtx, token *models.Token) error {
 	filter := map[string]interface{}{
 		"internal_id": token.InternalID,
 	}
 
+	rotating := token.RefreshToken != "" && token.RefreshTokenHash != ""
+	if rotating {
+		filter["refresh_token_hash"] = token.RefreshTokenHash
+	}
+
+	setData := map[string]interface{}{
+		"rotated_refresh_tokens": token.RotatedRefreshTokens,
+		"expires_at":             token.ExpiresAt,
+		"refresh_expires_at":     token.RefreshExpiresAt,
+		"compiled_permissions":   token.CompiledPermissions,
+		"ch_time":                time.Now().UnixNano(),
+	}
+
+	if token.PermissionsChangedAt != 0 {
+		setData["permissions_changed_at"] = token.PermissionsChangedAt
+	}
+
+	if token.AccessToken != "" {
+		setData["access_token_hash"] = r.hasher.hash(token.AccessToken)
+	}
+
+	if token.RefreshToken != "" {
+		setData["refresh_token_hash"] = r.hasher.hash(token.RefreshToken)
+	}
+
 	updateData := map[string]interface{}{
-		"$set": map[string]interface{}{
-			"access_token":       token.AccessToken,
-			"refresh_token":      token.RefreshToken,
-			"expires_at":         token.ExpiresAt,
-			"refresh_expires_at": token.RefreshExpiresAt,
-		},
+		"$set":   setData,
+		"$unset": map[string]interface{}{"access_token": "", "refresh_token": ""},
 	}
 
 	reqData := map[string]interface{}{
@@ -168,6 +286,67 @@ func (r *MongoTokenRepository) Update(ctx *saiTypes.RequestCtx, token *models.To
 		return fmt.Errorf("storage request failed with status %d", statusCode)
 	}
 
+	if rotating {
+		stored, err := r.exists(ctx, map[string]interface{}{
+			"internal_id":        token.InternalID,
+			"refresh_token_hash": setData["refresh_token_hash"],
+		})
+		if err != nil {
+			return err
+		}
+		if !stored {
+			return ErrRefreshTokenRotated
+		}
+	}
+
+	return nil
+}
+
+func (r *MongoTokenRepository) exists(ctx *saiTypes.RequestCtx, filter map[string]interface{}) (bool, error) {
+	reqData := map[string]interface{}{
+		"collection": "tokens",
+		"filter":     filter,
+		"limit":      1,
+	}
+
+	response, statusCode, err := r.client.Call("storage", "GET", "/api/v1/documents", reqData, nil)
+	if err != nil {
+		return false, err
+	}
+
+	if statusCode != 200 {
+		return false, fmt.Errorf("storage request failed with status %d", statusCode)
+	}
+
+	var result struct {
+		Data []models.Token `json:"data"`
+	}
+
+	if err := ctx.Unmarshal(response, &result); err != nil {
+		return false, err
+	}
+
+	return len(result.Data) > 0, nil
+}
+
+func (r *MongoTokenRepository) Touch(ctx *saiTypes.RequestCtx, tokenID string, lastUsedAt int64) error {
+	reqData := map[string]interface{}{
+		"collection": "tokens",
+		"filter":     map[string]interface{}{"internal_id": tokenID},
+		"data": map[string]interface{}{
+			"$set": map[string]interface{}{"last_used_at": lastUsedAt},
+		},
+	}
+
+	_, statusCode, err := r.client.Call("storage", "PUT", "/api/v1/documents", reqData, nil)
+	if err != nil {
+		return err
+	}
+
+	if statusCode >= 400 {
+		return fmt.Errorf("storage request failed with status %d", statusCode)
+	}
+
 	return nil
 }
 
@@ -215,6 +394,53 @@ func (r *MongoTokenRepository) DeleteByUserID(ctx *saiTypes.RequestCtx, userID s
 	return nil
 }
 
+// DeleteExpiredByUserID removes the sessions of a user whose refresh token
+// has expired.
+func (r *MongoTokenRepository) DeleteExpiredByUserID(ctx *saiTypes.RequestCtx, userID string) error {
+	filter := map[string]interface{}{
+		"user_id":            userID,
+		"refresh_expires_at": map[string]interface{}{"$lte": time.Now().UnixNano()},
+	}
+
+	reqData := map[string]interface{}{
+		"collection": "tokens",
+		"filter":     filter,
+	}
+
+	_, statusCode, err := r.client.Call("storage", "DELETE", "/api/v1/documents", reqData, nil)
+	if err != nil {
+		return err
+	}
+
+	if statusCode >= 400 {
+		return fmt.Errorf("storage request failed with status %d", statusCode)
+	}
+
+	return nil
+}
+
+func (r *MongoTokenRepository) DeleteByFamilyID(ctx *saiTypes.RequestCtx, familyID string) error {
+	filter := map[string]interface{}{
+		"family_id": familyID,
+	}
+
+	reqData := map[string]interface{}{
+		"collection": "tokens",
+		"filter":     filter,
+	}
+
+	_, statusCode, err := r.client.Call("storage", "DELETE", "/api/v1/documents", reqData, nil)
+	if err != nil {
+		return err
+	}
+
+	if statusCode >= 400 {
+		return fmt.Errorf("storage request failed with status %d", statusCode)
+	}
+
+	return nil
+}
+
 func (r *MongoTokenRepository) List(ctx *saiTypes.RequestCtx, filter *types.TokenFilterRequest) ([]*models.Token, int64, error) {
 	mongoFilter := make(map[string]interface{})
 
@@ -279,6 +505,84 @@ func (r *MongoTokenRepository) List(ctx *saiTypes.RequestCtx, filter *types.Toke
 	return tokens, result.Total, nil
 }
 
+// MigratePlaintextTokens hashes the tokens still stored in plaintext. It
+// works through at most the documents that were pending when it started, so
+// a document that keeps failing to migrate cannot make it loop forever.
+func (r *MongoTokenRepository) MigratePlaintextTokens(ctx *saiTypes.RequestCtx) (int, error) {
+	const batchSize = 100
+
+	migrated := 0
+	pending := int64(-1)
+
+	for batch := int64(0); pending < 0 || batch*batchSize < pending; batch++ {
+		reqData := map[string]interface{}{
+			"collection": "tokens",
+			"filter": map[string]interface{}{
+				"$or": []interface{}{
+					map[string]interface{}{"access_token": map[string]interface{}{"$exists": true}},
+					map[string]interface{}{"refresh_token": map[string]interface{}{"$exists": true}},
+				},
+			},
+			"limit": batchSize,
+		}
+
+		response, statusCode, err := r.client.Call("storage", "GET", "/api/v1/documents", reqData, nil)
+		if err != nil {
+			return migrated, err
+		}
+
+		if statusCode != 200 {
+			return migrated, fmt.Errorf("storage request failed with status %d", statusCode)
+		}
+
+		var result struct {
+			Data  []models.Token `json:"data"`
+			Total int64          `json:"total"`
+		}
+
+		if err := ctx.Unmarshal(response, &result); err != nil {
+			return migrated, err
+		}
+
+		if len(result.Data) == 0 {
+			return migrated, nil
+		}
+
+		if pending < 0 {
+			pending = max(result.Total, int64(len(result.Data)))
+		}
+
+		for i := range result.Data {
+			if err := r.Update(ctx, &result.Data[i]); err != nil {
+				return migrated, err
+			}
+			migrated++
+		}
+	}
+
+	return migrated, nil
+}
+
+func (r *MongoTokenRepository) migrateLegacyToken(ctx *saiTypes.RequestCtx, token *models.Token) {
+	if token.AccessToken == "" && token.RefreshToken == "" {
+		return
+	}
+
+	if err := r.Update(ctx, token); err != nil {
+		return
+	}
+
+	if token.AccessToken != "" {
+		token.AccessTokenHash = r.hasher.hash(token.AccessToken)
+	}
+	if token.RefreshToken != "" {
+		token.RefreshTokenHash = r.hasher.hash(token.RefreshToken)
+	}
+
+	token.AccessToken = ""
+	token.RefreshToken = ""
+}
+
 func (r *MongoTokenRepository) IsValid(ctx *saiTypes.RequestCtx, accessToken string) bool {
 	token, err := r.GetByAccessToken(ctx, accessToken)
 	return err == nil && token != nil
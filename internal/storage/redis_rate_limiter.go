@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/saiset-co/sai-auth/internal/models"
+	"github.com/saiset-co/sai-auth/types"
+)
+
+const rateLimitKeyPrefix = "sai-auth:rate"
+
+// slidingWindowScript keeps one sorted set per window with a member per
+// accepted request, scored by its timestamp in milliseconds. KEYS holds one
+// key per window and ARGV is now, member, then a window and limit pair per
+// key. The hit is recorded in every window only if all of them allow it, so
+// a request refused by one window uses no quota of the others. It returns
+// {allowed, remaining, reset_ms, index} for the refusing window, or for the
+// window with the least remaining quota.
+var slidingWindowScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local member = ARGV[2]
+
+local counts = {}
+local allowed = 1
+local denied = 0
+
+for i, key in ipairs(KEYS) do
+	local window = tonumber(ARGV[1 + i * 2])
+	local limit = tonumber(ARGV[2 + i * 2])
+
+	redis.call('ZREMRANGEBYSCORE', key, 0, now - window)
+	counts[i] = redis.call('ZCARD', key)
+
+	if allowed == 1 and counts[i] >= limit then
+		allowed = 0
+		denied = i
+	end
+end
+
+local result = nil
+
+for i, key in ipairs(KEYS) do
+	local window = tonumber(ARGV[1 + i * 2])
+	local limit = tonumber(ARGV[2 + i * 2])
+
+	if allowed == 1 then
+		redis.call('ZADD', key, now, member)
+		counts[i] = counts[i] + 1
+	end
+
+	redis.call('PEXPIRE', key, window)
+
+	local reset = window
+	local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+	if oldest[2] then
+		reset = tonumber(oldest[2]) + window - now
+	end
+
+	local remaining = limit - counts[i]
+	if remaining < 0 then
+		remaining = 0
+	end
+
+	if (allowed == 0 and i == denied) or (allowed == 1 and (result == nil or remaining < result[2])) then
+		result = {allowed, remaining, reset, i}
+	end
+end
+
+return result
+`)
+
+type RedisRateLimiter struct {
+	client *redis.Client
+}
+
+func NewRedisRateLimiter(config types.RedisConfig) *RedisRateLimiter {
+	host := config.Host
+	if host == "" {
+		host = "localhost"
+	}
+
+	port := config.Port
+	if port == 0 {
+		port = 6379
+	}
+
+	return &RedisRateLimiter{
+		client: redis.NewClient(&redis.Options{
+			Addr:     fmt.Sprintf("%s:%d", host, port),
+			Password: config.Password,
+			DB:       config.DB,
+		}),
+	}
+}
+
+// CheckRates registers a hit for key against all rates at once and reports
+// whether it fits into every sliding window. Rates without a window are not
+// limited.
+func (l *RedisRateLimiter) CheckRates(ctx context.Context, key string, rates []models.Rate) (*models.RateLimitStatus, error) {
+	var limited []models.Rate
+	for _, rate := range rates {
+		if rate.Window > 0 {
+			limited = append(limited, rate)
+		}
+	}
+
+	if len(limited) == 0 {
+		var limit int64
+		if len(rates) > 0 {
+			limit = rates[0].Limit
+		}
+		return &models.RateLimitStatus{Allowed: true, Limit: limit, Remaining: limit}, nil
+	}
+
+	now := time.Now().UnixMilli()
+	keys := make([]string, len(limited))
+	args := []interface{}{now, fmt.Sprintf("%d-%s", now, uuid.New().String())}
+
+	for i, rate := range limited {
+		window := rate.Window.Milliseconds()
+		if window < 1 {
+			window = 1
+		}
+
+		// The hash tag keeps all windows of a key in one cluster slot, as a
+		// script may only touch keys of a single slot.
+		keys[i] = fmt.Sprintf("%s:{%s}:%d", rateLimitKeyPrefix, key, window)
+		args = append(args, window, rate.Limit)
+	}
+
+	result, err := slidingWindowScript.Run(ctx, l.client, keys, args...).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result) != 4 || result[3] < 1 || int(result[3]) > len(limited) {
+		return nil, fmt.Errorf("unexpected rate limiter response")
+	}
+
+	return &models.RateLimitStatus{
+		Allowed:   result[0] == 1,
+		Limit:     limited[result[3]-1].Limit,
+		Remaining: result[1],
+		Reset:     (result[2] + 999) / 1000,
+	}, nil
+}
+
+func (l *RedisRateLimiter) Close() error {
+	return l.client.Close()
+}
@@ -0,0 +1,26 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// tokenHasher hashes secrets kept at rest with an HMAC key derived from the
+// service secret for a single purpose, so the hashes of one kind of token
+// cannot be checked with another's key.
+type tokenHasher struct {
+	key []byte
+}
+
+func newTokenHasher(secretKey, purpose string) tokenHasher {
+	key := sha256.Sum256([]byte("sai-auth:" + purpose + ":" + secretKey))
+
+	return tokenHasher{key: key[:]}
+}
+
+func (h tokenHasher) hash(value string) string {
+	mac := hmac.New(sha256.New, h.key)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
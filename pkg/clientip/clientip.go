@@ -0,0 +1,100 @@
+package clientip
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// ParseNetworks parses CIDRs and plain addresses, which match only
+// themselves. Blank entries left by unset environment variables are skipped.
+func ParseNetworks(values []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(values))
+
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %q", value)
+			}
+
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip = ip4
+				bits = 8 * net.IPv4len
+			}
+
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", value)
+		}
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
+
+// Contains reports whether ip belongs to any of the networks.
+func Contains(networks []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Resolver finds the client address of a request. X-Forwarded-For is only
+// honoured when the connection comes from a trusted proxy, and is read from
+// the right so a client cannot spoof its address by sending the header
+// itself.
+type Resolver struct {
+	trustedProxies []*net.IPNet
+}
+
+func NewResolver(trustedProxies []string) (*Resolver, error) {
+	networks, err := ParseNetworks(trustedProxies)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Resolver{trustedProxies: networks}, nil
+}
+
+func (r *Resolver) ClientIP(ctx *fasthttp.RequestCtx) net.IP {
+	ip := ctx.RemoteIP()
+	if r == nil || !Contains(r.trustedProxies, ip) {
+		return ip
+	}
+
+	hops := strings.Split(string(ctx.Request.Header.Peek("X-Forwarded-For")), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			break
+		}
+
+		ip = hop
+		if !Contains(r.trustedProxies, ip) {
+			break
+		}
+	}
+
+	return ip
+}
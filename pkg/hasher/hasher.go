@@ -0,0 +1,57 @@
+package hasher
+
+// Hasher hashes passwords with one algorithm and recognizes its own encoded
+// hashes, so several hashers can coexist while stored passwords migrate.
+type Hasher interface {
+	Hash(password string) (string, error)
+	Verify(encoded, password string) bool
+	// Identifies reports whether encoded was produced by this algorithm.
+	Identifies(encoded string) bool
+	// NeedsRehash reports whether encoded uses weaker parameters than the
+	// hasher is configured with.
+	NeedsRehash(encoded string) bool
+}
+
+// Chain hashes new passwords with the primary hasher and verifies hashes of
+// any known algorithm. Hashes not produced by the primary hasher with its
+// current parameters need a rehash.
+type Chain struct {
+	primary Hasher
+	all     []Hasher
+}
+
+func NewChain(primary Hasher, legacy ...Hasher) *Chain {
+	return &Chain{
+		primary: primary,
+		all:     append([]Hasher{primary}, legacy...),
+	}
+}
+
+func (c *Chain) Hash(password string) (string, error) {
+	return c.primary.Hash(password)
+}
+
+func (c *Chain) Verify(encoded, password string) bool {
+	for _, h := range c.all {
+		if h.Identifies(encoded) {
+			return h.Verify(encoded, password)
+		}
+	}
+	return false
+}
+
+func (c *Chain) Identifies(encoded string) bool {
+	for _, h := range c.all {
+		if h.Identifies(encoded) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Chain) NeedsRehash(encoded string) bool {
+	if !c.primary.Identifies(encoded) {
+		return true
+	}
+	return c.primary.NeedsRehash(encoded)
+}
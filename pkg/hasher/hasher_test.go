@@ -0,0 +1,156 @@
+package hasher
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Cheap parameters keep the tests fast; the defaults are exercised once.
+var testArgon2idParams = Argon2idParams{
+	Memory:      1024,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+func mustHash(t *testing.T, h Hasher, password string) string {
+	t.Helper()
+
+	encoded, err := h.Hash(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encoded
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		hasher Hasher
+		prefix string
+	}{
+		{"argon2id", NewArgon2id(testArgon2idParams), "$argon2id$v=19$m=1024,t=1,p=1$"},
+		{"argon2id defaults", NewArgon2id(Argon2idParams{}), "$argon2id$v=19$m=65536,t=3,p=2$"},
+		{"bcrypt", NewBcrypt(bcrypt.MinCost), "$2a$04$"},
+		{"chain", NewChain(NewArgon2id(testArgon2idParams), NewBcrypt(bcrypt.MinCost)), "$argon2id$"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded := mustHash(t, tt.hasher, "correct horse")
+
+			if !strings.HasPrefix(encoded, tt.prefix) {
+				t.Fatalf("Hash() = %s, want prefix %s", encoded, tt.prefix)
+			}
+			if !tt.hasher.Identifies(encoded) {
+				t.Fatalf("Identifies() = false for its own hash")
+			}
+			if !tt.hasher.Verify(encoded, "correct horse") {
+				t.Fatalf("Verify() rejected the right password")
+			}
+			if tt.hasher.Verify(encoded, "correct horse ") {
+				t.Fatalf("Verify() accepted a wrong password")
+			}
+			if tt.hasher.NeedsRehash(encoded) {
+				t.Fatalf("NeedsRehash() = true for a fresh hash")
+			}
+			if again := mustHash(t, tt.hasher, "correct horse"); again == encoded {
+				t.Fatalf("Hash() is not salted")
+			}
+		})
+	}
+}
+
+func TestArgon2idVerifyRejectsMalformed(t *testing.T) {
+	h := NewArgon2id(testArgon2idParams)
+	encoded := mustHash(t, h, "secret")
+	parts := strings.Split(encoded, "$")
+
+	tests := map[string]string{
+		"empty":           "",
+		"bcrypt hash":     "$2a$04$abcdefghijklmnopqrstuu5cgtdUmdYV0BRQ2Gx0gf8ApWJm3l3Hm",
+		"wrong version":   strings.Replace(encoded, "v=19", "v=16", 1),
+		"bad parameters":  strings.Replace(encoded, parts[3], "m=x,t=1,p=1", 1),
+		"bad salt":        strings.Replace(encoded, parts[4], "!!", 1),
+		"empty key":       strings.TrimSuffix(encoded, parts[5]),
+		"missing segment": strings.Join(parts[:5], "$"),
+	}
+
+	for name, value := range tests {
+		t.Run(name, func(t *testing.T) {
+			if h.Verify(value, "secret") {
+				t.Fatalf("Verify(%q) accepted", value)
+			}
+			if !h.NeedsRehash(value) {
+				t.Fatalf("NeedsRehash(%q) = false", value)
+			}
+		})
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	weakArgon2id := testArgon2idParams
+	strongArgon2id := testArgon2idParams
+	strongArgon2id.Memory *= 2
+
+	longerKey := testArgon2idParams
+	longerKey.KeyLength = 64
+
+	tests := []struct {
+		name     string
+		producer Hasher
+		checker  Hasher
+		want     bool
+	}{
+		{"argon2id same parameters", NewArgon2id(weakArgon2id), NewArgon2id(weakArgon2id), false},
+		{"argon2id more memory configured", NewArgon2id(weakArgon2id), NewArgon2id(strongArgon2id), true},
+		{"argon2id less memory configured", NewArgon2id(strongArgon2id), NewArgon2id(weakArgon2id), false},
+		{"argon2id longer key configured", NewArgon2id(weakArgon2id), NewArgon2id(longerKey), true},
+		{"bcrypt same cost", NewBcrypt(bcrypt.MinCost), NewBcrypt(bcrypt.MinCost), false},
+		{"bcrypt higher cost configured", NewBcrypt(bcrypt.MinCost), NewBcrypt(bcrypt.MinCost + 1), true},
+		{"bcrypt lower cost configured", NewBcrypt(bcrypt.MinCost + 1), NewBcrypt(bcrypt.MinCost), false},
+		{"chain legacy bcrypt", NewBcrypt(bcrypt.MinCost), NewChain(NewArgon2id(weakArgon2id), NewBcrypt(bcrypt.MinCost)), true},
+		{"chain primary argon2id", NewArgon2id(weakArgon2id), NewChain(NewArgon2id(weakArgon2id), NewBcrypt(bcrypt.MinCost)), false},
+		{"chain weaker argon2id", NewArgon2id(weakArgon2id), NewChain(NewArgon2id(strongArgon2id), NewBcrypt(bcrypt.MinCost)), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded := mustHash(t, tt.producer, "secret")
+			if got := tt.checker.NeedsRehash(encoded); got != tt.want {
+				t.Fatalf("NeedsRehash() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChainVerifiesLegacyHashes(t *testing.T) {
+	legacy := NewBcrypt(bcrypt.MinCost)
+	chain := NewChain(NewArgon2id(testArgon2idParams), legacy)
+
+	encoded := mustHash(t, legacy, "secret")
+
+	if !chain.Identifies(encoded) {
+		t.Fatalf("Identifies() = false for a legacy hash")
+	}
+	if !chain.Verify(encoded, "secret") {
+		t.Fatalf("Verify() rejected a legacy hash")
+	}
+	if chain.Verify(encoded, "wrong") {
+		t.Fatalf("Verify() accepted a wrong password for a legacy hash")
+	}
+	if chain.Identifies("plaintext") || chain.Verify("plaintext", "plaintext") {
+		t.Fatalf("Chain accepted an unknown hash format")
+	}
+}
+
+func TestNewBcryptFallsBackToDefaultCost(t *testing.T) {
+	for _, cost := range []int{0, bcrypt.MinCost - 1, bcrypt.MaxCost + 1} {
+		if got := NewBcrypt(cost).cost; got != bcrypt.DefaultCost {
+			t.Errorf("NewBcrypt(%d).cost = %d, want %d", cost, got, bcrypt.DefaultCost)
+		}
+	}
+}
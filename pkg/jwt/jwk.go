@@ -0,0 +1,72 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"fmt"
+	"math/big"
+)
+
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+func NewJWK(kid, algorithm string, publicKey crypto.PublicKey) (*JWK, error) {
+	switch key := publicKey.(type) {
+	case ed25519.PublicKey:
+		return &JWK{
+			KeyType:   "OKP",
+			KeyID:     kid,
+			Algorithm: algorithm,
+			Use:       "sig",
+			Curve:     "Ed25519",
+			X:         encode(key),
+		}, nil
+	case *rsa.PublicKey:
+		return &JWK{
+			KeyType:   "RSA",
+			KeyID:     kid,
+			Algorithm: algorithm,
+			Use:       "sig",
+			N:         encode(key.N.Bytes()),
+			E:         encode(big.NewInt(int64(key.E)).Bytes()),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", publicKey)
+	}
+}
+
+func (k JWK) PublicKey() (crypto.PublicKey, error) {
+	switch k.KeyType {
+	case "OKP":
+		x, err := decode(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key %s", k.KeyID)
+		}
+		return ed25519.PublicKey(x), nil
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA key %s", k.KeyID)
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA key %s", k.KeyID)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.KeyType)
+	}
+}
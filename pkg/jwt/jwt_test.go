@@ -0,0 +1,176 @@
+package jwt
+
+import (
+	"crypto"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testIssuer = "sai-auth"
+
+func testKey(t *testing.T, kid, algorithm string) *SigningKey {
+	t.Helper()
+
+	privateKey, err := GenerateKey(algorithm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &SigningKey{KeyID: kid, Algorithm: algorithm, PrivateKey: privateKey}
+}
+
+func testClaims(expiresAt int64) *Claims {
+	return &Claims{
+		Issuer:    testIssuer,
+		Subject:   "user-1",
+		SessionID: "session-1",
+		IssuedAt:  time.Now().Unix(),
+		ExpiresAt: expiresAt,
+		Permissions: []Permission{
+			{Microservice: "storage", Method: "GET", Path: "/api/v1/*"},
+		},
+	}
+}
+
+func keySet(keys ...*SigningKey) KeyFunc {
+	return func(kid string) (crypto.PublicKey, error) {
+		for _, key := range keys {
+			if key.KeyID == kid {
+				return key.PrivateKey.Public(), nil
+			}
+		}
+		return nil, ErrUnknownKey
+	}
+}
+
+func sign(t *testing.T, claims *Claims, key *SigningKey) string {
+	t.Helper()
+
+	token, err := Sign(claims, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// withHeader swaps the header of token, keeping its payload and signature.
+func withHeader(token, headerJSON string) string {
+	parts := strings.Split(token, ".")
+	return encode([]byte(headerJSON)) + "." + parts[1] + "." + parts[2]
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	for _, algorithm := range []string{AlgorithmEdDSA, AlgorithmRS256} {
+		t.Run(algorithm, func(t *testing.T) {
+			key := testKey(t, "k1", algorithm)
+
+			jwk, err := NewJWK(key.KeyID, algorithm, key.PrivateKey.Public())
+			if err != nil {
+				t.Fatal(err)
+			}
+			publicKey, err := jwk.PublicKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+			fromJWKS := func(kid string) (crypto.PublicKey, error) {
+				if kid != jwk.KeyID {
+					return nil, ErrUnknownKey
+				}
+				return publicKey, nil
+			}
+
+			want := testClaims(time.Now().Add(time.Minute).Unix())
+			claims, err := Parse(sign(t, want, key), testIssuer, fromJWKS)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if claims.Subject != want.Subject || claims.SessionID != want.SessionID ||
+				len(claims.Permissions) != 1 || claims.Permissions[0] != want.Permissions[0] {
+				t.Fatalf("Parse() claims = %+v, want %+v", claims, want)
+			}
+		})
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	edKey := testKey(t, "ed", AlgorithmEdDSA)
+	otherEdKey := testKey(t, "ed-other", AlgorithmEdDSA)
+	rsaKey := testKey(t, "rsa", AlgorithmRS256)
+	keys := keySet(edKey, otherEdKey, rsaKey)
+
+	valid := sign(t, testClaims(time.Now().Add(time.Minute).Unix()), edKey)
+	validRSA := sign(t, testClaims(time.Now().Add(time.Minute).Unix()), rsaKey)
+	parts := strings.Split(valid, ".")
+
+	tamperedSignature := []byte(parts[2])
+	if tamperedSignature[0] == 'A' {
+		tamperedSignature[0] = 'B'
+	} else {
+		tamperedSignature[0] = 'A'
+	}
+
+	tamperedClaims := testClaims(time.Now().Add(time.Minute).Unix())
+	tamperedClaims.Subject = "user-2"
+	resigned := sign(t, tamperedClaims, edKey)
+
+	foreignIssuer := testClaims(time.Now().Add(time.Minute).Unix())
+	foreignIssuer.Issuer = "someone-else"
+
+	noIssuer := testClaims(time.Now().Add(time.Minute).Unix())
+	noIssuer.Issuer = ""
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"malformed", "not-a-token", ErrMalformed},
+		{"two segments", parts[0] + "." + parts[1], ErrMalformed},
+		{"bad header encoding", "!!!." + parts[1] + "." + parts[2], ErrMalformed},
+		{"tampered signature", parts[0] + "." + parts[1] + "." + string(tamperedSignature), ErrInvalidSignature},
+		{"tampered payload", parts[0] + "." + strings.Split(resigned, ".")[1] + "." + parts[2], ErrInvalidSignature},
+		{"empty signature", parts[0] + "." + parts[1] + ".", ErrInvalidSignature},
+		{"alg none", withHeader(parts[0]+"."+parts[1]+".", `{"alg":"none","typ":"JWT","kid":"ed"}`), ErrInvalidSignature},
+		{"EdDSA token relabelled RS256", withHeader(valid, `{"alg":"RS256","typ":"JWT","kid":"ed"}`), ErrInvalidSignature},
+		{"RS256 token relabelled EdDSA", withHeader(validRSA, `{"alg":"EdDSA","typ":"JWT","kid":"rsa"}`), ErrInvalidSignature},
+		{"EdDSA token pointed at RSA kid", withHeader(valid, `{"alg":"EdDSA","typ":"JWT","kid":"rsa"}`), ErrInvalidSignature},
+		{"token pointed at another key", withHeader(valid, `{"alg":"EdDSA","typ":"JWT","kid":"ed-other"}`), ErrInvalidSignature},
+		{"unknown kid", withHeader(valid, `{"alg":"EdDSA","typ":"JWT","kid":"missing"}`), ErrUnknownKey},
+		{"expired", sign(t, testClaims(time.Now().Add(-time.Second).Unix()), edKey), ErrExpired},
+		{"expiring now", sign(t, testClaims(time.Now().Unix()), edKey), ErrExpired},
+		{"foreign issuer", sign(t, foreignIssuer, edKey), ErrInvalidIssuer},
+		{"missing issuer", sign(t, noIssuer, edKey), ErrInvalidIssuer},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := Parse(tt.token, testIssuer, keys)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Parse() error = %v, want %v", err, tt.want)
+			}
+			if claims != nil {
+				t.Fatalf("Parse() returned claims for a rejected token")
+			}
+		})
+	}
+}
+
+func TestLooksLikeJWT(t *testing.T) {
+	tests := []struct {
+		token string
+		want  bool
+	}{
+		{"a.b.c", true},
+		{"3f9a0c", false},
+		{"a.b", false},
+		{"a.b.c.d", false},
+	}
+
+	for _, tt := range tests {
+		if got := LooksLikeJWT(tt.token); got != tt.want {
+			t.Errorf("LooksLikeJWT(%q) = %v, want %v", tt.token, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"strconv"
+
+	"github.com/saiset-co/sai-auth/internal/models"
+	saiTypes "github.com/saiset-co/sai-service/types"
+)
+
+// SetRateLimitHeaders writes the RateLimit-* headers from the IETF
+// RateLimit header fields draft, plus Retry-After for rejected requests.
+func SetRateLimitHeaders(ctx *saiTypes.RequestCtx, status *models.RateLimitStatus) {
+	if status == nil {
+		return
+	}
+
+	ctx.Response.Header.Set("RateLimit-Limit", strconv.FormatInt(status.Limit, 10))
+	ctx.Response.Header.Set("RateLimit-Remaining", strconv.FormatInt(status.Remaining, 10))
+	ctx.Response.Header.Set("RateLimit-Reset", strconv.FormatInt(status.Reset, 10))
+
+	if !status.Allowed {
+		ctx.Response.Header.Set("Retry-After", strconv.FormatInt(status.Reset, 10))
+	}
+}
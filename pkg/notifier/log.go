@@ -0,0 +1,47 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/saiset-co/sai-service/sai"
+	"go.uber.org/zap"
+)
+
+// LogNotifier is meant for development: messages are appended to a file, or
+// logged when no file is set. Message bodies carry secrets such as reset
+// links, so it must not be used in production.
+type LogNotifier struct {
+	file string
+	mu   sync.Mutex
+}
+
+func NewLogNotifier(file string) *LogNotifier {
+	return &LogNotifier{file: file}
+}
+
+func (n *LogNotifier) Send(ctx context.Context, msg *Message) error {
+	if n.file == "" {
+		sai.Logger().Info("Notification",
+			zap.String("to", msg.To),
+			zap.String("subject", msg.Subject),
+			zap.String("body", msg.Body))
+		return nil
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	f, err := os.OpenFile(n.file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "Date: %s\nTo: %s\nSubject: %s\n\n%s\n\n",
+		time.Now().Format(time.RFC1123Z), msg.To, msg.Subject, msg.Body)
+	return err
+}
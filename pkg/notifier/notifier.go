@@ -0,0 +1,32 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/saiset-co/sai-auth/types"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Notifier delivers messages such as password reset links to users.
+type Notifier interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// New builds the notifier selected by config.Type: "smtp", or "log" (the
+// default) which writes messages to a file or the service log.
+func New(config types.NotifierConfig) (Notifier, error) {
+	switch config.Type {
+	case "", "log":
+		return NewLogNotifier(config.File), nil
+	case "smtp":
+		return NewSMTPNotifier(config.SMTP)
+	default:
+		return nil, fmt.Errorf("unknown notifier type %q", config.Type)
+	}
+}
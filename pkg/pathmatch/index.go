@@ -0,0 +1,207 @@
+package pathmatch
+
+import (
+	"sort"
+	"strings"
+)
+
+const (
+	kindExact = iota
+	kindTemplate
+	kindWildcard
+)
+
+// Less reports whether pattern a is more specific than pattern b: exact
+// paths come before templates with single-segment placeholders, which come
+// before wildcards, and within each kind longer literal parts win. Equally
+// specific patterns are ordered by their text so the ranking is the same
+// for every caller.
+func Less(a, b string) bool {
+	kindA, lenA := rank(a)
+	kindB, lenB := rank(b)
+
+	if kindA != kindB {
+		return kindA < kindB
+	}
+	if lenA != lenB {
+		return lenA > lenB
+	}
+	return a < b
+}
+
+// rank returns the kind of pattern and the length of its literal part.
+func rank(pattern string) (int, int) {
+	if isTemplate(pattern) {
+		tmpl, err := compiled(pattern)
+		if err != nil {
+			return kindWildcard, 0
+		}
+
+		length, multi := tmpl.literalLength()
+		if multi {
+			return kindWildcard, length
+		}
+		return kindTemplate, length
+	}
+
+	if strings.HasSuffix(pattern, "*") {
+		return kindWildcard, len(wildcardPrefix(pattern))
+	}
+	return kindExact, len(pattern)
+}
+
+// wildcardPrefix is the literal prefix a trailing-* pattern matches with,
+// as in Match.
+func wildcardPrefix(pattern string) string {
+	if strings.HasSuffix(pattern, "/*") {
+		return strings.TrimSuffix(pattern, "/*")
+	}
+	return strings.TrimSuffix(pattern, "*")
+}
+
+// Index finds the patterns covering a path without testing each of them.
+// Patterns are stored in a trie of path segments; a wildcard is kept at the
+// node of its last full segment together with the partial segment it
+// requires, so matching follows the same prefix rules as Match. A template
+// is kept at the node of its leading literal segments and only its
+// remaining segments are matched at lookup.
+type Index struct {
+	root *node
+}
+
+type node struct {
+	children  map[string]*node
+	exact     []entry
+	wildcards []wildcard
+	templates []templateEntry
+}
+
+type entry struct {
+	id      int
+	pattern string
+}
+
+type wildcard struct {
+	rest string
+	entry
+}
+
+type templateEntry struct {
+	rest *template
+	entry
+}
+
+// Result is a pattern matched by Index.Lookup with the values captured by
+// its named segments.
+type Result struct {
+	ID     int
+	Params map[string]string
+}
+
+func NewIndex() *Index {
+	return &Index{root: newNode()}
+}
+
+func newNode() *node {
+	return &node{children: make(map[string]*node)}
+}
+
+// Add stores pattern under id. Invalid templates are skipped as they match
+// nothing.
+func (idx *Index) Add(pattern string, id int) {
+	if isTemplate(pattern) {
+		tmpl, err := compiled(pattern)
+		if err != nil {
+			return
+		}
+
+		literal := 0
+		for literal < len(tmpl.segments) && tmpl.segments[literal].kind == segmentLiteral {
+			literal++
+		}
+
+		segments := make([]string, literal)
+		for i := range segments {
+			segments[i] = tmpl.segments[i].literal
+		}
+
+		n := idx.descend(segments)
+		n.templates = append(n.templates, templateEntry{
+			rest:  &template{segments: tmpl.segments[literal:]},
+			entry: entry{id: id, pattern: pattern},
+		})
+		return
+	}
+
+	if !strings.HasSuffix(pattern, "*") {
+		n := idx.descend(strings.Split(pattern, "/"))
+		n.exact = append(n.exact, entry{id: id, pattern: pattern})
+		return
+	}
+
+	segments := strings.Split(wildcardPrefix(pattern), "/")
+	last := len(segments) - 1
+	n := idx.descend(segments[:last])
+	n.wildcards = append(n.wildcards, wildcard{rest: segments[last], entry: entry{id: id, pattern: pattern}})
+}
+
+func (idx *Index) descend(segments []string) *node {
+	current := idx.root
+	for _, segment := range segments {
+		next, ok := current.children[segment]
+		if !ok {
+			next = newNode()
+			current.children[segment] = next
+		}
+		current = next
+	}
+	return current
+}
+
+// Lookup returns the patterns matching requestPath, most specific first as
+// ordered by Less.
+func (idx *Index) Lookup(requestPath string) []Result {
+	var matches []entry
+	captured := make(map[int]map[string]string)
+
+	segments := strings.Split(requestPath, "/")
+	current := idx.root
+	offset := 0
+
+	for depth := 0; current != nil; depth++ {
+		for _, t := range current.templates {
+			if params, ok := t.rest.match(segments[depth:]); ok {
+				matches = append(matches, t.entry)
+				captured[t.id] = params
+			}
+		}
+
+		if depth == len(segments) {
+			matches = append(matches, current.exact...)
+			break
+		}
+
+		remaining := requestPath[offset:]
+		for _, w := range current.wildcards {
+			if strings.HasPrefix(remaining, w.rest) {
+				matches = append(matches, w.entry)
+			}
+		}
+
+		offset += len(segments[depth]) + 1
+		current = current.children[segments[depth]]
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].pattern != matches[j].pattern {
+			return Less(matches[i].pattern, matches[j].pattern)
+		}
+		return matches[i].id < matches[j].id
+	})
+
+	results := make([]Result, len(matches))
+	for i, match := range matches {
+		results[i] = Result{ID: match.id, Params: captured[match.id]}
+	}
+	return results
+}
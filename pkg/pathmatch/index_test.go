@@ -0,0 +1,171 @@
+package pathmatch
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestLess(t *testing.T) {
+	tests := []struct {
+		more, less string
+	}{
+		{"/api/v1/users", "/api/v1/{resource}"},
+		{"/api/v1/users", "/api/v1/*"},
+		{"/api/v1/{resource}", "/api/v1/*"},
+		{"/api/v1/{resource}", "/api/v1/**"},
+		{"/api/v1/{resource}", "/api/v1/{rest...}"},
+		{"/api/v1/*/users", "/api/v1/*"},
+		{"/api/v1/users/export", "/api/v1/users"},
+		{"/api/v1/{id}/edit", "/api/v1/{id}"},
+		{"/api/v1/users/*", "/api/v1/*"},
+		{"/api/v1/users*", "/api/v1/*"},
+		{"/api/v1/**/users", "/api/v1/**"},
+		{"/api/v1/{id}", "/api/v1/{name}"},
+		{"/api/v1/users", "/api/v1/{bad"},
+	}
+
+	for _, tt := range tests {
+		if !Less(tt.more, tt.less) {
+			t.Errorf("Less(%s, %s) = false, want true", tt.more, tt.less)
+		}
+		if Less(tt.less, tt.more) {
+			t.Errorf("Less(%s, %s) = true, want false", tt.less, tt.more)
+		}
+	}
+
+	if Less("/api/v1/users", "/api/v1/users") {
+		t.Errorf("Less() is not irreflexive")
+	}
+}
+
+var indexPatterns = []string{
+	"*",
+	"/*",
+	"/api/*",
+	"/api/v1*",
+	"/api/v1/*",
+	"/api/v1/users",
+	"/api/v1/users/",
+	"/api/v1/users/*",
+	"/api/v1/users/{id}",
+	"/api/v1/users/{id:[0-9]+}",
+	"/api/v1/users/{id}/roles",
+	"/api/v1/users/*/roles",
+	"/api/v1/users/me",
+	"/api/v1/{resource}/{id}",
+	"/api/v1/**",
+	"/api/v1/**/export",
+	"/api/**/users/{id}",
+	"/files/{path...}",
+	"/files/{path...}/raw",
+	"/files/public/*",
+	"/api/v1/users/{id}/{id}",
+	"/api/v1/{bad",
+	"",
+}
+
+var indexPaths = []string{
+	"",
+	"/",
+	"/api",
+	"/api/",
+	"/api/v1",
+	"/api/v1/",
+	"/api/v10",
+	"/api/v1/users",
+	"/api/v1/users/",
+	"/api/v1/users/42",
+	"/api/v1/users/me",
+	"/api/v1/users/abc/roles",
+	"/api/v1/users/42/roles/extra",
+	"/api/v1/groups/7",
+	"/api/v1/reports/2024/export",
+	"/api/v2/users/9",
+	"/files",
+	"/files/",
+	"/files/public/logo.png",
+	"/files/a/b/raw",
+	"/files/a/raw/raw",
+	"/other",
+}
+
+// TestIndexLookupMatchesLinearScan checks the index against testing every
+// pattern with Capture: same patterns, same captures, ordered by Less.
+func TestIndexLookupMatchesLinearScan(t *testing.T) {
+	idx := NewIndex()
+	for id, pattern := range indexPatterns {
+		idx.Add(pattern, id)
+	}
+
+	for _, path := range indexPaths {
+		var want []Result
+		for id, pattern := range indexPatterns {
+			if params, ok := Capture(pattern, path); ok {
+				want = append(want, Result{ID: id, Params: params})
+			}
+		}
+		sort.SliceStable(want, func(i, j int) bool {
+			return Less(indexPatterns[want[i].ID], indexPatterns[want[j].ID])
+		})
+
+		got := idx.Lookup(path)
+		if len(got) != len(want) {
+			t.Errorf("Lookup(%q) = %v, want %v", path, patternsOf(got), patternsOf(want))
+			continue
+		}
+
+		for i := range want {
+			if got[i].ID != want[i].ID {
+				t.Errorf("Lookup(%q) = %v, want %v", path, patternsOf(got), patternsOf(want))
+				break
+			}
+			if len(got[i].Params)+len(want[i].Params) > 0 && !reflect.DeepEqual(got[i].Params, want[i].Params) {
+				t.Errorf("Lookup(%q) params of %s = %v, want %v", path, indexPatterns[got[i].ID], got[i].Params, want[i].Params)
+			}
+		}
+	}
+}
+
+func TestIndexLookupMostSpecificFirst(t *testing.T) {
+	idx := NewIndex()
+	idx.Add("/api/v1/*", 1)
+	idx.Add("/api/v1/users/{id}", 2)
+	idx.Add("/api/v1/users/42", 3)
+	idx.Add("/api/v1/**", 4)
+
+	got := idx.Lookup("/api/v1/users/42")
+
+	want := []int{3, 2, 4, 1}
+	if len(got) != len(want) {
+		t.Fatalf("Lookup() = %v, want ids %v", got, want)
+	}
+	for i, id := range want {
+		if got[i].ID != id {
+			t.Fatalf("Lookup() = %v, want ids %v", got, want)
+		}
+	}
+
+	if got[1].Params["id"] != "42" {
+		t.Fatalf("Lookup() params = %v, want id 42", got[1].Params)
+	}
+}
+
+func TestIndexKeepsDuplicatePatterns(t *testing.T) {
+	idx := NewIndex()
+	idx.Add("/api/v1/users", 2)
+	idx.Add("/api/v1/users", 1)
+
+	got := idx.Lookup("/api/v1/users")
+	if len(got) != 2 || got[0].ID != 1 || got[1].ID != 2 {
+		t.Fatalf("Lookup() = %v, want ids [1 2]", got)
+	}
+}
+
+func patternsOf(results []Result) []string {
+	patterns := make([]string, len(results))
+	for i, result := range results {
+		patterns[i] = indexPatterns[result.ID]
+	}
+	return patterns
+}
@@ -0,0 +1,45 @@
+package pathmatch
+
+import "strings"
+
+// Match reports whether requestPath is covered by the permission path pattern.
+// It is shared by the auth service and providers verifying tokens locally so
+// both sides take the same decision.
+func Match(pattern, requestPath string) bool {
+	_, ok := Capture(pattern, requestPath)
+	return ok
+}
+
+// Capture matches like Match and also returns the values of the named
+// segments of a path template.
+func Capture(pattern, requestPath string) (map[string]string, bool) {
+	if isTemplate(pattern) {
+		tmpl, err := compiled(pattern)
+		if err != nil {
+			return nil, false
+		}
+		return tmpl.match(strings.Split(requestPath, "/"))
+	}
+
+	// Exact match
+	if pattern == requestPath {
+		return nil, true
+	}
+
+	// Wildcard match - /api/v1/* and /api/v1* both match any path starting with /api/v1
+	if strings.HasSuffix(pattern, "*") {
+		return nil, strings.HasPrefix(requestPath, wildcardPrefix(pattern))
+	}
+
+	return nil, false
+}
+
+// Validate reports why a permission path pattern can never match.
+func Validate(pattern string) error {
+	if !isTemplate(pattern) {
+		return nil
+	}
+
+	_, err := compiled(pattern)
+	return err
+}
@@ -0,0 +1,201 @@
+package pathmatch
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	segmentLiteral = iota
+	segmentSingle
+	segmentMulti
+)
+
+// template is a parsed path template. Its segments are literals, "{name}"
+// or "*" for exactly one segment, "{name:regex}" for one segment matching
+// regex, and "{name...}" or "**" for any number of segments.
+type template struct {
+	segments []segment
+}
+
+type segment struct {
+	kind    int
+	literal string
+	name    string
+	re      *regexp.Regexp
+}
+
+// templates caches parsed templates by pattern.
+var templates sync.Map
+
+type parsed struct {
+	tmpl *template
+	err  error
+}
+
+// isTemplate tells path templates from plain paths and trailing-* prefixes,
+// which keep their original meaning.
+func isTemplate(pattern string) bool {
+	if strings.Contains(pattern, "{") {
+		return true
+	}
+
+	segments := strings.Split(pattern, "/")
+	for i, segment := range segments {
+		if segment == "**" || (segment == "*" && i < len(segments)-1) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func compiled(pattern string) (*template, error) {
+	if cached, ok := templates.Load(pattern); ok {
+		result := cached.(parsed)
+		return result.tmpl, result.err
+	}
+
+	tmpl, err := parseTemplate(pattern)
+	templates.Store(pattern, parsed{tmpl: tmpl, err: err})
+
+	return tmpl, err
+}
+
+func parseTemplate(pattern string) (*template, error) {
+	parts := strings.Split(pattern, "/")
+	tmpl := &template{segments: make([]segment, 0, len(parts))}
+	names := make(map[string]bool)
+
+	for _, part := range parts {
+		seg, err := parseSegment(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path template %s: %w", pattern, err)
+		}
+
+		if seg.name != "" {
+			if names[seg.name] {
+				return nil, fmt.Errorf("invalid path template %s: duplicate segment name %s", pattern, seg.name)
+			}
+			names[seg.name] = true
+		}
+
+		tmpl.segments = append(tmpl.segments, seg)
+	}
+
+	return tmpl, nil
+}
+
+func parseSegment(part string) (segment, error) {
+	switch part {
+	case "*":
+		return segment{kind: segmentSingle}, nil
+	case "**":
+		return segment{kind: segmentMulti}, nil
+	}
+
+	if !strings.HasPrefix(part, "{") || !strings.HasSuffix(part, "}") {
+		if strings.ContainsAny(part, "{}*") {
+			return segment{}, fmt.Errorf("segment %s must be a whole placeholder or wildcard", part)
+		}
+		return segment{kind: segmentLiteral, literal: part}, nil
+	}
+
+	inner := part[1 : len(part)-1]
+
+	if name, ok := strings.CutSuffix(inner, "..."); ok {
+		if name == "" {
+			return segment{}, fmt.Errorf("segment %s has no name", part)
+		}
+		return segment{kind: segmentMulti, name: name}, nil
+	}
+
+	name, expr, constrained := strings.Cut(inner, ":")
+	if name == "" {
+		return segment{}, fmt.Errorf("segment %s has no name", part)
+	}
+
+	seg := segment{kind: segmentSingle, name: name}
+	if constrained {
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return segment{}, fmt.Errorf("segment %s: %w", part, err)
+		}
+		seg.re = re
+	}
+
+	return seg, nil
+}
+
+// match matches the template against the path split on "/".
+func (t *template) match(parts []string) (map[string]string, bool) {
+	captures := make(map[string]string)
+	if !matchSegments(t.segments, parts, captures) {
+		return nil, false
+	}
+	return captures, true
+}
+
+func matchSegments(segments []segment, parts []string, captures map[string]string) bool {
+	if len(segments) == 0 {
+		return len(parts) == 0
+	}
+
+	seg := segments[0]
+
+	if seg.kind == segmentMulti {
+		for n := len(parts); n >= 0; n-- {
+			if matchSegments(segments[1:], parts[n:], captures) {
+				if seg.name != "" {
+					captures[seg.name] = strings.Join(parts[:n], "/")
+				}
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(parts) == 0 {
+		return false
+	}
+
+	switch seg.kind {
+	case segmentLiteral:
+		if parts[0] != seg.literal {
+			return false
+		}
+	case segmentSingle:
+		if parts[0] == "" || (seg.re != nil && !seg.re.MatchString(parts[0])) {
+			return false
+		}
+	}
+
+	if !matchSegments(segments[1:], parts[1:], captures) {
+		return false
+	}
+
+	if seg.name != "" {
+		captures[seg.name] = parts[0]
+	}
+	return true
+}
+
+// literalLength is the length of the template without its placeholders and
+// wildcards, and whether it has a multi-segment wildcard.
+func (t *template) literalLength() (int, bool) {
+	length := len(t.segments) - 1
+	multi := false
+
+	for _, seg := range t.segments {
+		switch seg.kind {
+		case segmentLiteral:
+			length += len(seg.literal)
+		case segmentMulti:
+			multi = true
+		}
+	}
+
+	return length, multi
+}
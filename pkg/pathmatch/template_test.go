@@ -0,0 +1,141 @@
+package pathmatch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCapture(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		path    string
+		want    map[string]string
+		ok      bool
+	}{
+		{"exact", "/api/v1/users", "/api/v1/users", nil, true},
+		{"exact mismatch", "/api/v1/users", "/api/v1/users/1", nil, false},
+		{"trailing prefix", "/api/v1/*", "/api/v1/users/1", nil, true},
+		{"trailing prefix mismatch", "/api/v1/*", "/api/v2/users", nil, false},
+		{"placeholder", "/users/{id}", "/users/42", map[string]string{"id": "42"}, true},
+		{"placeholder empty segment", "/users/{id}", "/users/", nil, false},
+		{"placeholder one segment only", "/users/{id}", "/users/42/roles", nil, false},
+		{"two placeholders", "/{tenant}/users/{id}", "/acme/users/42", map[string]string{"tenant": "acme", "id": "42"}, true},
+		{"regex", "/users/{id:[0-9]+}", "/users/42", map[string]string{"id": "42"}, true},
+		{"regex mismatch", "/users/{id:[0-9]+}", "/users/me", nil, false},
+		{"regex anchored", "/users/{id:[0-9]+}", "/users/42x", nil, false},
+		{"regex alternation anchored", "/users/{id:me|self}", "/users/meself", nil, false},
+		{"inner star", "/users/*/roles", "/users/42/roles", map[string]string{}, true},
+		{"inner star empty segment", "/users/*/roles", "/users//roles", nil, false},
+		{"inner star one segment only", "/users/*/roles", "/users/1/2/roles", nil, false},
+		{"rest", "/files/{path...}", "/files/a/b/c.txt", map[string]string{"path": "a/b/c.txt"}, true},
+		{"rest empty", "/files/{path...}", "/files", map[string]string{"path": ""}, true},
+		{"double star", "/files/**", "/files/a/b", map[string]string{}, true},
+		{"double star empty", "/files/**", "/files", map[string]string{}, true},
+		{"double star wrong root", "/files/**", "/other/a", nil, false},
+		{"rest then literal", "/files/{path...}/raw", "/files/a/b/raw", map[string]string{"path": "a/b"}, true},
+		{"rest then literal missing", "/files/{path...}/raw", "/files/a/b", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Capture(tt.pattern, tt.path)
+			if ok != tt.ok {
+				t.Fatalf("Capture(%s, %s) ok = %v, want %v", tt.pattern, tt.path, ok, tt.ok)
+			}
+			if ok && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Capture(%s, %s) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+			}
+			if Match(tt.pattern, tt.path) != tt.ok {
+				t.Fatalf("Match(%s, %s) disagrees with Capture", tt.pattern, tt.path)
+			}
+		})
+	}
+}
+
+func TestCaptureBacktracking(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		path    string
+		want    map[string]string
+		ok      bool
+	}{
+		{"rest gives back a literal", "/a/{rest...}/b", "/a/b/b", map[string]string{"rest": "b"}, true},
+		{"rest gives back two segments", "/a/{rest...}/b/{id}", "/a/b/b/c", map[string]string{"rest": "b", "id": "c"}, true},
+		{"rest takes the longest prefix", "/a/{rest...}/b/{id}", "/a/1/b/2/b/3", map[string]string{"rest": "1/b/2", "id": "3"}, true},
+		{"two double stars", "/a/**/b/**/c", "/a/x/b/y/b/z/c", map[string]string{}, true},
+		{"two rests", "/a/{x...}/b/{y...}", "/a/1/b/2/b/3", map[string]string{"x": "1/b/2", "y": "3"}, true},
+		{"regex forces backtracking", "/a/{rest...}/{id:[0-9]+}/b", "/a/1/x/b/2/b", map[string]string{"rest": "1/x/b", "id": "2"}, true},
+		{"no split fits", "/a/**/b/{id:[0-9]+}", "/a/b/x/b/y", nil, false},
+		{"no discarded captures", "/a/{rest...}/{id}/end", "/a/1/2/3/end", map[string]string{"rest": "1/2", "id": "3"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Capture(tt.pattern, tt.path)
+			if ok != tt.ok {
+				t.Fatalf("Capture(%s, %s) ok = %v, want %v", tt.pattern, tt.path, ok, tt.ok)
+			}
+			if ok && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Capture(%s, %s) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := []string{
+		"/api/v1/users",
+		"/api/v1/*",
+		"/api/v1*",
+		"/users/{id}",
+		"/users/{id:[0-9]+}/roles",
+		"/files/{path...}",
+		"/a/**/b/*/c",
+	}
+
+	for _, pattern := range valid {
+		if err := Validate(pattern); err != nil {
+			t.Errorf("Validate(%s) error = %v", pattern, err)
+		}
+	}
+
+	invalid := []string{
+		"/users/{}",
+		"/users/{:[0-9]+}",
+		"/users/{...}",
+		"/users/{id",
+		"/users/id}/{role}",
+		"/users/x{id}",
+		"/users/{id}x",
+		"/users/a*b/{id}",
+		"/users/***/{role}",
+		"/users/{id:(}",
+		"/users/{id}/roles/{id}",
+		"/users/{id}/{id...}",
+	}
+
+	for _, pattern := range invalid {
+		if err := Validate(pattern); err == nil {
+			t.Errorf("Validate(%s) accepted an invalid template", pattern)
+		}
+	}
+}
+
+func TestInvalidTemplateNeverMatches(t *testing.T) {
+	pattern := "/users/{id}/roles/{id}"
+
+	if Match(pattern, "/users/1/roles/2") {
+		t.Fatalf("Match() matched an invalid template")
+	}
+
+	idx := NewIndex()
+	idx.Add(pattern, 1)
+	idx.Add("/users/*", 2)
+
+	got := idx.Lookup("/users/1/roles/2")
+	if len(got) != 1 || got[0].ID != 2 {
+		t.Fatalf("Lookup() = %v, want only the valid pattern", got)
+	}
+}
@@ -1,23 +1,67 @@
 package providers
 
 import (
+	"crypto"
 	"encoding/json"
 	"errors"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/saiset-co/sai-auth/internal/models"
+	"github.com/saiset-co/sai-auth/pkg/clientip"
+	"github.com/saiset-co/sai-auth/pkg/jwt"
+	"github.com/saiset-co/sai-auth/pkg/middleware"
+	"github.com/saiset-co/sai-auth/pkg/pathmatch"
 	"github.com/saiset-co/sai-service/sai"
 	"github.com/saiset-co/sai-service/types"
 	"github.com/valyala/fasthttp"
 	"go.uber.org/zap"
 )
 
+// errRateLimited carries the basic auth challenge marker so the framework's auth
+// middleware keeps the 429 response written by the provider instead of
+// replacing it with a generic 401.
+//
+// This depends on sai-service v1.1.3, whose middleware/auth.go leaves the
+// response alone when the provider error contains "basic_auth_challenge_sent"
+// and has no other way for a provider to answer with its own status. Check
+// that behaviour again before upgrading sai-service.
+var errRateLimited = errors.New("rate limit exceeded: basic_auth_challenge_sent")
+
+const (
+	jwksRefreshInterval    = 10 * time.Minute
+	jwksMinRefetchInterval = 10 * time.Second
+	maxSessionChecks       = 10000
+)
+
 type SaiAuthProvider struct {
 	name           string
 	authServiceURL string
 	timeout        time.Duration
 	cachedToken    string
 	tokenExpiry    time.Time
+	trustedProxies *clientip.Resolver
+
+	userRateLimiter *middleware.RateLimitUserMiddleware
+
+	localVerification       bool
+	issuer                  string
+	revocationCheckInterval time.Duration
+
+	keysMu        sync.RWMutex
+	keys          map[string]crypto.PublicKey
+	keysFetchedAt time.Time
+
+	sessionsMu    sync.Mutex
+	sessionChecks map[string]sessionCheck
+}
+
+// sessionCheck is a positive introspection answer kept for
+// revocationCheckInterval.
+type sessionCheck struct {
+	checkedAt            time.Time
+	permissionsChangedAt int64
 }
 
 func NewSaiAuthProvider(name, authServiceURL string) *SaiAuthProvider {
@@ -28,6 +72,36 @@ func NewSaiAuthProvider(name, authServiceURL string) *SaiAuthProvider {
 	}
 }
 
+// EnableLocalVerification makes the provider verify JWT access tokens issued
+// by issuer against the auth service JWKS instead of calling /verify on every
+// request. The service is still asked about permissions with params or rates,
+// about each session at most once per revocationCheckInterval, and about
+// tenant sessions on every request, since their tenant can be deactivated.
+func (p *SaiAuthProvider) EnableLocalVerification(issuer string, revocationCheckInterval time.Duration) {
+	if issuer == "" {
+		issuer = jwt.DefaultIssuer
+	}
+
+	p.localVerification = true
+	p.issuer = issuer
+	p.revocationCheckInterval = revocationCheckInterval
+	p.keys = make(map[string]crypto.PublicKey)
+	p.sessionChecks = make(map[string]sessionCheck)
+}
+
+// SetUserRateLimiter makes the provider apply the rates of every verified
+// request per user before letting it through.
+func (p *SaiAuthProvider) SetUserRateLimiter(limiter *middleware.RateLimitUserMiddleware) {
+	p.userRateLimiter = limiter
+}
+
+// SetTrustedProxies makes the provider take the client IP it forwards to the
+// auth service from X-Forwarded-For when the request comes through one of
+// these proxies.
+func (p *SaiAuthProvider) SetTrustedProxies(resolver *clientip.Resolver) {
+	p.trustedProxies = resolver
+}
+
 func (p *SaiAuthProvider) Type() string {
 	return "sai_auth"
 }
@@ -39,27 +113,81 @@ func (p *SaiAuthProvider) ApplyToIncomingRequest(ctx *types.RequestCtx) error {
 		"method":         string(ctx.Method()),
 		"path":           string(ctx.Path()),
 		"request_params": p.extractRequestParams(ctx),
+		"client_ip":      p.trustedProxies.ClientIP(ctx.RequestCtx).String(),
 	}
 
-	allowed, userID, modifiedParams, err := p.verifyWithAuthService(requestData)
-	if err != nil {
-		sai.Logger().Error("SaiAuthProvider: Verification failed", zap.Error(err))
-		return err
+	var result *models.VerifyResponse
+	var err error
+
+	if p.localVerification && jwt.LooksLikeJWT(requestData["token"].(string)) {
+		result, err = p.verifyLocally(requestData)
+		if err != nil {
+			sai.Logger().Error("SaiAuthProvider: Local verification failed", zap.Error(err))
+			return err
+		}
 	}
 
-	if !allowed {
+	if result == nil {
+		result, err = p.verifyWithAuthService(requestData)
+		if err != nil {
+			sai.Logger().Error("SaiAuthProvider: Verification failed", zap.Error(err))
+			return err
+		}
+	}
+
+	if result.RateLimited {
+		sai.Logger().Warn("SaiAuthProvider: Rate limit exceeded",
+			zap.String("microservice", p.name),
+			zap.String("method", string(ctx.Method())),
+			zap.String("path", string(ctx.Path())),
+			zap.String("user_id", result.UserID))
+		ctx.Error(errors.New(result.Reason), fasthttp.StatusTooManyRequests)
+		middleware.SetRateLimitHeaders(ctx, result.RateLimit)
+		return errRateLimited
+	}
+
+	if !result.Allowed {
 		sai.Logger().Warn("SaiAuthProvider: Access denied",
 			zap.String("microservice", p.name),
 			zap.String("method", string(ctx.Method())),
 			zap.String("path", string(ctx.Path())),
-			zap.Any("request_params", requestData["request_params"]))
+			zap.Any("request_params", requestData["request_params"]),
+			zap.String("reason", result.Reason))
+		if result.Reason != "" {
+			return errors.New("access denied: " + result.Reason)
+		}
 		return errors.New("access denied")
 	}
 
-	ctx.SetUserValue("user_id", userID)
+	// The auth service already counted the request when it returned a rate
+	// limit status; the local limiter only covers an auth service without one.
+	rateLimit := result.RateLimit
+	if rateLimit == nil && p.userRateLimiter != nil && len(result.Rates) > 0 {
+		rateLimit = p.userRateLimiter.Check(ctx, result.UserID, result.Permission, result.Rates)
+		if rateLimit != nil && !rateLimit.Allowed {
+			sai.Logger().Warn("SaiAuthProvider: Rate limit exceeded",
+				zap.String("microservice", p.name),
+				zap.String("method", string(ctx.Method())),
+				zap.String("path", string(ctx.Path())),
+				zap.String("user_id", result.UserID))
+			ctx.Error(errors.New("Rate limit exceeded"), fasthttp.StatusTooManyRequests)
+			middleware.SetRateLimitHeaders(ctx, rateLimit)
+			return errRateLimited
+		}
+	}
+
+	ctx.SetUserValue("user_id", result.UserID)
+	if result.TenantID != "" {
+		ctx.SetUserValue("tenant_id", result.TenantID)
+	}
+	middleware.SetRateLimitHeaders(ctx, rateLimit)
 
-	if modifiedParams != nil {
-		p.applyModifiedParams(ctx, modifiedParams)
+	if result.ModifiedParams != nil {
+		p.applyModifiedParams(ctx, result.ModifiedParams)
+	}
+
+	if len(result.PathParams) > 0 {
+		ctx.SetUserValue("auth_path_params", result.PathParams)
 	}
 
 	return nil
@@ -93,7 +221,7 @@ func (p *SaiAuthProvider) extractRequestParams(ctx *types.RequestCtx) map[string
 	return params
 }
 
-func (p *SaiAuthProvider) verifyWithAuthService(requestData map[string]interface{}) (bool, string, map[string]interface{}, error) {
+func (p *SaiAuthProvider) verifyWithAuthService(requestData map[string]interface{}) (*models.VerifyResponse, error) {
 	req := fasthttp.AcquireRequest()
 	resp := fasthttp.AcquireResponse()
 	defer fasthttp.ReleaseRequest(req)
@@ -108,21 +236,238 @@ func (p *SaiAuthProvider) verifyWithAuthService(requestData map[string]interface
 	err := fasthttp.DoTimeout(req, resp, p.timeout)
 	if err != nil {
 		sai.Logger().Error("SaiAuthProvider request failed", zap.Error(err))
-		return false, "", nil, err
+		return nil, err
 	}
 
-	if resp.StatusCode() == 200 {
-		var result struct {
-			Allowed        bool                   `json:"allowed"`
-			UserID         string                 `json:"user_id"`
-			ModifiedParams map[string]interface{} `json:"modified_params"`
-		}
+	if resp.StatusCode() == fasthttp.StatusOK || resp.StatusCode() == fasthttp.StatusTooManyRequests {
+		var result models.VerifyResponse
 
 		err = json.Unmarshal(resp.Body(), &result)
-		return result.Allowed, result.UserID, result.ModifiedParams, err
+		return &result, err
+	}
+
+	return nil, errors.New("authorization failed")
+}
+
+// verifyLocally decides the request from the signed token alone. It returns a
+// nil result when the decision needs the auth service: superusers, and
+// permissions with params or rates.
+func (p *SaiAuthProvider) verifyLocally(requestData map[string]interface{}) (*models.VerifyResponse, error) {
+	token := requestData["token"].(string)
+
+	claims, err := jwt.Parse(token, p.issuer, p.publicKey)
+	if err != nil {
+		return &models.VerifyResponse{Allowed: false, Reason: err.Error()}, nil
+	}
+
+	if claims.SuperUser {
+		return nil, nil
+	}
+
+	method := requestData["method"].(string)
+	path := requestData["path"].(string)
+
+	var matched *jwt.Permission
+	var pathParams map[string]string
+	for i := range claims.Permissions {
+		permission := &claims.Permissions[i]
+		if permission.Microservice != p.name || permission.Method != method {
+			continue
+		}
+		captured, ok := pathmatch.Capture(permission.Path, path)
+		if !ok {
+			continue
+		}
+		if permission.Deny {
+			return &models.VerifyResponse{Allowed: false, Reason: "Access denied"}, nil
+		}
+		if matched == nil || pathmatch.Less(permission.Path, matched.Path) {
+			matched = permission
+			pathParams = captured
+		}
+	}
+
+	if matched == nil {
+		return &models.VerifyResponse{Allowed: false, Reason: "No permission found"}, nil
+	}
+
+	if matched.Remote {
+		return nil, nil
+	}
+
+	check, err := p.checkSession(token, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	if check == nil {
+		return &models.VerifyResponse{Allowed: false, Reason: "Session revoked"}, nil
+	}
+
+	// The session's permissions were recompiled after this token was issued,
+	// so the ones it carries may be out of date.
+	if check.permissionsChangedAt >= claims.IssuedAt*int64(time.Second) {
+		return nil, nil
+	}
+
+	params, _ := requestData["request_params"].(map[string]interface{})
+	modifiedParams := make(map[string]interface{}, len(params))
+	for key, value := range params {
+		modifiedParams[key] = value
+	}
+
+	return &models.VerifyResponse{
+		Allowed:        true,
+		UserID:         claims.Subject,
+		TenantID:       claims.TenantID,
+		ModifiedParams: modifiedParams,
+		PathParams:     pathParams,
+	}, nil
+}
+
+func (p *SaiAuthProvider) publicKey(kid string) (crypto.PublicKey, error) {
+	p.keysMu.RLock()
+	key, ok := p.keys[kid]
+	fetchedAt := p.keysFetchedAt
+	p.keysMu.RUnlock()
+
+	if ok && time.Since(fetchedAt) < jwksRefreshInterval {
+		return key, nil
+	}
+
+	if !ok && time.Since(fetchedAt) < jwksMinRefetchInterval {
+		return nil, jwt.ErrUnknownKey
+	}
+
+	if err := p.fetchKeys(); err != nil {
+		if ok {
+			return key, nil
+		}
+		return nil, err
+	}
+
+	p.keysMu.RLock()
+	defer p.keysMu.RUnlock()
+
+	key, ok = p.keys[kid]
+	if !ok {
+		return nil, jwt.ErrUnknownKey
+	}
+
+	return key, nil
+}
+
+func (p *SaiAuthProvider) fetchKeys() error {
+	req := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.SetRequestURI(p.authServiceURL + "/.well-known/jwks.json")
+	req.Header.SetMethod("GET")
+
+	p.keysMu.Lock()
+	p.keysFetchedAt = time.Now()
+	p.keysMu.Unlock()
+
+	err := fasthttp.DoTimeout(req, resp, p.timeout)
+	if err != nil {
+		sai.Logger().Error("SaiAuthProvider JWKS request failed", zap.Error(err))
+		return err
+	}
+
+	if resp.StatusCode() != fasthttp.StatusOK {
+		return errors.New("failed to fetch signing keys")
+	}
+
+	var set jwt.JWKSet
+	if err := json.Unmarshal(resp.Body(), &set); err != nil {
+		return err
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		key, err := jwk.PublicKey()
+		if err != nil {
+			sai.Logger().Warn("SaiAuthProvider: Skipping invalid JWK", zap.Error(err))
+			continue
+		}
+		keys[jwk.KeyID] = key
+	}
+
+	p.keysMu.Lock()
+	p.keys = keys
+	p.keysMu.Unlock()
+
+	return nil
+}
+
+// checkSession asks the auth service whether the session is still alive and
+// returns nil when it is not. A positive answer is trusted for
+// revocationCheckInterval, except for tenant sessions.
+func (p *SaiAuthProvider) checkSession(token string, claims *jwt.Claims) (*sessionCheck, error) {
+	sessionID := claims.SessionID
+
+	p.sessionsMu.Lock()
+	cached, ok := p.sessionChecks[sessionID]
+	p.sessionsMu.Unlock()
+
+	if ok && claims.TenantID == "" && time.Since(cached.checkedAt) < p.revocationCheckInterval {
+		return &cached, nil
+	}
+
+	req := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+
+	reqBody, _ := json.Marshal(map[string]interface{}{"token": token})
+	req.SetRequestURI(p.authServiceURL + "/api/v1/auth/introspect")
+	req.Header.SetMethod("POST")
+	req.Header.SetContentType("application/json")
+	req.SetBody(reqBody)
+
+	err := fasthttp.DoTimeout(req, resp, p.timeout)
+	if err != nil {
+		sai.Logger().Error("SaiAuthProvider introspection failed", zap.Error(err))
+		return nil, err
+	}
+
+	if resp.StatusCode() != fasthttp.StatusOK {
+		return nil, errors.New("session check failed")
+	}
+
+	var result models.IntrospectResponse
+	if err := json.Unmarshal(resp.Body(), &result); err != nil {
+		return nil, err
+	}
+
+	p.sessionsMu.Lock()
+	defer p.sessionsMu.Unlock()
+
+	if !result.Active {
+		delete(p.sessionChecks, sessionID)
+		return nil, nil
+	}
+
+	if len(p.sessionChecks) >= maxSessionChecks {
+		for id, check := range p.sessionChecks {
+			if time.Since(check.checkedAt) >= p.revocationCheckInterval {
+				delete(p.sessionChecks, id)
+			}
+		}
+		if len(p.sessionChecks) >= maxSessionChecks {
+			p.sessionChecks = make(map[string]sessionCheck)
+		}
+	}
+
+	check := sessionCheck{
+		checkedAt:            time.Now(),
+		permissionsChangedAt: result.PermissionsChangedAt,
 	}
+	p.sessionChecks[sessionID] = check
 
-	return false, "", nil, errors.New("authorization failed")
+	return &check, nil
 }
 
 func (p *SaiAuthProvider) applyModifiedParams(ctx *types.RequestCtx, params map[string]interface{}) {
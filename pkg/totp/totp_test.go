@@ -0,0 +1,96 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 seed of the RFC 6238 test vectors, "12345678901234567890".
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestValidateRFC6238Vectors(t *testing.T) {
+	// The RFC lists 8-digit codes; a 6-digit code is their last six digits.
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		step, ok := Validate(rfcSecret, tt.code, time.Unix(tt.unix, 0), 0)
+		if !ok {
+			t.Errorf("Validate(%d, %s) rejected a valid code", tt.unix, tt.code)
+			continue
+		}
+		if want := tt.unix / 30; step != want {
+			t.Errorf("Validate(%d, %s) step = %d, want %d", tt.unix, tt.code, step, want)
+		}
+	}
+}
+
+func TestValidateSkew(t *testing.T) {
+	key, err := encoding.DecodeString(rfcSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := time.Unix(1111111111, 0)
+	current := at.Unix() / 30
+
+	tests := []struct {
+		name     string
+		step     int64
+		lastStep int64
+		want     bool
+	}{
+		{"current step", current, 0, true},
+		{"one step behind", current - 1, 0, true},
+		{"one step ahead", current + 1, 0, true},
+		{"two steps behind", current - 2, 0, false},
+		{"two steps ahead", current + 2, 0, false},
+		{"replayed step", current, current, false},
+		{"step before last used", current - 1, current - 1, false},
+		{"step after last used", current, current - 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Validate(rfcSecret, generate(key, tt.step), at, tt.lastStep)
+			if ok != tt.want {
+				t.Fatalf("Validate() ok = %v, want %v", ok, tt.want)
+			}
+			if ok && step != tt.step {
+				t.Fatalf("Validate() step = %d, want %d", step, tt.step)
+			}
+		})
+	}
+}
+
+func TestValidateRejectsMalformedInput(t *testing.T) {
+	at := time.Unix(59, 0)
+
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+	}{
+		{"short code", rfcSecret, "28708"},
+		{"long code", rfcSecret, "2870820"},
+		{"wrong code", rfcSecret, "287083"},
+		{"invalid secret", "not base32!", "287082"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := Validate(tt.secret, tt.code, at, 0); ok {
+				t.Fatalf("Validate(%q, %q) accepted", tt.secret, tt.code)
+			}
+		})
+	}
+}
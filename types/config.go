@@ -3,12 +3,164 @@ package types
 import "time"
 
 type SaiAuthConfig struct {
-	AccessTokenTTL  time.Duration `yaml:"access_token_ttl"`
-	RefreshTokenTTL time.Duration `yaml:"refresh_token_ttl"`
-	BcryptCost      int           `yaml:"bcrypt_cost"`
-	SuperUser       struct {
-		AllowedIPs []string `yaml:"allowed_ips"`
-	} `yaml:"super_user"`
+	AccessTokenTTL  time.Duration        `yaml:"access_token_ttl"`
+	RefreshTokenTTL time.Duration        `yaml:"refresh_token_ttl"`
+	BcryptCost      int                  `yaml:"bcrypt_cost"`
+	SecretKey       string               `yaml:"secret_key"`
+	TrustedProxies  []string             `yaml:"trusted_proxies"`
+	SuperUser       SuperUserConfig      `yaml:"super_user"`
+	JWT             JWTConfig            `yaml:"jwt"`
+	MFA             MFAConfig            `yaml:"mfa"`
+	Lockout         LockoutConfig        `yaml:"lockout"`
+	PasswordPolicy  PasswordPolicyConfig `yaml:"password_policy"`
+	PasswordHash    PasswordHashConfig   `yaml:"password_hash"`
+	PasswordReset   PasswordResetConfig  `yaml:"password_reset"`
+	Notifier        NotifierConfig       `yaml:"notifier"`
+
+	EmailVerification EmailVerificationConfig `yaml:"email_verification"`
+	Registration      RegistrationConfig      `yaml:"registration"`
+	Invitation        InvitationConfig        `yaml:"invitation"`
+	Bootstrap         BootstrapConfig         `yaml:"bootstrap"`
+	RoleExpiry        RoleExpiryConfig        `yaml:"role_expiry"`
+}
+
+// SuperUserConfig limits where superusers may log in and act from. AllowedIPs
+// takes CIDRs or plain addresses; when empty superusers are not restricted.
+type SuperUserConfig struct {
+	AllowedIPs []string `yaml:"allowed_ips"`
+}
+
+type JWTConfig struct {
+	Enabled             bool          `yaml:"enabled"`
+	Algorithm           string        `yaml:"algorithm"`
+	Issuer              string        `yaml:"issuer"`
+	KeyRotationInterval time.Duration `yaml:"key_rotation_interval"`
+}
+
+// MFAConfig configures TOTP. At most MaxOpenChallenges login challenges are
+// kept per user; creating another one drops the oldest.
+type MFAConfig struct {
+	Issuer            string        `yaml:"issuer"`
+	ChallengeTTL      time.Duration `yaml:"challenge_ttl"`
+	MaxAttempts       int           `yaml:"max_attempts"`
+	MaxOpenChallenges int           `yaml:"max_open_challenges"`
+}
+
+// LockoutConfig throttles password guessing. Failures per user back off
+// exponentially from BackoffBase up to BackoffMax and lock the account for
+// LockDuration after MaxUserFailures; a source IP is refused after
+// MaxIPFailures failures within FailureWindow. A negative threshold disables
+// that check.
+type LockoutConfig struct {
+	MaxUserFailures int           `yaml:"max_user_failures"`
+	MaxIPFailures   int           `yaml:"max_ip_failures"`
+	FailureWindow   time.Duration `yaml:"failure_window"`
+	LockDuration    time.Duration `yaml:"lock_duration"`
+	BackoffBase     time.Duration `yaml:"backoff_base"`
+	BackoffMax      time.Duration `yaml:"backoff_max"`
+}
+
+// PasswordPolicyConfig governs new passwords. HistorySize counts the current
+// password, so 1 only forbids keeping the same one; MaxAge forces a change at
+// login once the password is older.
+type PasswordPolicyConfig struct {
+	MinLength     int           `yaml:"min_length"`
+	MaxLength     int           `yaml:"max_length"`
+	RequireUpper  bool          `yaml:"require_upper"`
+	RequireLower  bool          `yaml:"require_lower"`
+	RequireDigit  bool          `yaml:"require_digit"`
+	RequireSymbol bool          `yaml:"require_symbol"`
+	DenyCommon    bool          `yaml:"deny_common"`
+	HistorySize   int           `yaml:"history_size"`
+	MaxAge        time.Duration `yaml:"max_age"`
+}
+
+// PasswordHashConfig selects the algorithm for new password hashes: "bcrypt"
+// (default, cost from BcryptCost) or "argon2id". Hashes of the other
+// algorithm or with weaker parameters are rehashed on the next login.
+type PasswordHashConfig struct {
+	Algorithm string `yaml:"algorithm"`
+	Argon2id  struct {
+		Memory      uint32 `yaml:"memory"`
+		Iterations  uint32 `yaml:"iterations"`
+		Parallelism uint8  `yaml:"parallelism"`
+		SaltLength  uint32 `yaml:"salt_length"`
+		KeyLength   uint32 `yaml:"key_length"`
+	} `yaml:"argon2id"`
+}
+
+// PasswordResetConfig controls the forgot/reset flow. URL is the page that
+// accepts the reset token; the token is appended as the "token" query
+// parameter. Without URL the mail carries the bare token.
+type PasswordResetConfig struct {
+	TokenTTL time.Duration `yaml:"token_ttl"`
+	URL      string        `yaml:"url"`
+}
+
+// EmailVerificationConfig.Mode is "off" (default, no mails), "optional"
+// (mails are sent but not enforced), "required" (unverified accounts cannot
+// log in) or "restricted" (unverified accounts only get RestrictedRole).
+// Superusers are never held back by verification.
+type EmailVerificationConfig struct {
+	Mode           string        `yaml:"mode"`
+	RestrictedRole string        `yaml:"restricted_role"`
+	TokenTTL       time.Duration `yaml:"token_ttl"`
+	ResendInterval time.Duration `yaml:"resend_interval"`
+	URL            string        `yaml:"url"`
+}
+
+// RegistrationConfig enables the public /auth/register endpoint. Mode is
+// "disabled" (default), "open", "invite" (one of InviteCodes is required) or
+// "approval" (accounts stay inactive until an admin approves them).
+// DefaultRoles are role names. MaxPerIP caps registrations from one address
+// per lockout failure window; a negative value disables the cap.
+type RegistrationConfig struct {
+	Mode         string   `yaml:"mode"`
+	DefaultRoles []string `yaml:"default_roles"`
+	InviteCodes  []string `yaml:"invite_codes"`
+	MaxPerIP     int      `yaml:"max_per_ip"`
+}
+
+// InvitationConfig sets how long invitations stay valid and the page that
+// accepts them; the token is appended as the "token" query parameter.
+type InvitationConfig struct {
+	TTL time.Duration `yaml:"ttl"`
+	URL string        `yaml:"url"`
+}
+
+// BootstrapConfig creates the first superuser. With Username, Email and
+// PasswordHash (bcrypt or argon2id) the account is created at startup unless
+// a superuser exists. Otherwise SetupToken logs a one-time token at startup
+// for POST /auth/setup. AllowOpenAccess keeps the legacy behaviour of
+// allowing every request while there are no users at all.
+type BootstrapConfig struct {
+	Username        string `yaml:"username"`
+	Email           string `yaml:"email"`
+	PasswordHash    string `yaml:"password_hash"`
+	SetupToken      bool   `yaml:"setup_token"`
+	AllowOpenAccess bool   `yaml:"allow_open_access"`
+}
+
+// RoleExpiryConfig sets how often time-bound role assignments are checked.
+// Expired roles are removed and roles whose window opened are recompiled
+// into the user's permissions.
+type RoleExpiryConfig struct {
+	CheckInterval time.Duration `yaml:"check_interval"`
+}
+
+type NotifierConfig struct {
+	Type string     `yaml:"type"`
+	File string     `yaml:"file"`
+	SMTP SMTPConfig `yaml:"smtp"`
+}
+
+type SMTPConfig struct {
+	Host        string `yaml:"host"`
+	Port        int    `yaml:"port"`
+	Username    string `yaml:"username"`
+	Password    string `yaml:"password"`
+	From        string `yaml:"from"`
+	ImplicitTLS bool   `yaml:"implicit_tls"`
 }
 
 type RedisConfig struct {
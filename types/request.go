@@ -10,19 +10,57 @@ type UserFilterRequest struct {
 	PaginationRequest
 	Role   string `json:"role" form:"role"`
 	Active *bool  `json:"active" form:"active"`
+	Status string `json:"status" form:"status"`
+	Tenant string `json:"tenant" form:"tenant"`
+	// SuperUser is only set internally; List hides the flag from responses.
+	SuperUser *bool `json:"-" form:"-"`
+	// RoleWindowsDue selects users with a role window that starts or ends
+	// at or before this time; it is only set by the expiry job.
+	RoleWindowsDue int64 `json:"-" form:"-"`
+	// Match is a storage filter the users must also match; it is only set
+	// internally to resolve the users an update or delete filter selects.
+	Match map[string]interface{} `json:"-" form:"-"`
 }
 
 type RoleFilterRequest struct {
+	PaginationRequest
+	Active   *bool  `json:"active" form:"active"`
+	TenantID string `json:"tenant_id" form:"tenant_id"`
+}
+
+type TenantFilterRequest struct {
 	PaginationRequest
 	Active *bool `json:"active" form:"active"`
 }
 
+type GroupFilterRequest struct {
+	PaginationRequest
+	Active *bool  `json:"active" form:"active"`
+	Member string `json:"member" form:"member"`
+	Role   string `json:"role" form:"role"`
+	Parent string `json:"parent" form:"parent"`
+}
+
 type TokenFilterRequest struct {
 	PaginationRequest
 	UserID string `json:"user_id" form:"user_id"`
 	Active *bool  `json:"active" form:"active"`
 }
 
+type SecurityEventFilterRequest struct {
+	PaginationRequest
+	Type   string `json:"type" form:"type"`
+	UserID string `json:"user_id" form:"user_id"`
+	IP     string `json:"ip" form:"ip"`
+	Since  int64  `json:"since" form:"since"`
+}
+
+type InvitationFilterRequest struct {
+	PaginationRequest
+	Status string `json:"status" form:"status"`
+	Email  string `json:"email" form:"email"`
+}
+
 type UpdateRequest struct {
 	Filter map[string]interface{} `json:"filter" validate:"required"`
 	Data   map[string]interface{} `json:"data" validate:"required"`